@@ -0,0 +1,146 @@
+// Package alert evaluates price and stock rules against the runs recorded in
+// a history and delivers the resulting alerts to webhooks.
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"web-scraper/extract"
+)
+
+// RuleType selects what a rule looks for
+type RuleType string
+
+// Rule types
+const (
+	PriceBelow  RuleType = "price_below"   // price fell below a threshold
+	PriceDrop   RuleType = "price_drop"    // price dropped by at least a percentage
+	BackInStock RuleType = "back_in_stock" // product went from out of stock to in stock
+	NewProduct  RuleType = "new_product"   // product not seen in the previous run
+)
+
+// Rule describes when to raise an alert
+type Rule struct {
+	Name     string   `yaml:"name" json:"name"`
+	Type     RuleType `yaml:"type" json:"type"`
+	Below    string   `yaml:"below,omitempty" json:"below,omitempty"`     // price_below threshold, e.g. "49.99" or "€50"
+	Percent  float64  `yaml:"percent,omitempty" json:"percent,omitempty"` // price_drop minimum drop
+	Category string   `yaml:"category,omitempty" json:"category,omitempty"`
+
+	threshold extract.Price
+}
+
+// Webhook is an endpoint alerts are POSTed to
+type Webhook struct {
+	URL string `yaml:"url" json:"url"`
+	// Secret signs the body with HMAC-SHA256 when set. Environment variables
+	// such as ${ALERT_SECRET} are expanded, so it need not be in the file.
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+}
+
+// Config holds the alert rules and where to deliver them
+type Config struct {
+	Rules    []Rule    `yaml:"rules" json:"rules"`
+	Webhooks []Webhook `yaml:"webhooks" json:"webhooks"`
+}
+
+// LoadConfig reads an alert config from a YAML or JSON file.
+// Files ending in .json are decoded as JSON, everything else as YAML.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert config: %w", err)
+	}
+
+	config := &Config{}
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse alert config %s: %w", filename, err)
+	}
+
+	for i := range config.Webhooks {
+		config.Webhooks[i].Secret = os.ExpandEnv(config.Webhooks[i].Secret)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid alert config %s: %w", filename, err)
+	}
+
+	return config, nil
+}
+
+// Validate checks the rules and webhooks and parses price thresholds
+func (c *Config) Validate() error {
+	if len(c.Rules) == 0 {
+		return errors.New("no rules")
+	}
+	if len(c.Webhooks) == 0 {
+		return errors.New("no webhooks")
+	}
+
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			rule.Name = string(rule.Type)
+		}
+		if err := rule.validate(); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	for _, hook := range c.Webhooks {
+		parsed, err := url.Parse(hook.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q", hook.URL)
+		}
+	}
+
+	return nil
+}
+
+// validate checks the settings needed by the rule's type
+func (r *Rule) validate() error {
+	switch r.Type {
+	case PriceBelow:
+		threshold, err := extract.ParsePrice(r.Below)
+		if err != nil {
+			return fmt.Errorf("invalid below price %q: %w", r.Below, err)
+		}
+		r.threshold = threshold
+	case PriceDrop:
+		if r.Percent <= 0 || r.Percent > 100 {
+			return fmt.Errorf("percent must be between 0 and 100, got %g", r.Percent)
+		}
+	case BackInStock, NewProduct:
+	default:
+		return fmt.Errorf("unknown type %q", r.Type)
+	}
+	return nil
+}
+
+// thresholdIn returns the price_below threshold in minor units of currency. A
+// threshold without currency applies to every currency.
+func (r *Rule) thresholdIn(currency string) (int64, bool) {
+	if r.threshold.Currency != "" {
+		return r.threshold.Amount, r.threshold.Currency == currency
+	}
+	threshold, err := extract.PriceFormat{Currency: currency}.Parse(r.Below)
+	if err != nil {
+		return 0, false
+	}
+	return threshold.Amount, true
+}
@@ -0,0 +1,72 @@
+package alert
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes content to a file called name in a temp dir
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("loads YAML and expands secrets", func(t *testing.T) {
+		t.Setenv("ALERT_TEST_SECRET", "s3cret")
+		path := writeConfig(t, "alerts.yaml", `
+rules:
+  - name: cheap
+    type: price_below
+    below: "€50"
+  - type: back_in_stock
+    category: Shoes
+webhooks:
+  - url: https://hooks.example/alerts
+    secret: ${ALERT_TEST_SECRET}
+`)
+
+		config, err := LoadConfig(path)
+		require.NoError(t, err)
+		require.Len(t, config.Rules, 2)
+		assert.Equal(t, "EUR", config.Rules[0].threshold.Currency)
+		assert.Equal(t, int64(5000), config.Rules[0].threshold.Amount)
+		assert.Equal(t, "back_in_stock", config.Rules[1].Name)
+		assert.Equal(t, "s3cret", config.Webhooks[0].Secret)
+	})
+
+	t.Run("loads JSON", func(t *testing.T) {
+		path := writeConfig(t, "alerts.json", `{
+			"rules": [{"type": "price_drop", "percent": 10}],
+			"webhooks": [{"url": "http://127.0.0.1:9000/"}]
+		}`)
+
+		config, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, config.Rules[0].Percent)
+	})
+
+	invalid := []struct {
+		name, content, err string
+	}{
+		{"unknown field", "rules: []\nwebhook: x\n", "field webhook not found"},
+		{"no rules", "webhooks: [{url: 'https://hooks.example/'}]\n", "no rules"},
+		{"no webhooks", "rules: [{type: new_product}]\n", "no webhooks"},
+		{"unknown type", "rules: [{type: cheaper}]\nwebhooks: [{url: 'https://hooks.example/'}]\n", `unknown type "cheaper"`},
+		{"bad threshold", "rules: [{type: price_below, below: cheap}]\nwebhooks: [{url: 'https://hooks.example/'}]\n", "invalid below price"},
+		{"bad percent", "rules: [{type: price_drop, percent: 150}]\nwebhooks: [{url: 'https://hooks.example/'}]\n", "percent must be"},
+		{"bad webhook URL", "rules: [{type: new_product}]\nwebhooks: [{url: 'hooks.example'}]\n", "invalid webhook URL"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, "alerts.yaml", tt.content))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package alert
+
+import (
+	"sort"
+	"strings"
+
+	"web-scraper/history"
+)
+
+// Alert is a product that matched a rule
+type Alert struct {
+	Rule     string   `json:"rule"`
+	Type     RuleType `json:"type"`
+	URL      string   `json:"url"`
+	Name     string   `json:"name"`
+	Category string   `json:"category,omitempty"`
+	Price    string   `json:"price"`
+	Currency string   `json:"currency,omitempty"`
+	Amount   int64    `json:"amount,omitempty"` // current price in minor units
+	OldPrice string   `json:"old_price,omitempty"`
+	Percent  float64  `json:"percent,omitempty"` // price change, negative for drops
+	InStock  *bool    `json:"in_stock,omitempty"`
+}
+
+// Evaluate returns the alerts raised by the current run, in rule order and
+// then by URL. previous is the last complete run before it, nil for the
+// first run; price_drop, back_in_stock and new_product need one, and
+// price_below only fires for products that were not below the threshold in
+// it, so a product on sale raises one alert rather than one per run.
+func Evaluate(rules []Rule, previous *history.Run, current history.Run) []Alert {
+	var diff history.Diff
+	if previous != nil {
+		diff = history.Compare(*previous, current)
+	}
+
+	var alerts []Alert
+	for i := range rules {
+		rule := &rules[i]
+		switch rule.Type {
+		case PriceBelow:
+			for _, productURL := range sortedURLs(current.Products) {
+				now := current.Products[productURL]
+				if !rule.matches(now) || !rule.below(now) {
+					continue
+				}
+				if previous != nil {
+					if was, ok := previous.Products[productURL]; ok && rule.below(was) {
+						continue
+					}
+				}
+				alerts = append(alerts, newAlert(rule, productURL, now))
+			}
+		case PriceDrop:
+			for _, change := range diff.Prices {
+				if !change.Comparable || -change.Percent < rule.Percent || !rule.matches(change.New) {
+					continue
+				}
+				alert := newAlert(rule, change.URL, change.New)
+				alert.OldPrice = change.Old.Price
+				alert.Percent = change.Percent
+				alerts = append(alerts, alert)
+			}
+		case BackInStock:
+			for _, change := range diff.Stock {
+				if now := current.Products[change.URL]; change.InStock && rule.matches(now) {
+					alerts = append(alerts, newAlert(rule, change.URL, now))
+				}
+			}
+		case NewProduct:
+			for _, product := range diff.Added {
+				if rule.matches(product.Observation) {
+					alerts = append(alerts, newAlert(rule, product.URL, product.Observation))
+				}
+			}
+		}
+	}
+	return alerts
+}
+
+// newAlert describes a product that matched rule
+func newAlert(rule *Rule, productURL string, seen history.Observation) Alert {
+	return Alert{
+		Rule:     rule.Name,
+		Type:     rule.Type,
+		URL:      productURL,
+		Name:     seen.Name,
+		Category: seen.Category,
+		Price:    seen.Price,
+		Currency: seen.Pricing.Currency,
+		Amount:   seen.Pricing.Amount,
+		InStock:  seen.InStock,
+	}
+}
+
+// matches reports whether a product is in the rule's category, if it has one
+func (r *Rule) matches(seen history.Observation) bool {
+	return r.Category == "" || strings.EqualFold(strings.TrimSpace(seen.Category), strings.TrimSpace(r.Category))
+}
+
+// below reports whether a product's parsed price is under the threshold
+func (r *Rule) below(seen history.Observation) bool {
+	if seen.Pricing.IsZero() {
+		return false
+	}
+	threshold, ok := r.thresholdIn(seen.Pricing.Currency)
+	return ok && seen.Pricing.Amount < threshold
+}
+
+// sortedURLs returns the keys of products in order
+func sortedURLs(products map[string]history.Observation) []string {
+	urls := make([]string, 0, len(products))
+	for productURL := range products {
+		urls = append(urls, productURL)
+	}
+	sort.Strings(urls)
+	return urls
+}
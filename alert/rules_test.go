@@ -0,0 +1,111 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+	"web-scraper/history"
+)
+
+// product returns an observation of a product priced in currency
+func product(name, currency string, amount int64, inStock bool, category string) history.Observation {
+	return history.Observation{
+		Name:     name,
+		Price:    extract.FormatAmount(amount, currency),
+		Pricing:  extract.Price{Currency: currency, Amount: amount},
+		InStock:  &inStock,
+		Category: category,
+	}
+}
+
+// rule returns a validated rule
+func rule(t *testing.T, r Rule) Rule {
+	t.Helper()
+	require.NoError(t, r.validate())
+	if r.Name == "" {
+		r.Name = string(r.Type)
+	}
+	return r
+}
+
+func TestEvaluate(t *testing.T) {
+	previous := history.Run{ID: 1, Products: map[string]history.Observation{
+		"https://shop.example/a": product("A", "USD", 6000, true, "Shoes"),
+		"https://shop.example/b": product("B", "USD", 10000, false, "Shoes"),
+		"https://shop.example/c": product("C", "USD", 4000, true, "Hats"),
+	}}
+	current := history.Run{ID: 2, Products: map[string]history.Observation{
+		"https://shop.example/a": product("A", "USD", 4500, true, "Shoes"),
+		"https://shop.example/b": product("B", "USD", 9500, true, "Shoes"),
+		"https://shop.example/c": product("C", "USD", 3900, true, "Hats"),
+		"https://shop.example/d": product("D", "EUR", 2000, true, "Hats"),
+	}}
+
+	urls := func(alerts []Alert) []string {
+		var result []string
+		for _, a := range alerts {
+			result = append(result, a.URL)
+		}
+		return result
+	}
+
+	t.Run("price below fires when the price crosses the threshold", func(t *testing.T) {
+		alerts := Evaluate([]Rule{rule(t, Rule{Type: PriceBelow, Below: "50.00"})}, &previous, current)
+		// C was already below, D is new
+		assert.Equal(t, []string{"https://shop.example/a", "https://shop.example/d"}, urls(alerts))
+		assert.Equal(t, int64(4500), alerts[0].Amount)
+	})
+
+	t.Run("price below in a currency ignores other currencies", func(t *testing.T) {
+		alerts := Evaluate([]Rule{rule(t, Rule{Type: PriceBelow, Below: "$50"})}, &previous, current)
+		assert.Equal(t, []string{"https://shop.example/a"}, urls(alerts))
+	})
+
+	t.Run("price below without previous run fires for all matches", func(t *testing.T) {
+		alerts := Evaluate([]Rule{rule(t, Rule{Type: PriceBelow, Below: "$50"})}, nil, current)
+		assert.Equal(t, []string{"https://shop.example/a", "https://shop.example/c"}, urls(alerts))
+	})
+
+	t.Run("price drop needs the given percentage", func(t *testing.T) {
+		alerts := Evaluate([]Rule{rule(t, Rule{Type: PriceDrop, Percent: 20})}, &previous, current)
+		require.Equal(t, []string{"https://shop.example/a"}, urls(alerts))
+		assert.Equal(t, "60.00", alerts[0].OldPrice)
+		assert.InDelta(t, -25.0, alerts[0].Percent, 0.001)
+	})
+
+	t.Run("back in stock", func(t *testing.T) {
+		alerts := Evaluate([]Rule{rule(t, Rule{Type: BackInStock})}, &previous, current)
+		assert.Equal(t, []string{"https://shop.example/b"}, urls(alerts))
+	})
+
+	t.Run("new product in category", func(t *testing.T) {
+		alerts := Evaluate([]Rule{rule(t, Rule{Type: NewProduct, Category: "hats"})}, &previous, current)
+		assert.Equal(t, []string{"https://shop.example/d"}, urls(alerts))
+
+		alerts = Evaluate([]Rule{rule(t, Rule{Type: NewProduct, Category: "Shoes"})}, &previous, current)
+		assert.Empty(t, alerts)
+	})
+
+	t.Run("comparison rules need a previous run", func(t *testing.T) {
+		rules := []Rule{
+			rule(t, Rule{Type: PriceDrop, Percent: 1}),
+			rule(t, Rule{Type: BackInStock}),
+			rule(t, Rule{Type: NewProduct}),
+		}
+		assert.Empty(t, Evaluate(rules, nil, current))
+	})
+
+	t.Run("alerts follow rule order", func(t *testing.T) {
+		rules := []Rule{
+			rule(t, Rule{Name: "restock", Type: BackInStock}),
+			rule(t, Rule{Name: "drop", Type: PriceDrop, Percent: 5}),
+		}
+		alerts := Evaluate(rules, &previous, current)
+		require.Len(t, alerts, 3)
+		assert.Equal(t, "restock", alerts[0].Rule)
+		assert.Equal(t, "drop", alerts[1].Rule)
+		assert.Equal(t, PriceDrop, alerts[2].Type)
+	})
+}
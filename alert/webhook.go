@@ -0,0 +1,176 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"web-scraper/crawler"
+	"web-scraper/history"
+)
+
+// Headers set on every webhook request
+const (
+	SignatureHeader = "X-Scraper-Signature" // "sha256=" and the hex HMAC-SHA256 of the body
+	DeliveryHeader  = "X-Scraper-Delivery"  // random id, the same for every attempt of a delivery
+)
+
+// Payload is the JSON body POSTed to webhooks, one per run with alerts
+type Payload struct {
+	RunID         int       `json:"run_id"`
+	PreviousRunID int       `json:"previous_run_id,omitempty"`
+	StartURL      string    `json:"start_url"`
+	SentAt        time.Time `json:"sent_at"`
+	Alerts        []Alert   `json:"alerts"`
+}
+
+// Sign returns the signature header value of body for secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the signature of body for secret.
+// Receivers use it to check a delivery came from the scraper.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Sender delivers payloads to webhooks, retrying failed deliveries
+type Sender struct {
+	Client *http.Client
+	Retry  crawler.RetryPolicy
+}
+
+// NewSender returns a Sender with a 10 second timeout per attempt and the
+// crawler's default retry policy
+func NewSender() *Sender {
+	return &Sender{
+		Client: &http.Client{Timeout: 10 * time.Second},
+		Retry:  crawler.DefaultRetryPolicy(),
+	}
+}
+
+// Send POSTs payload to hook. Network errors, 408, 429 and 5xx responses are
+// retried with backoff, honouring Retry-After; any other non-2xx status fails
+// straight away.
+func (s *Sender) Send(ctx context.Context, hook Webhook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+	delivery, err := newDeliveryID()
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		status, headers, err := s.post(ctx, hook, body, delivery)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || attempt >= s.Retry.MaxAttempts || !retryableDelivery(status) {
+			return fmt.Errorf("webhook %s: %w", hook.URL, err)
+		}
+
+		delay, ok := s.Retry.Delay(attempt, headers)
+		if !ok {
+			return fmt.Errorf("webhook %s: %w (Retry-After too long)", hook.URL, err)
+		}
+		log.Printf("[ALERT] Delivery to %s failed (%v), retry %d in %s", hook.URL, err, attempt, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("webhook %s: %w", hook.URL, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// post makes one delivery attempt. It returns the response status, 0 when
+// none was received, and headers for Retry-After.
+func (s *Sender) post(ctx context.Context, hook Webhook, body []byte, delivery string) (int, *http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "web-scraper-alerts/1.0")
+	req.Header.Set(DeliveryHeader, delivery)
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, &resp.Header, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil, nil
+}
+
+// retryableDelivery reports whether a delivery that got status may succeed
+// later. Unlike page fetches, any network error and 5xx is worth retrying.
+func retryableDelivery(status int) bool {
+	return status == 0 || status == http.StatusRequestTimeout ||
+		status == http.StatusTooManyRequests || status >= 500
+}
+
+// newDeliveryID returns a random delivery id
+func newDeliveryID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate delivery id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// Notify evaluates the rules of config for the current run and sends any
+// alerts to every webhook. It returns the alerts, and the errors of the
+// webhooks that could not be reached.
+func Notify(ctx context.Context, sender *Sender, config *Config, previous *history.Run, current history.Run) ([]Alert, error) {
+	alerts := Evaluate(config.Rules, previous, current)
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+
+	payload := Payload{
+		RunID:    current.ID,
+		StartURL: current.StartURL,
+		SentAt:   time.Now().UTC(),
+		Alerts:   alerts,
+	}
+	if previous != nil {
+		payload.PreviousRunID = previous.ID
+	}
+
+	var errs []error
+	for _, hook := range config.Webhooks {
+		if err := sender.Send(ctx, hook, payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return alerts, errors.Join(errs...)
+}
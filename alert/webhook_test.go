@@ -0,0 +1,181 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/crawler"
+	"web-scraper/history"
+)
+
+// delivery is a request received by a receiver
+type delivery struct {
+	body    []byte
+	headers http.Header
+}
+
+// receiver is a webhook endpoint answering with the queued statuses, then 204
+type receiver struct {
+	*httptest.Server
+	mu         sync.Mutex
+	statuses   []int
+	deliveries []delivery
+}
+
+func newReceiver(statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		r.deliveries = append(r.deliveries, delivery{body: body, headers: req.Header.Clone()})
+		status := http.StatusNoContent
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	return r
+}
+
+func (r *receiver) received() []delivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]delivery(nil), r.deliveries...)
+}
+
+// testSender retries quickly
+func testSender() *Sender {
+	return &Sender{
+		Client: &http.Client{Timeout: time.Second},
+		Retry:  crawler.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"run_id":1}`)
+	signature := Sign("s3cret", body)
+
+	assert.Regexp(t, `^sha256=[0-9a-f]{64}$`, signature)
+	assert.True(t, Verify("s3cret", body, signature))
+	assert.False(t, Verify("other", body, signature))
+	assert.False(t, Verify("s3cret", []byte(`{"run_id":2}`), signature))
+}
+
+func TestSend(t *testing.T) {
+	payload := Payload{RunID: 2, StartURL: "https://shop.example/", Alerts: []Alert{{Rule: "drop", URL: "https://shop.example/a"}}}
+
+	t.Run("posts a signed payload", func(t *testing.T) {
+		server := newReceiver()
+		defer server.Close()
+
+		require.NoError(t, testSender().Send(context.Background(), Webhook{URL: server.URL, Secret: "s3cret"}, payload))
+
+		received := server.received()
+		require.Len(t, received, 1)
+		assert.Equal(t, "application/json", received[0].headers.Get("Content-Type"))
+		assert.True(t, Verify("s3cret", received[0].body, received[0].headers.Get(SignatureHeader)))
+
+		var got Payload
+		require.NoError(t, json.Unmarshal(received[0].body, &got))
+		assert.Equal(t, 2, got.RunID)
+		assert.Equal(t, "drop", got.Alerts[0].Rule)
+	})
+
+	t.Run("unsigned without secret", func(t *testing.T) {
+		server := newReceiver()
+		defer server.Close()
+
+		require.NoError(t, testSender().Send(context.Background(), Webhook{URL: server.URL}, payload))
+		assert.Empty(t, server.received()[0].headers.Get(SignatureHeader))
+	})
+
+	t.Run("retries server errors with the same delivery id", func(t *testing.T) {
+		server := newReceiver(http.StatusInternalServerError, http.StatusTooManyRequests)
+		defer server.Close()
+
+		require.NoError(t, testSender().Send(context.Background(), Webhook{URL: server.URL}, payload))
+
+		received := server.received()
+		require.Len(t, received, 3)
+		id := received[0].headers.Get(DeliveryHeader)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, id, received[2].headers.Get(DeliveryHeader))
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		server := newReceiver(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		defer server.Close()
+
+		err := testSender().Send(context.Background(), Webhook{URL: server.URL}, payload)
+		assert.ErrorContains(t, err, "502")
+		assert.Len(t, server.received(), 3)
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		server := newReceiver(http.StatusBadRequest)
+		defer server.Close()
+
+		err := testSender().Send(context.Background(), Webhook{URL: server.URL}, payload)
+		assert.ErrorContains(t, err, "400")
+		assert.Len(t, server.received(), 1)
+	})
+
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		server := newReceiver(http.StatusServiceUnavailable)
+		defer server.Close()
+		sender := testSender()
+		sender.Retry.BaseDelay = time.Hour
+		sender.Retry.MaxDelay = time.Hour
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := sender.Send(ctx, Webhook{URL: server.URL}, payload)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestNotify(t *testing.T) {
+	server := newReceiver()
+	defer server.Close()
+
+	config := &Config{
+		Rules:    []Rule{{Type: NewProduct}},
+		Webhooks: []Webhook{{URL: server.URL}},
+	}
+	require.NoError(t, config.Validate())
+
+	previous := history.Run{ID: 1, Products: map[string]history.Observation{}}
+	current := history.Run{ID: 2, StartURL: "https://shop.example/", Products: map[string]history.Observation{
+		"https://shop.example/a": {Name: "A", Price: "$1.00"},
+	}}
+
+	t.Run("sends alerts", func(t *testing.T) {
+		alerts, err := Notify(context.Background(), testSender(), config, &previous, current)
+		require.NoError(t, err)
+		require.Len(t, alerts, 1)
+
+		var got Payload
+		require.NoError(t, json.Unmarshal(server.received()[0].body, &got))
+		assert.Equal(t, 1, got.PreviousRunID)
+		assert.Equal(t, "https://shop.example/", got.StartURL)
+		assert.Equal(t, "new_product", got.Alerts[0].Rule)
+	})
+
+	t.Run("sends nothing without alerts", func(t *testing.T) {
+		alerts, err := Notify(context.Background(), testSender(), config, &current, current)
+		require.NoError(t, err)
+		assert.Empty(t, alerts)
+		assert.Len(t, server.received(), 1)
+	})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+
+	"web-scraper/alert"
+	"web-scraper/history"
+)
+
+// addAlertsFlag registers the -alerts flag on fs
+func addAlertsFlag(fs *flag.FlagSet) *string {
+	return fs.String("alerts", "", "alert rules and webhooks (YAML or JSON) evaluated after each run, needs -history")
+}
+
+// loadAlerts loads the -alerts config, nil when the flag is empty. Rules
+// compare the run with the previous one, so they need a history.
+func loadAlerts(path, historyPath string, stderr io.Writer) (*alert.Config, bool) {
+	if path == "" {
+		return nil, true
+	}
+	if historyPath == "" {
+		fmt.Fprintln(stderr, "-alerts needs -history to compare runs")
+		return nil, false
+	}
+	config, err := alert.LoadConfig(path)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, false
+	}
+	return config, true
+}
+
+// sendAlerts evaluates the alert rules for the run recorder saved and
+// delivers any alerts. It reports false when a webhook could not be reached.
+func sendAlerts(config *alert.Config, historyPath string, recorder *history.Recorder) bool {
+	if config == nil {
+		return true
+	}
+
+	store, err := history.Open(historyPath)
+	if err != nil {
+		log.Printf("Failed to open history: %v", err)
+		return false
+	}
+	current, ok := store.Run(recorder.RunID())
+	if !ok {
+		log.Printf("Run %d is missing from the history", recorder.RunID())
+		return false
+	}
+	var previous *history.Run
+	if run, ok := store.Previous(current.ID); ok {
+		previous = &run
+	}
+
+	alerts, err := alert.Notify(context.Background(), alert.NewSender(), config, previous, current)
+	if err != nil {
+		log.Printf("Failed to deliver alerts: %v", err)
+		return false
+	}
+	if len(alerts) > 0 {
+		fmt.Printf("Sent %d alerts\n", len(alerts))
+	}
+	return true
+}
+
+// alertsExitCode fails an otherwise successful run whose alerts could not be
+// delivered
+func alertsExitCode(code int, delivered bool) int {
+	if !delivered && (code == exitOK || code == exitPartial) {
+		return exitFailure
+	}
+	return code
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/alert"
+	"web-scraper/internal/testutil"
+)
+
+func TestAlertsFlag(t *testing.T) {
+	var mu sync.Mutex
+	var payloads []alert.Payload
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !alert.Verify("s3cret", body, r.Header.Get(alert.SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload alert.Payload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	dir := t.TempDir()
+	t.Setenv("TEST_ALERT_SECRET", "s3cret")
+	config := filepath.Join(dir, "alerts.yaml")
+	require.NoError(t, os.WriteFile(config, []byte(`
+rules:
+  - name: big-drop
+    type: price_drop
+    percent: 10
+  - name: restock
+    type: back_in_stock
+    category: Test Category
+webhooks:
+  - url: `+receiver.URL+`
+    secret: ${TEST_ALERT_SECRET}
+`), 0o644))
+
+	server := testutil.CreateShopServer(t)
+	defer server.Close()
+	historyPath := filepath.Join(dir, "history.json")
+	deepScrape := func(extra ...string) int {
+		args := []string{
+			"deep-scrape", "-url", server.URL + "/", "-output", filepath.Join(dir, "products.json"),
+			"-delay", "0", "-detail-delay", "0", "-cache-dir", "", "-ignore-robots", "-no-checkpoint",
+		}
+		return run(append(args, extra...), io.Discard)
+	}
+
+	t.Run("needs a history", func(t *testing.T) {
+		assert.Equal(t, exitUsage, deepScrape("-alerts", config))
+	})
+
+	t.Run("rejects an invalid config", func(t *testing.T) {
+		invalid := filepath.Join(dir, "invalid.yaml")
+		require.NoError(t, os.WriteFile(invalid, []byte("rules: []\n"), 0o644))
+		assert.Equal(t, exitUsage, deepScrape("-history", historyPath, "-alerts", invalid))
+	})
+
+	t.Run("posts alerts for changes since the previous run", func(t *testing.T) {
+		product := testutil.MustGetFixture(t, "product.html")
+		server.Route("/product/test-product-1", testutil.MustGetFixture(t, "product_out_of_stock.html"))
+		require.Equal(t, exitOK, deepScrape("-history", historyPath, "-alerts", config))
+		mu.Lock()
+		assert.Empty(t, payloads, "the first run has nothing to compare with")
+		mu.Unlock()
+
+		server.Route("/product/test-product-1", product)
+		server.Route("/product/test-product-2", strings.Replace(product, "$99.99", "$79.99", 1))
+		require.Equal(t, exitOK, deepScrape("-history", historyPath, "-alerts", config))
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, payloads, 1)
+		payload := payloads[0]
+		assert.Equal(t, 2, payload.RunID)
+		assert.Equal(t, 1, payload.PreviousRunID)
+		require.Len(t, payload.Alerts, 2)
+		assert.Equal(t, "big-drop", payload.Alerts[0].Rule)
+		assert.Equal(t, server.URL+"/product/test-product-2", payload.Alerts[0].URL)
+		assert.Equal(t, "restock", payload.Alerts[1].Rule)
+		assert.Equal(t, server.URL+"/product/test-product-1", payload.Alerts[1].URL)
+	})
+}
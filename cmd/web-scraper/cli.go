@@ -0,0 +1,900 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"web-scraper/crawler"
+	"web-scraper/export"
+	"web-scraper/extract"
+	"web-scraper/history"
+)
+
+// Exit codes returned by the command line interface
+const (
+	exitOK      = 0 // every request succeeded
+	exitFailure = 1 // the run could not complete or produced nothing
+	exitUsage   = 2 // invalid subcommand or flags
+	exitPartial = 3 // results were written but some requests failed
+
+	exitInterrupted = 130 // stopped by SIGINT/SIGTERM, partial results were written
+)
+
+// runOptions holds the flags shared by every subcommand
+type runOptions struct {
+	StartURL       string
+	AllowedDomains []string
+	Output         string
+	Parallelism    int
+	Delay          time.Duration
+	Depth          int
+	CacheDir       string
+	Profile        *extract.SiteProfile
+	IgnoreRobots   bool
+	Retries        int // retries per failed request on top of the first attempt
+	DrainTimeout   time.Duration
+}
+
+// commandDefaults describes the default flag values for a subcommand
+type commandDefaults struct {
+	StartURL    string
+	Output      string
+	Parallelism int
+	Delay       time.Duration
+	Depth       int
+	CacheDir    string
+}
+
+const usageText = `Usage: web-scraper <command> [flags]
+
+Commands:
+  scrape        Scrape a product listing and export it to CSV
+  crawl         Crawl a site and record every discovered link or page
+  deep-scrape   Scrape listings and product detail pages and export to JSON or CSV
+  diff          Compare two runs recorded with -history
+
+Run "web-scraper <command> -h" for the flags of a command.
+`
+
+// run dispatches the subcommand in args and returns the process exit code
+func run(args []string, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usageText)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "scrape":
+		return runScrapeCommand(args[1:], stderr)
+	case "crawl":
+		return runCrawlCommand(args[1:], stderr)
+	case "deep-scrape":
+		return runDeepScrapeCommand(args[1:], stderr)
+	case "diff":
+		return runDiffCommand(args[1:], os.Stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stderr, usageText)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usageText)
+		return exitUsage
+	}
+}
+
+// newFlagSet creates a flag set with the flags shared by all subcommands
+func newFlagSet(name string, defaults commandDefaults, stderr io.Writer) (*flag.FlagSet, *runOptions, *string) {
+	opts := &runOptions{}
+	domains := new(string)
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&opts.StartURL, "url", defaults.StartURL, "start URL")
+	fs.StringVar(domains, "domains", "", "comma-separated allowed domains (default: host of -url)")
+	fs.StringVar(&opts.Output, "output", defaults.Output, "output file path")
+	fs.IntVar(&opts.Parallelism, "parallelism", defaults.Parallelism, "maximum concurrent requests per domain")
+	fs.DurationVar(&opts.Delay, "delay", defaults.Delay, "delay between requests to the same domain")
+	fs.IntVar(&opts.Depth, "depth", defaults.Depth, "maximum link depth to follow")
+	fs.StringVar(&opts.CacheDir, "cache-dir", defaults.CacheDir, "response cache directory (empty disables caching)")
+	fs.BoolVar(&opts.IgnoreRobots, "ignore-robots", false, "do not fetch or obey robots.txt")
+	fs.DurationVar(&opts.DrainTimeout, "drain-timeout", crawler.DefaultDrainTimeout, "how long to wait for in-flight requests after Ctrl-C")
+	fs.IntVar(&opts.Retries, "retries", crawler.DefaultRetryPolicy().MaxAttempts-1, "retries for throttled (429/503) and transient failures")
+
+	return fs, opts, domains
+}
+
+// finishOptions validates parsed flags and fills in derived values
+func finishOptions(opts *runOptions, domains string) error {
+	parsed, err := url.Parse(opts.StartURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("invalid start URL %q", opts.StartURL)
+	}
+
+	opts.AllowedDomains = splitList(domains)
+	if len(opts.AllowedDomains) == 0 {
+		opts.AllowedDomains = []string{parsed.Hostname()}
+	}
+
+	if opts.Output == "" {
+		return errors.New("output path must not be empty")
+	}
+	if opts.Parallelism < 1 {
+		return errors.New("parallelism must be at least 1")
+	}
+	if opts.Delay < 0 {
+		return errors.New("delay must not be negative")
+	}
+	if opts.Depth < 0 {
+		return errors.New("depth must not be negative")
+	}
+	if opts.Retries < 0 {
+		return errors.New("retries must not be negative")
+	}
+	if opts.DrainTimeout < 0 {
+		return errors.New("drain-timeout must not be negative")
+	}
+
+	return nil
+}
+
+// parseFlags parses args into fs and reports the exit code to use on failure
+func parseFlags(fs *flag.FlagSet, args []string, opts *runOptions, domains *string, stderr io.Writer) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return exitUsage, false
+	}
+
+	if err := finishOptions(opts, *domains); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", fs.Name(), err)
+		return exitUsage, false
+	}
+
+	return exitOK, true
+}
+
+// retryPolicy returns the default retry policy limited to opts.Retries retries
+func (opts runOptions) retryPolicy() crawler.RetryPolicy {
+	policy := crawler.DefaultRetryPolicy()
+	policy.MaxAttempts = opts.Retries + 1
+	return policy
+}
+
+// addProfileFlag registers the -profile flag on fs
+func addProfileFlag(fs *flag.FlagSet) *string {
+	return fs.String("profile", "woocommerce", `site profile file (YAML or JSON), or "woocommerce" for the built-in profile`)
+}
+
+// loadProfileFlag resolves the -profile flag into opts
+func loadProfileFlag(opts *runOptions, profile string, stderr io.Writer) bool {
+	p, err := extract.ResolveSiteProfile(profile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return false
+	}
+	opts.Profile = p
+	return true
+}
+
+// addHistoryFlag registers the -history flag on fs
+func addHistoryFlag(fs *flag.FlagSet) *string {
+	return fs.String("history", "", "history file to record the price and stock of this run in")
+}
+
+// addImagesFlag registers the -images flag on fs
+func addImagesFlag(fs *flag.FlagSet) *string {
+	return fs.String("images", "", "directory to download product images into (default: keep image URLs only)")
+}
+
+// addCookiesFlag registers the -cookies flag on fs
+func addCookiesFlag(fs *flag.FlagSet) *string {
+	return fs.String("cookies", "", "cookie file to seed the run from and save its cookies to (JSON, or a Netscape cookies.txt)")
+}
+
+// loadCookies loads the cookie jar of the -cookies flag, nil when it is empty
+func loadCookies(filename string, stderr io.Writer) (*crawler.CookieJar, bool) {
+	if filename == "" {
+		return nil, true
+	}
+	jar, err := crawler.LoadCookieJar(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "-cookies: %v\n", err)
+		return nil, false
+	}
+	return jar, true
+}
+
+// saveCookies saves jar to filename at the end of a run, even a failed one.
+// A failure is only logged, as the scraped data is still good.
+func saveCookies(jar *crawler.CookieJar, filename string) {
+	if jar == nil {
+		return
+	}
+	if err := jar.Save(filename); err != nil {
+		log.Printf("[COOKIES] %v", err)
+		return
+	}
+	log.Printf("[COOKIES] Saved %d cookies to %s", len(jar.Cookies()), filename)
+}
+
+// openImages opens the image store in dir, nil when dir is empty
+func openImages(dir string) (*export.ImageStore, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	return export.NewImageStore(dir)
+}
+
+// openHistory starts recording the run in the history at path, nil when path
+// is empty. A resumed run continues the interrupted one it resumes.
+func openHistory(path, startURL string, resume bool) (*history.Recorder, error) {
+	if path == "" {
+		return nil, nil
+	}
+	store, err := history.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if resume {
+		return store.Resume(startURL), nil
+	}
+	return store.Record(startURL), nil
+}
+
+// saveHistory adds the run to the history, if any, marked as incomplete when
+// it was stopped or failed. Products the scraper kept in memory are recorded
+// here, streamed ones were recorded as they came in.
+func saveHistory[T any](recorder *history.Recorder, products []T, stopped, failed bool) bool {
+	if recorder == nil {
+		return true
+	}
+	for _, product := range products {
+		if err := recorder.Export(product); err != nil {
+			log.Printf("Failed to record history: %v", err)
+			return false
+		}
+	}
+	if stopped {
+		recorder.Interrupted()
+	}
+	if failed {
+		recorder.Failed()
+	}
+	if err := recorder.Close(); err != nil {
+		log.Printf("Failed to save history: %v", err)
+		return false
+	}
+	fmt.Printf("Run %d recorded in the history\n", recorder.RunID())
+	return true
+}
+
+// checkpointFlags holds the checkpoint flags of the resumable subcommands
+type checkpointFlags struct {
+	path     *string
+	interval *time.Duration
+	disable  *bool
+	resume   *bool
+}
+
+// addCheckpointFlags registers the checkpoint and -resume flags on fs
+func addCheckpointFlags(fs *flag.FlagSet) *checkpointFlags {
+	return &checkpointFlags{
+		path:     fs.String("checkpoint", "", "checkpoint file (default: <output>.checkpoint.json)"),
+		interval: fs.Duration("checkpoint-interval", crawler.DefaultCheckpointInterval, "how often to save the checkpoint"),
+		disable:  fs.Bool("no-checkpoint", false, "do not save checkpoints"),
+		resume:   fs.Bool("resume", false, "continue from the last checkpoint without refetching completed URLs"),
+	}
+}
+
+// resolve returns the checkpoint file for a run writing to output, empty when
+// checkpoints are disabled
+func (f *checkpointFlags) resolve(output string) (string, error) {
+	if *f.interval <= 0 {
+		return "", errors.New("checkpoint-interval must be positive")
+	}
+	if *f.disable {
+		if *f.resume {
+			return "", errors.New("-resume cannot be combined with -no-checkpoint")
+		}
+		return "", nil
+	}
+	if *f.path != "" {
+		return *f.path, nil
+	}
+	return output + ".checkpoint.json", nil
+}
+
+// runScrapeCommand implements the "scrape" subcommand using the basic collector
+func runScrapeCommand(args []string, stderr io.Writer) int {
+	fs, opts, domains := newFlagSet("scrape", commandDefaults{
+		StartURL:    "https://scrapingcourse.com/ecommerce/",
+		Output:      "products.csv",
+		Parallelism: 2,
+		Delay:       1 * time.Second,
+		Depth:       2,
+		CacheDir:    "./cache",
+	}, stderr)
+	profile := addProfileFlag(fs)
+	historyPath := addHistoryFlag(fs)
+	alertsPath := addAlertsFlag(fs)
+	imagesDir := addImagesFlag(fs)
+	validation := addValidationFlags(fs)
+	proxies := addProxyFlags(fs, false)
+	cookiesPath := addCookiesFlag(fs)
+	if code, ok := parseFlags(fs, args, opts, domains, stderr); !ok {
+		return code
+	}
+	if !loadProfileFlag(opts, *profile, stderr) {
+		return exitUsage
+	}
+	alerts, ok := loadAlerts(*alertsPath, *historyPath, stderr)
+	if !ok {
+		return exitUsage
+	}
+	rules, ok := validation.loadRules(stderr)
+	if !ok {
+		return exitUsage
+	}
+	pools, ok := proxies.pools(stderr)
+	if !ok {
+		return exitUsage
+	}
+	cookies, ok := loadCookies(*cookiesPath, stderr)
+	if !ok {
+		return exitUsage
+	}
+	if !proxies.check(pools, opts.StartURL) {
+		return exitFailure
+	}
+
+	images, err := openImages(*imagesDir)
+	if err != nil {
+		log.Printf("Failed to open image directory: %v", err)
+		return exitFailure
+	}
+	checks, err := validation.open(rules, opts.Output, false)
+	if err != nil {
+		log.Printf("Failed to open rejects file: %v", err)
+		return exitFailure
+	}
+	stream, err := openStream(opts.Output, false)
+	if err != nil {
+		log.Printf("Failed to open output: %v", err)
+		return exitFailure
+	}
+	recorder, err := openHistory(*historyPath, opts.StartURL, false)
+	if err != nil {
+		stream.close()
+		log.Printf("Failed to open history: %v", err)
+		return exitFailure
+	}
+
+	ls := crawler.NewListingScraperWithConfig(crawler.ScraperConfig{
+		AllowedDomains:  opts.AllowedDomains,
+		MaxDepth:        opts.Depth,
+		Parallelism:     opts.Parallelism,
+		Delay:           opts.Delay,
+		CacheDir:        opts.CacheDir,
+		Profile:         opts.Profile,
+		IgnoreRobotsTxt: opts.IgnoreRobots,
+		Retry:           opts.retryPolicy(),
+		DrainTimeout:    opts.DrainTimeout,
+		Exporter:        stream.target(recorder),
+		Images:          images,
+		Validator:       checks.validator,
+		Proxies:         pools.listing,
+		Cookies:         cookies,
+	})
+
+	release := interruptHandler(ls.Stop)
+	err = ls.Scrape(opts.StartURL)
+	release()
+	saveCookies(cookies, *cookiesPath)
+	pools.print()
+	validated := checks.finish()
+	if err != nil {
+		stream.close()
+		log.Printf("Scraping failed: %v", err)
+		saveHistory(recorder, ls.GetProducts(), false, true)
+		return exitFailure
+	}
+	if !validated {
+		stream.close()
+		saveHistory(recorder, ls.GetProducts(), false, true)
+		return exitFailure
+	}
+
+	code := exitCode(ls.GetProductCount(), ls.GetFailedRequests())
+	products := ls.GetProducts()
+	if !saveHistory(recorder, products, ls.Stopped(), code == exitFailure) {
+		stream.close()
+		return exitFailure
+	}
+	delivered := ls.Stopped() || code == exitFailure || sendAlerts(alerts, *historyPath, recorder)
+	if stream != nil {
+		return alertsExitCode(stream.finish(opts.Output, ls.Stopped(), "", code), delivered)
+	}
+
+	// Interrupted runs always write their partial export, even when empty
+	output := exportPath(opts.Output, ls.Stopped())
+	if len(products) > 0 || ls.Stopped() {
+		if err := export.WriteCSV(products, output); err != nil {
+			log.Printf("Failed to export to CSV: %v", err)
+			return exitFailure
+		}
+		fmt.Printf("\nScraping complete! Found %d products.\n", len(products))
+		fmt.Printf("Data exported to %s\n", output)
+		removeStalePartial(opts.Output, output)
+	} else {
+		fmt.Println("No products found.")
+	}
+
+	if ls.Stopped() {
+		return interrupted(output, "")
+	}
+	return alertsExitCode(code, delivered)
+}
+
+// runCrawlCommand implements the "crawl" subcommand using WebCrawler. The
+// output's extension picks what is written: the page records for ".csv",
+// ".json", ".jsonl", ".db" and ".sqlite", and the discovered links otherwise.
+func runCrawlCommand(args []string, stderr io.Writer) int {
+	fs, opts, domains := newFlagSet("crawl", commandDefaults{
+		StartURL:    "https://go-colly.org/",
+		Output:      "links.txt",
+		Parallelism: 1,
+		Depth:       3,
+	}, stderr)
+	maxPages := fs.Int("max-pages", 10, "maximum number of pages to visit")
+	ignoreSitemaps := fs.Bool("ignore-sitemaps", false, "do not seed the crawl from sitemap.xml")
+	stripParams := fs.String("strip-params", "", `comma-separated query parameters to strip from links, * matching any suffix, or "none" (default: tracking and session parameters)`)
+	trailingSlash := fs.String("trailing-slash", "", `trailing slash policy for links: "strip" or "add" (default: keep)`)
+	browsers := fs.String("browsers", "", "comma-separated browser profiles to pose as (default: all of "+strings.Join(extract.BrowserNames(), ", ")+")")
+	rotateHeaders := fs.String("rotate-headers", "", `pick a browser profile per "session" (default) or per "domain"`)
+	checkpoint := addCheckpointFlags(fs)
+	if code, ok := parseFlags(fs, args, opts, domains, stderr); !ok {
+		return code
+	}
+	urls := urlPolicy(*stripParams, *trailingSlash)
+	if err := urls.Validate(); err != nil {
+		fmt.Fprintf(stderr, "crawl: %v\n", err)
+		return exitUsage
+	}
+	headers := extract.HeaderPolicy{Browsers: splitList(*browsers), Rotate: extract.Rotation(*rotateHeaders)}
+	if err := headers.Validate(); err != nil {
+		fmt.Fprintf(stderr, "crawl: %v\n", err)
+		return exitUsage
+	}
+	checkpointPath, err := checkpoint.resolve(opts.Output)
+	if err != nil {
+		fmt.Fprintf(stderr, "crawl: %v\n", err)
+		return exitUsage
+	}
+
+	stream, err := openStream(opts.Output, *checkpoint.resume)
+	if err != nil {
+		log.Printf("Failed to open output: %v", err)
+		return exitFailure
+	}
+
+	wc := crawler.NewWebCrawlerWithConfig(crawler.CrawlerConfig{
+		AllowedDomains:     opts.AllowedDomains,
+		MaxPages:           *maxPages,
+		MaxDepth:           opts.Depth,
+		Parallelism:        opts.Parallelism,
+		Delay:              opts.Delay,
+		IgnoreRobotsTxt:    opts.IgnoreRobots,
+		IgnoreSitemaps:     *ignoreSitemaps,
+		URLs:               urls,
+		Headers:            headers,
+		Retry:              opts.retryPolicy(),
+		DrainTimeout:       opts.DrainTimeout,
+		CheckpointPath:     checkpointPath,
+		CheckpointInterval: *checkpoint.interval,
+		Resume:             *checkpoint.resume,
+		Exporter:           stream.target(nil),
+	})
+
+	fmt.Printf("Crawling %s (max %d pages)\n\n", opts.StartURL, *maxPages)
+	release := interruptHandler(wc.Stop)
+	err = wc.Crawl(opts.StartURL)
+	release()
+	if err != nil {
+		stream.close()
+		log.Printf("Crawling failed: %v", err)
+		return exitFailure
+	}
+
+	links := wc.GetFoundLinks()
+	fmt.Printf("\n=== Crawl Results ===\n")
+	fmt.Printf("Pages visited: %d\n", wc.GetPagesVisited())
+	fmt.Printf("Links discovered: %d\n", len(links))
+	fmt.Printf("Sitemap URLs: %d\n", len(wc.GetSitemapURLs()))
+	printBlocked(wc.GetBlockedURLs())
+
+	failed := wc.GetFailedRequests()
+	code := exitCode(wc.GetPagesVisited()-failed, failed)
+	if stream != nil {
+		return stream.finish(opts.Output, wc.Stopped(), checkpointPath, code)
+	}
+
+	output := exportPath(opts.Output, wc.Stopped())
+	if err := writeCrawlOutput(wc, output); err != nil {
+		log.Printf("Failed to write %s: %v", output, err)
+		return exitFailure
+	}
+	removeStalePartial(opts.Output, output)
+
+	if wc.Stopped() {
+		return interrupted(output, checkpointPath)
+	}
+	return code
+}
+
+// urlPolicy builds the URL policy of the -strip-params and -trailing-slash
+// flags
+func urlPolicy(stripParams, trailingSlash string) extract.URLPolicy {
+	policy := extract.URLPolicy{TrailingSlash: extract.TrailingSlash(trailingSlash)}
+	switch stripParams {
+	case "":
+	case "none":
+		policy.StripParams = []string{}
+	default:
+		policy.StripParams = splitList(stripParams)
+	}
+	return policy
+}
+
+// writeCrawlOutput writes the page records of wc to a ".csv" or ".json"
+// output, and its links to any other
+func writeCrawlOutput(wc *crawler.WebCrawler, output string) error {
+	var err error
+	switch filepath.Ext(output) {
+	case ".csv":
+		err = export.WritePagesCSV(wc.GetPages(), output)
+	case ".json":
+		err = export.WritePagesJSON(wc.GetPages(), output)
+	default:
+		if err := export.WriteLinks(wc.GetFoundLinks(), output); err != nil {
+			return err
+		}
+		fmt.Printf("Links written to %s\n", output)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Pages written to %s\n", output)
+	return nil
+}
+
+// runDeepScrapeCommand implements the "deep-scrape" subcommand using Scraper
+func runDeepScrapeCommand(args []string, stderr io.Writer) int {
+	defaults := crawler.DefaultScraperConfig(nil)
+	fs, opts, domains := newFlagSet("deep-scrape", commandDefaults{
+		StartURL:    "https://scrapingcourse.com/ecommerce/",
+		Output:      "products_detailed.json",
+		Parallelism: defaults.Parallelism,
+		Delay:       defaults.Delay,
+		Depth:       defaults.MaxDepth,
+		CacheDir:    defaults.CacheDir,
+	}, stderr)
+	detailParallelism := fs.Int("detail-parallelism", defaults.DetailParallelism, "maximum concurrent product detail requests")
+	detailDelay := fs.Duration("detail-delay", defaults.DetailDelay, "delay between product detail requests")
+	profile := addProfileFlag(fs)
+	historyPath := addHistoryFlag(fs)
+	alertsPath := addAlertsFlag(fs)
+	imagesDir := addImagesFlag(fs)
+	validation := addValidationFlags(fs)
+	proxies := addProxyFlags(fs, true)
+	cookiesPath := addCookiesFlag(fs)
+	checkpoint := addCheckpointFlags(fs)
+	if code, ok := parseFlags(fs, args, opts, domains, stderr); !ok {
+		return code
+	}
+	if !loadProfileFlag(opts, *profile, stderr) {
+		return exitUsage
+	}
+	alerts, ok := loadAlerts(*alertsPath, *historyPath, stderr)
+	if !ok {
+		return exitUsage
+	}
+	if *detailParallelism < 1 {
+		fmt.Fprintln(stderr, "deep-scrape: detail-parallelism must be at least 1")
+		return exitUsage
+	}
+	checkpointPath, err := checkpoint.resolve(opts.Output)
+	if err != nil {
+		fmt.Fprintf(stderr, "deep-scrape: %v\n", err)
+		return exitUsage
+	}
+	rules, ok := validation.loadRules(stderr)
+	if !ok {
+		return exitUsage
+	}
+	pools, ok := proxies.pools(stderr)
+	if !ok {
+		return exitUsage
+	}
+	cookies, ok := loadCookies(*cookiesPath, stderr)
+	if !ok {
+		return exitUsage
+	}
+	if !proxies.check(pools, opts.StartURL) {
+		return exitFailure
+	}
+
+	images, err := openImages(*imagesDir)
+	if err != nil {
+		log.Printf("Failed to open image directory: %v", err)
+		return exitFailure
+	}
+	checks, err := validation.open(rules, opts.Output, *checkpoint.resume)
+	if err != nil {
+		log.Printf("Failed to open rejects file: %v", err)
+		return exitFailure
+	}
+	stream, err := openStream(opts.Output, *checkpoint.resume)
+	if err != nil {
+		log.Printf("Failed to open output: %v", err)
+		return exitFailure
+	}
+	recorder, err := openHistory(*historyPath, opts.StartURL, *checkpoint.resume)
+	if err != nil {
+		stream.close()
+		log.Printf("Failed to open history: %v", err)
+		return exitFailure
+	}
+
+	scraper := crawler.NewScraperWithConfig(crawler.ScraperConfig{
+		AllowedDomains:     opts.AllowedDomains,
+		MaxDepth:           opts.Depth,
+		Parallelism:        opts.Parallelism,
+		Delay:              opts.Delay,
+		RandomDelay:        defaults.RandomDelay,
+		DetailParallelism:  *detailParallelism,
+		DetailDelay:        *detailDelay,
+		CacheDir:           opts.CacheDir,
+		Profile:            opts.Profile,
+		IgnoreRobotsTxt:    opts.IgnoreRobots,
+		Retry:              opts.retryPolicy(),
+		DrainTimeout:       opts.DrainTimeout,
+		CheckpointPath:     checkpointPath,
+		CheckpointInterval: *checkpoint.interval,
+		Resume:             *checkpoint.resume,
+		Exporter:           stream.target(recorder),
+		Images:             images,
+		Validator:          checks.validator,
+		Proxies:            pools.listing,
+		DetailProxies:      pools.detail,
+		Cookies:            cookies,
+	})
+
+	startTime := time.Now()
+	release := interruptHandler(scraper.Stop)
+	err = scraper.Scrape(opts.StartURL)
+	release()
+	saveCookies(cookies, *cookiesPath)
+	validated := checks.finish()
+	if err != nil {
+		stream.close()
+		log.Printf("Scraping failed: %v", err)
+		saveHistory(recorder, scraper.GetProducts(), false, true)
+		return exitFailure
+	}
+
+	fmt.Printf("\n=== Results ===\n")
+	fmt.Printf("Products found: %d\n", scraper.GetProductCount())
+	fmt.Printf("Time elapsed: %s\n", time.Since(startTime))
+	printBlocked(scraper.GetBlockedURLs())
+	pools.print()
+	if !validated {
+		stream.close()
+		saveHistory(recorder, scraper.GetProducts(), false, true)
+		return exitFailure
+	}
+
+	code := exitCode(scraper.GetProductCount(), scraper.GetFailedRequests())
+	products := scraper.GetProducts()
+	if !saveHistory(recorder, products, scraper.Stopped(), code == exitFailure) {
+		stream.close()
+		return exitFailure
+	}
+	delivered := scraper.Stopped() || code == exitFailure || sendAlerts(alerts, *historyPath, recorder)
+	if stream != nil {
+		return alertsExitCode(stream.finish(opts.Output, scraper.Stopped(), checkpointPath, code), delivered)
+	}
+
+	output := exportPath(opts.Output, scraper.Stopped())
+	if len(products) > 0 || scraper.Stopped() {
+		write, format := scraper.ExportToJSON, "JSON"
+		if filepath.Ext(output) == ".csv" {
+			write, format = scraper.ExportToCSV, "CSV"
+		}
+		if err := write(output); err != nil {
+			log.Printf("Failed to export %s: %v", format, err)
+			return exitFailure
+		}
+		fmt.Printf("Data exported to %s\n", output)
+		removeStalePartial(opts.Output, output)
+	}
+
+	if scraper.Stopped() {
+		return interrupted(output, checkpointPath)
+	}
+	return alertsExitCode(code, delivered)
+}
+
+// streamOutput is an output written while the run is in progress
+type streamOutput struct {
+	exporter export.Exporter
+	partial  string // file moved to the output once the run completes, empty for databases
+}
+
+// openStream opens the exporter for outputs written as products are
+// scraped: JSON Lines for ".jsonl", and SQLite for ".db" and ".sqlite",
+// which accumulate products across runs. It returns nil for the formats
+// written at the end of the run. JSON Lines records go to the partial file
+// until finishStream moves it into place, so an interrupted run never
+// replaces a complete export; a resumed run appends to it.
+func openStream(output string, resume bool) (*streamOutput, error) {
+	switch filepath.Ext(output) {
+	case ".db", ".sqlite":
+		exporter, err := export.OpenSQLite(output)
+		if err != nil {
+			return nil, err
+		}
+		return &streamOutput{exporter: exporter}, nil
+	case ".jsonl":
+		return openJSONLines(output, resume)
+	default:
+		return nil, nil
+	}
+}
+
+// openJSONLines opens the partial file of a JSON Lines output
+func openJSONLines(output string, resume bool) (*streamOutput, error) {
+	partial := partialPath(output)
+	if resume {
+		// The last run may have completed with failed requests, leaving its
+		// records in output rather than in the partial file
+		if _, err := os.Stat(partial); os.IsNotExist(err) {
+			if err := os.Rename(output, partial); err != nil && !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+	}
+
+	exporter, err := export.CreateJSONLines(partial, resume)
+	if err != nil {
+		return nil, err
+	}
+	return &streamOutput{exporter: exporter, partial: partial}, nil
+}
+
+// target returns the exporter to hand to a scraper, also feeding recorder
+// when set. It is nil without a stream so the scraper keeps its products in
+// memory.
+func (s *streamOutput) target(recorder *history.Recorder) export.Exporter {
+	if s == nil {
+		return nil
+	}
+	if recorder == nil {
+		return s.exporter
+	}
+	return export.Multi(s.exporter, recorder)
+}
+
+// close closes the stream, if any, after a failed run
+func (s *streamOutput) close() {
+	if s == nil {
+		return
+	}
+	if err := s.exporter.Close(); err != nil {
+		log.Printf("Failed to close output: %v", err)
+	}
+}
+
+// finish closes the stream and moves the partial file into place unless the
+// run was interrupted. It returns code, or the exit code for an interrupted
+// run or a failed export.
+func (s *streamOutput) finish(output string, stopped bool, checkpointPath string, code int) int {
+	if err := s.exporter.Close(); err != nil {
+		log.Printf("Failed to close %s: %v", output, err)
+		return exitFailure
+	}
+
+	written := output
+	if s.partial != "" {
+		written = s.partial
+	}
+	if stopped {
+		return interrupted(written, checkpointPath)
+	}
+	if s.partial != "" {
+		if err := os.Rename(s.partial, output); err != nil {
+			log.Printf("Failed to move %s into place: %v", s.partial, err)
+			return exitFailure
+		}
+	}
+	fmt.Printf("Data exported to %s\n", output)
+	return code
+}
+
+// exportPath returns the file to export to. Interrupted runs write to a
+// ".partial" file so incomplete results never replace a complete export.
+func exportPath(output string, interrupted bool) string {
+	if interrupted {
+		return partialPath(output)
+	}
+	return output
+}
+
+// removeStalePartial deletes the partial export of an earlier interrupted run
+// once a complete export has been written to written
+func removeStalePartial(output, written string) {
+	partial := partialPath(output)
+	if written == partial {
+		return
+	}
+	if err := os.Remove(partial); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove stale %s: %v", partial, err)
+	}
+}
+
+// interrupted reports a run stopped by a signal and returns its exit code
+func interrupted(output, checkpointPath string) int {
+	fmt.Printf("Interrupted: partial results are in %s\n", output)
+	if checkpointPath != "" {
+		fmt.Printf("Run again with -resume to continue from %s\n", checkpointPath)
+	}
+	return exitInterrupted
+}
+
+// printBlocked lists the URLs skipped because of robots.txt
+func printBlocked(blocked []crawler.BlockedURL) {
+	if len(blocked) == 0 {
+		return
+	}
+
+	fmt.Printf("Blocked by robots.txt: %d\n", len(blocked))
+	for _, b := range blocked {
+		fmt.Printf("  - %s (%s)\n", b.URL, b.Reason)
+	}
+}
+
+// exitCode maps the outcome of a run onto the CLI exit codes
+func exitCode(succeeded, failed int) int {
+	switch {
+	case failed == 0:
+		return exitOK
+	case succeeded == 0:
+		return exitFailure
+	default:
+		return exitPartial
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,492 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/crawler"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+)
+
+func TestRunDispatch(t *testing.T) {
+	t.Run("no arguments prints usage", func(t *testing.T) {
+		var stderr strings.Builder
+		code := run(nil, &stderr)
+
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr.String(), "Usage: web-scraper")
+	})
+
+	t.Run("unknown command", func(t *testing.T) {
+		var stderr strings.Builder
+		code := run([]string{"explode"}, &stderr)
+
+		assert.Equal(t, exitUsage, code)
+		assert.Contains(t, stderr.String(), `unknown command "explode"`)
+	})
+
+	t.Run("help", func(t *testing.T) {
+		code := run([]string{"help"}, io.Discard)
+		assert.Equal(t, exitOK, code)
+	})
+
+	t.Run("subcommand help", func(t *testing.T) {
+		var stderr strings.Builder
+		code := run([]string{"crawl", "-h"}, &stderr)
+
+		assert.Equal(t, exitOK, code)
+		assert.Contains(t, stderr.String(), "-max-pages")
+	})
+}
+
+func TestRunFlagValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "unknown flag", args: []string{"scrape", "-bogus"}},
+		{name: "relative start URL", args: []string{"scrape", "-url", "/ecommerce"}},
+		{name: "zero parallelism", args: []string{"crawl", "-parallelism", "0"}},
+		{name: "negative delay", args: []string{"deep-scrape", "-delay", "-1s"}},
+		{name: "negative depth", args: []string{"scrape", "-depth", "-1"}},
+		{name: "empty output", args: []string{"crawl", "-output", ""}},
+		{name: "zero detail parallelism", args: []string{"deep-scrape", "-detail-parallelism", "0"}},
+		{name: "positional arguments", args: []string{"scrape", "extra"}},
+		{name: "unknown trailing slash policy", args: []string{"crawl", "-trailing-slash", "remove"}},
+		{name: "unknown browser", args: []string{"crawl", "-browsers", "chrome-windows,netscape"}},
+		{name: "unknown header rotation", args: []string{"crawl", "-rotate-headers", "request"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code := run(tt.args, io.Discard)
+			assert.Equal(t, exitUsage, code)
+		})
+	}
+}
+
+func TestFinishOptions(t *testing.T) {
+	t.Run("defaults allowed domains to start URL host", func(t *testing.T) {
+		opts := runOptions{StartURL: "http://127.0.0.1:8080/shop", Output: "out.csv", Parallelism: 1}
+		require.NoError(t, finishOptions(&opts, ""))
+		assert.Equal(t, []string{"127.0.0.1"}, opts.AllowedDomains)
+	})
+
+	t.Run("uses explicit domains", func(t *testing.T) {
+		opts := runOptions{StartURL: "http://example.com/", Output: "out.csv", Parallelism: 1}
+		require.NoError(t, finishOptions(&opts, "example.com, www.example.com,"))
+		assert.Equal(t, []string{"example.com", "www.example.com"}, opts.AllowedDomains)
+	})
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, exitOK, exitCode(5, 0))
+	assert.Equal(t, exitOK, exitCode(0, 0))
+	assert.Equal(t, exitPartial, exitCode(5, 2))
+	assert.Equal(t, exitFailure, exitCode(0, 2))
+}
+
+func TestScrapeCommand(t *testing.T) {
+	t.Run("scrapes all pages and exports CSV", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/":       testutil.MustGetFixture(t, "listing.html"),
+			"/page/2": testutil.MustGetFixture(t, "listing_page2.html"),
+		})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "products.csv")
+		code := run([]string{"scrape", "-url", server.URL + "/", "-output", output, "-delay", "0", "-cache-dir", ""}, io.Discard)
+		assert.Equal(t, exitOK, code)
+
+		rows, err := testutil.ReadCSVFile(output)
+		require.NoError(t, err)
+		assert.Len(t, rows, 6) // Header + 5 products
+	})
+
+	t.Run("reports partial failure", func(t *testing.T) {
+		listing := strings.Replace(testutil.MustGetFixture(t, "listing.html"), `href="/page/2"`, `href="/missing"`, 1)
+		server := testutil.CreateMockServerWithRoutes(map[string]string{"/shop": listing})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "products.csv")
+		code := run([]string{"scrape", "-url", server.URL + "/shop", "-output", output, "-delay", "0", "-cache-dir", ""}, io.Discard)
+		assert.Equal(t, exitPartial, code)
+		assert.FileExists(t, output)
+	})
+
+	t.Run("fails when nothing could be fetched", func(t *testing.T) {
+		server := testutil.CreateMockServerWithStatus(500, "boom")
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "products.csv")
+		code := run([]string{"scrape", "-url", server.URL + "/", "-output", output, "-delay", "0", "-cache-dir", ""}, io.Discard)
+		assert.Equal(t, exitFailure, code)
+		assert.False(t, testutil.FileExists(output))
+	})
+}
+
+func TestCrawlCommand(t *testing.T) {
+	t.Run("writes discovered links", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/":        `<html><body><a href="/about">About</a><a href="/contact">Contact</a></body></html>`,
+			"/about":   `<html><body><a href="/">Home</a></body></html>`,
+			"/contact": `<html><body>Contact</body></html>`,
+		})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "links.txt")
+		code := run([]string{"crawl", "-url", server.URL + "/", "-output", output, "-max-pages", "10"}, io.Discard)
+		assert.Equal(t, exitOK, code)
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), server.URL+"/about\n")
+		assert.Contains(t, string(data), server.URL+"/contact\n")
+	})
+
+	t.Run("strips the parameters given by -strip-params", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/": `<html><body><a href="/about?ref=home&utm_source=x">About</a></body></html>`,
+		})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "links.txt")
+		code := run([]string{"crawl", "-url", server.URL + "/", "-output", output, "-strip-params", "ref"}, io.Discard)
+		assert.Equal(t, exitOK, code)
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), server.URL+"/about?utm_source=x\n")
+	})
+
+	t.Run("reports partial failure for broken links", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/start": `<html><body><a href="/missing">Missing</a></body></html>`,
+		})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "links.txt")
+		code := run([]string{"crawl", "-url", server.URL + "/start", "-output", output}, io.Discard)
+		assert.Equal(t, exitPartial, code)
+	})
+	t.Run("writes page records for JSON and CSV outputs", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/":      `<html><head><title>Home</title></head><body><a href="/about">About</a></body></html>`,
+			"/about": `<html><head><title>About</title></head><body>About</body></html>`,
+		})
+		defer server.Close()
+
+		dir := t.TempDir()
+		output := filepath.Join(dir, "pages.json")
+		code := run([]string{"crawl", "-url", server.URL + "/", "-output", output}, io.Discard)
+		require.Equal(t, exitOK, code)
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		var pages []extract.Page
+		require.NoError(t, json.Unmarshal(data, &pages))
+		var titles []string
+		for _, page := range pages {
+			titles = append(titles, page.Title)
+		}
+		assert.ElementsMatch(t, []string{"Home", "About"}, titles)
+
+		output = filepath.Join(dir, "pages.csv")
+		code = run([]string{"crawl", "-url", server.URL + "/", "-output", output}, io.Discard)
+		require.Equal(t, exitOK, code)
+		rows, err := testutil.ReadCSVFile(output)
+		require.NoError(t, err)
+		assert.Len(t, rows, 3)
+	})
+
+	t.Run("streams page records to JSON Lines", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/":      `<html><head><title>Home</title></head><body><a href="/about">About</a></body></html>`,
+			"/about": `<html><head><title>About</title></head><body>About</body></html>`,
+		})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "pages.jsonl")
+		code := run([]string{"crawl", "-url", server.URL + "/", "-output", output}, io.Discard)
+		require.Equal(t, exitOK, code)
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(data), "\n"))
+		assert.Contains(t, string(data), `"title":"About"`)
+		assert.False(t, testutil.FileExists(partialPath(output)))
+	})
+}
+
+func TestDeepScrapeCommand(t *testing.T) {
+	t.Run("scrapes detail pages and exports JSON", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/product/detailed": testutil.MustGetFixture(t, "product.html"),
+		})
+		defer server.Close()
+
+		listing := `<html><body><ul>
+			<li class="product"><a class="woocommerce-LoopProduct-link" href="` + server.URL + `/product/detailed">Detailed</a></li>
+		</ul></body></html>`
+		listingServer := testutil.CreateMockServerWithRoutes(map[string]string{"/": listing})
+		defer listingServer.Close()
+
+		output := filepath.Join(t.TempDir(), "products.json")
+		code := run([]string{
+			"deep-scrape", "-url", listingServer.URL + "/", "-output", output,
+			"-delay", "0", "-detail-delay", "0", "-cache-dir", "",
+		}, io.Discard)
+		assert.Equal(t, exitOK, code)
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+
+		var products []extract.ProductDetail
+		require.NoError(t, json.Unmarshal(data, &products))
+		require.Len(t, products, 1)
+		assert.Equal(t, "Detailed Test Product", products[0].Name)
+		assert.Equal(t, "TEST-SKU-001", products[0].SKU)
+		assert.Equal(t, extract.Price{Currency: "USD", Amount: 9999}, products[0].Pricing)
+	})
+
+	t.Run("exports a CSV row per variant", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/product/hoodie": testutil.MustGetFixture(t, "product_variable.html"),
+		})
+		defer server.Close()
+
+		listing := `<html><body><ul>
+			<li class="product"><a class="woocommerce-LoopProduct-link" href="` + server.URL + `/product/hoodie">Hoodie</a></li>
+		</ul></body></html>`
+		listingServer := testutil.CreateMockServerWithRoutes(map[string]string{"/": listing})
+		defer listingServer.Close()
+
+		output := filepath.Join(t.TempDir(), "products.csv")
+		code := run([]string{
+			"deep-scrape", "-url", listingServer.URL + "/", "-output", output,
+			"-delay", "0", "-detail-delay", "0", "-cache-dir", "",
+		}, io.Discard)
+		require.Equal(t, exitOK, code)
+
+		rows, err := testutil.ReadCSVFile(output)
+		require.NoError(t, err)
+		require.Len(t, rows, 3)
+		assert.Equal(t, "HOODIE-S-BLUE", rows[1][5])
+		assert.Equal(t, "HOODIE-L", rows[2][5])
+	})
+}
+
+func TestRetriesFlag(t *testing.T) {
+	t.Run("rejects negative retries", func(t *testing.T) {
+		code := run([]string{"scrape", "-retries", "-1"}, io.Discard)
+		assert.Equal(t, exitUsage, code)
+	})
+
+	t.Run("scrape retries throttled pages", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{"/shop": testutil.MustGetFixture(t, "listing.html")})
+		defer server.Close()
+		server.Fail("/shop", http.StatusServiceUnavailable, 1, "0")
+
+		output := t.TempDir() + "/products.csv"
+		code := run([]string{"scrape", "-url", server.URL + "/shop", "-output", output, "-delay", "0", "-cache-dir", "", "-retries", "1"}, io.Discard)
+		assert.NotEqual(t, exitFailure, code)
+		assert.Equal(t, 2, server.Count("/shop"))
+
+		_, err := os.Stat(output)
+		assert.NoError(t, err)
+	})
+}
+
+func TestCheckpointFlags(t *testing.T) {
+	t.Run("resume without checkpoints is a usage error", func(t *testing.T) {
+		code := run([]string{"crawl", "-resume", "-no-checkpoint"}, io.Discard)
+		assert.Equal(t, exitUsage, code)
+	})
+
+	t.Run("rejects non-positive interval", func(t *testing.T) {
+		code := run([]string{"deep-scrape", "-checkpoint-interval", "0"}, io.Discard)
+		assert.Equal(t, exitUsage, code)
+	})
+
+	t.Run("resume fails without a checkpoint file", func(t *testing.T) {
+		output := filepath.Join(t.TempDir(), "links.txt")
+		code := run([]string{"crawl", "-url", "http://127.0.0.1:1/", "-output", output, "-resume"}, io.Discard)
+		assert.Equal(t, exitFailure, code)
+	})
+
+	t.Run("crawl writes a checkpoint next to the output", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/start": `<html><body><a href="/missing">Missing</a></body></html>`,
+		})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "links.txt")
+		code := run([]string{"crawl", "-url", server.URL + "/start", "-output", output}, io.Discard)
+		assert.Equal(t, exitPartial, code)
+
+		cp, err := crawler.LoadCheckpoint(output + ".checkpoint.json")
+		require.NoError(t, err)
+		assert.Equal(t, []string{server.URL + "/start"}, cp.Completed)
+		assert.Equal(t, []string{server.URL + "/missing"}, cp.Pending) // failed pages are retried on resume
+	})
+}
+
+func TestScrapeWithCustomProfile(t *testing.T) {
+	server := testutil.CreateMockServerWithRoutes(map[string]string{
+		"/": `<html><body>
+			<div class="card"><a class="title" href="/p/1">First</a><span class="cost">$5.00</span></div>
+			<div class="card"><a class="title" href="/p/2">Second</a><span class="cost">$7.50</span></div>
+		</body></html>`,
+	})
+	defer server.Close()
+
+	profile := filepath.Join(t.TempDir(), "shop.yaml")
+	require.NoError(t, os.WriteFile(profile, []byte(`
+name: simple-shop
+listing:
+  item: div.card
+  url:
+    selector: a.title
+    attr: href
+  name:
+    selector: a.title
+  price:
+    selector: span.cost
+detail:
+  root: article
+  name:
+    selector: h1
+`), 0o644))
+	output := filepath.Join(t.TempDir(), "products.csv")
+	code := run([]string{
+		"scrape", "-url", server.URL + "/", "-output", output,
+		"-profile", profile, "-delay", "0", "-cache-dir", "",
+	}, io.Discard)
+	require.Equal(t, exitOK, code)
+
+	rows, err := testutil.ReadCSVFile(output)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"First", "$5.00", server.URL + "/p/1", ""}, rows[1][:4])
+	assert.Equal(t, []string{"Second", "$7.50", server.URL + "/p/2", ""}, rows[2][:4])
+}
+
+func TestScrapeWithInvalidProfile(t *testing.T) {
+	code := run([]string{"scrape", "-profile", filepath.Join(t.TempDir(), "missing.yaml")}, io.Discard)
+	assert.Equal(t, exitUsage, code)
+}
+
+func TestJSONLinesOutput(t *testing.T) {
+	readLines := func(t *testing.T, filename string) []string {
+		t.Helper()
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	}
+
+	t.Run("scrape streams products", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/":       testutil.MustGetFixture(t, "listing.html"),
+			"/page/2": testutil.MustGetFixture(t, "listing_page2.html"),
+		})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "products.jsonl")
+		code := run([]string{"scrape", "-url", server.URL + "/", "-output", output, "-delay", "0", "-cache-dir", ""}, io.Discard)
+		assert.Equal(t, exitOK, code)
+
+		lines := readLines(t, output)
+		require.Len(t, lines, 5)
+		var product extract.Product
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &product))
+		assert.NotEmpty(t, product.Name)
+		assert.NoFileExists(t, partialPath(output))
+
+		var record map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+		keys := make([]string, 0, len(record))
+		for key := range record {
+			keys = append(keys, key)
+		}
+		assert.ElementsMatch(t, []string{"url", "image_url", "name", "price", "pricing", "scraped_at"}, keys,
+			"keys are named like those of product details")
+	})
+
+	t.Run("deep-scrape streams product details", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "products.jsonl")
+		code := run([]string{
+			"deep-scrape", "-url", server.URL + "/", "-output", output,
+			"-delay", "0", "-detail-delay", "0", "-cache-dir", "", "-ignore-robots",
+		}, io.Discard)
+		assert.Equal(t, exitOK, code)
+
+		lines := readLines(t, output)
+		require.Len(t, lines, 3)
+		var product extract.ProductDetail
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &product))
+		assert.Equal(t, "TEST-SKU-001", product.SKU)
+	})
+
+	t.Run("interrupted run keeps a complete export", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "products.jsonl")
+		require.NoError(t, os.WriteFile(output, []byte("{}\n"), 0o644))
+
+		code := interruptOnHit(t, server, "/product/test-product-2", []string{
+			"deep-scrape", "-url", server.URL + "/", "-output", output,
+			"-delay", "0", "-detail-delay", "0", "-detail-parallelism", "1", "-cache-dir", "",
+			"-ignore-robots", "-drain-timeout", "50ms",
+		})
+		assert.Equal(t, exitInterrupted, code)
+		assert.Equal(t, []string{"{}"}, readLines(t, output))
+		assert.FileExists(t, partialPath(output))
+	})
+}
+
+func TestCookiesFlag(t *testing.T) {
+	t.Run("rejects a malformed cookie file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cookies.txt")
+		require.NoError(t, os.WriteFile(path, []byte("not a cookie\n"), 0o644))
+		assert.Equal(t, exitUsage, run([]string{"scrape", "-cookies", path}, io.Discard))
+	})
+
+	t.Run("scrape sends the seeded cookies and saves the new ones", func(t *testing.T) {
+		listing := testutil.MustGetFixture(t, "listing.html")
+		server := testutil.CreateMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c, err := r.Cookie("region"); err != nil || c.Value != "de" {
+				http.Error(w, "choose a region", http.StatusForbidden)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "visited", Value: "1", MaxAge: 3600})
+			w.Write([]byte(listing))
+		}))
+		defer server.Close()
+
+		cookies := filepath.Join(t.TempDir(), "cookies.txt")
+		require.NoError(t, os.WriteFile(cookies, []byte("127.0.0.1\tFALSE\t/\tFALSE\t0\tregion\tde\n"), 0o644))
+		output := filepath.Join(t.TempDir(), "products.csv")
+		code := run([]string{"scrape", "-url", server.URL + "/", "-output", output, "-delay", "0", "-cache-dir", "", "-depth", "1", "-cookies", cookies}, io.Discard)
+		assert.Equal(t, exitOK, code)
+
+		jar, err := crawler.LoadCookieJar(cookies)
+		require.NoError(t, err)
+		var names []string
+		for _, c := range jar.Cookies() {
+			names = append(names, c.Name)
+		}
+		assert.Equal(t, []string{"region", "visited"}, names)
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"web-scraper/extract"
+	"web-scraper/history"
+)
+
+// runDiffCommand implements the "diff" subcommand, reporting the changes
+// between two runs of a history to stdout
+func runDiffCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	historyPath := fs.String("history", "", "history file written by scrape or deep-scrape -history")
+	from := fs.Int("from", 0, "id of the older run (default: second to last complete run)")
+	to := fs.Int("to", 0, "id of the newer run (default: last complete run)")
+	asJSON := fs.Bool("json", false, "print the changes as JSON")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(stderr, "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		return exitUsage
+	}
+	if *historyPath == "" {
+		fmt.Fprintln(stderr, "diff: -history is required")
+		return exitUsage
+	}
+	if (*from == 0) != (*to == 0) {
+		fmt.Fprintln(stderr, "diff: -from and -to must be given together")
+		return exitUsage
+	}
+
+	store, err := history.Open(*historyPath)
+	if err != nil {
+		log.Printf("Failed to open history: %v", err)
+		return exitFailure
+	}
+	older, newer, err := selectRuns(store, *from, *to)
+	if err != nil {
+		log.Printf("Cannot compare runs: %v", err)
+		return exitFailure
+	}
+
+	d := history.Compare(older, newer)
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(d); err != nil {
+			log.Printf("Failed to write diff: %v", err)
+			return exitFailure
+		}
+		return exitOK
+	}
+	printDiff(stdout, older, newer, d)
+	return exitOK
+}
+
+// selectRuns returns the runs with ids from and to, or the last two complete
+// runs when both are zero
+func selectRuns(store *history.Store, from, to int) (history.Run, history.Run, error) {
+	if from == 0 && to == 0 {
+		return store.LatestPair()
+	}
+
+	older, ok := store.Run(from)
+	if !ok {
+		return history.Run{}, history.Run{}, fmt.Errorf("no run %d in the history", from)
+	}
+	newer, ok := store.Run(to)
+	if !ok {
+		return history.Run{}, history.Run{}, fmt.Errorf("no run %d in the history", to)
+	}
+	return older, newer, nil
+}
+
+// printDiff writes d as a readable report
+func printDiff(w io.Writer, older, newer history.Run, d history.Diff) {
+	fmt.Fprintf(w, "Comparing run %d (%s) with run %d (%s)\n",
+		older.ID, older.StartedAt.Format("2006-01-02 15:04"), newer.ID, newer.StartedAt.Format("2006-01-02 15:04"))
+	if d.Empty() {
+		fmt.Fprintln(w, "No changes.")
+		return
+	}
+
+	if len(d.Added) > 0 {
+		fmt.Fprintf(w, "\nNew products (%d):\n", len(d.Added))
+		for _, p := range d.Added {
+			fmt.Fprintf(w, "  + %s  %s  %s\n", p.Name, p.Price, p.URL)
+		}
+	}
+	if len(d.Removed) > 0 {
+		fmt.Fprintf(w, "\nRemoved products (%d):\n", len(d.Removed))
+		for _, p := range d.Removed {
+			fmt.Fprintf(w, "  - %s  %s  %s\n", p.Name, p.Price, p.URL)
+		}
+	}
+	if len(d.Prices) > 0 {
+		fmt.Fprintf(w, "\nPrice changes (%d):\n", len(d.Prices))
+		for _, c := range d.Prices {
+			fmt.Fprintf(w, "  %s: %s -> %s%s  %s\n", c.Name, c.Old.Price, c.New.Price, formatChange(c), c.URL)
+		}
+	}
+	if len(d.Stock) > 0 {
+		fmt.Fprintf(w, "\nStock changes (%d):\n", len(d.Stock))
+		for _, c := range d.Stock {
+			fmt.Fprintf(w, "  %s: %s -> %s  %s\n", c.Name, stockText(!c.InStock), stockText(c.InStock), c.URL)
+		}
+	}
+}
+
+// formatChange renders the absolute and relative change of a price, empty
+// when the prices could not be compared
+func formatChange(c history.PriceChange) string {
+	if !c.Comparable {
+		return ""
+	}
+	sign := ""
+	if c.Change > 0 {
+		sign = "+"
+	}
+	currency := c.New.Pricing.Currency
+	if c.Old.Pricing.Amount == 0 {
+		return fmt.Sprintf(" (%s%s %s)", sign, extract.FormatAmount(c.Change, currency), currency)
+	}
+	return fmt.Sprintf(" (%s%s %s, %+.1f%%)", sign, extract.FormatAmount(c.Change, currency), currency, c.Percent)
+}
+
+// stockText describes a stock state
+func stockText(inStock bool) string {
+	if inStock {
+		return "in stock"
+	}
+	return "out of stock"
+}
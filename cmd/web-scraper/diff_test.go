@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/history"
+	"web-scraper/internal/testutil"
+)
+
+func TestHistoryAndDiff(t *testing.T) {
+	server := testutil.CreateShopServer(t)
+	defer server.Close()
+
+	dir := t.TempDir()
+	historyPath := filepath.Join(dir, "history.json")
+	deepScrape := func(output string) int {
+		return run([]string{
+			"deep-scrape", "-url", server.URL + "/", "-output", filepath.Join(dir, output),
+			"-delay", "0", "-detail-delay", "0", "-cache-dir", "", "-ignore-robots", "-no-checkpoint",
+			"-history", historyPath,
+		}, io.Discard)
+	}
+
+	// The first run keeps products in memory, the second streams them
+	require.Equal(t, exitOK, deepScrape("first.json"))
+	product := testutil.MustGetFixture(t, "product.html")
+	server.Route("/product/test-product-2", testutil.MustGetFixture(t, "product_out_of_stock.html"))
+	server.Route("/product/test-product-3", strings.Replace(product, "$99.99", "$79.99", 1))
+	require.Equal(t, exitOK, deepScrape("second.jsonl"))
+
+	t.Run("reports changes as JSON", func(t *testing.T) {
+		var stdout strings.Builder
+		code := runDiffCommand([]string{"-history", historyPath, "-json"}, &stdout, io.Discard)
+		require.Equal(t, exitOK, code)
+
+		var d history.Diff
+		require.NoError(t, json.Unmarshal([]byte(stdout.String()), &d))
+		assert.Equal(t, 1, d.From)
+		assert.Equal(t, 2, d.To)
+		assert.Empty(t, d.Added)
+		assert.Empty(t, d.Removed)
+		require.Len(t, d.Prices, 1)
+		assert.Equal(t, server.URL+"/product/test-product-3", d.Prices[0].URL)
+		assert.Equal(t, int64(-2000), d.Prices[0].Change)
+		require.Len(t, d.Stock, 1)
+		assert.Equal(t, server.URL+"/product/test-product-2", d.Stock[0].URL)
+		assert.False(t, d.Stock[0].InStock)
+	})
+
+	t.Run("prints a report", func(t *testing.T) {
+		var stdout strings.Builder
+		code := run([]string{"diff", "-history", historyPath, "-from", "1", "-to", "2"}, io.Discard)
+		require.Equal(t, exitOK, code)
+
+		code = runDiffCommand([]string{"-history", historyPath}, &stdout, io.Discard)
+		require.Equal(t, exitOK, code)
+		report := stdout.String()
+		assert.Contains(t, report, "Comparing run 1")
+		assert.Contains(t, report, "$99.99 -> $79.99 (-20.00 USD, -20.0%)")
+		assert.Contains(t, report, "in stock -> out of stock")
+		assert.NotContains(t, report, "New products")
+	})
+
+	t.Run("same run has no changes", func(t *testing.T) {
+		var stdout strings.Builder
+		code := runDiffCommand([]string{"-history", historyPath, "-from", "2", "-to", "2"}, &stdout, io.Discard)
+		require.Equal(t, exitOK, code)
+		assert.Contains(t, stdout.String(), "No changes.")
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		assert.Equal(t, exitUsage, runDiffCommand(nil, io.Discard, io.Discard))
+		assert.Equal(t, exitUsage, runDiffCommand([]string{"-history", historyPath, "-from", "1"}, io.Discard, io.Discard))
+		assert.Equal(t, exitFailure, runDiffCommand([]string{"-history", historyPath, "-from", "1", "-to", "9"}, io.Discard, io.Discard))
+		assert.Equal(t, exitFailure, runDiffCommand([]string{"-history", filepath.Join(dir, "missing.json")}, io.Discard, io.Discard))
+	})
+}
+
+func TestFailedRunHistory(t *testing.T) {
+	server := testutil.CreateShopServer(t)
+	unreachable := server.URL + "/"
+	server.Close()
+	blocked := testutil.CreateMockServerWithRoutes(map[string]string{
+		"/robots.txt": "User-agent: *\nDisallow: /\n",
+	})
+	defer blocked.Close()
+
+	for _, command := range []string{"scrape", "deep-scrape"} {
+		for name, startURL := range map[string]string{
+			"unreachable server": unreachable,
+			"start URL blocked":  blocked.URL + "/",
+		} {
+			t.Run(command+"/"+name, func(t *testing.T) {
+				dir := t.TempDir()
+				historyPath := filepath.Join(dir, "history.json")
+				code := run([]string{
+					command, "-url", startURL, "-output", filepath.Join(dir, "products.jsonl"),
+					"-delay", "0", "-cache-dir", "", "-retries", "0", "-history", historyPath,
+				}, io.Discard)
+				require.Equal(t, exitFailure, code)
+
+				store, err := history.Open(historyPath)
+				require.NoError(t, err)
+				runs := store.Runs()
+				require.Len(t, runs, 1)
+				assert.True(t, runs[0].Failed)
+				assert.True(t, runs[0].Incomplete, "not compared with later runs")
+				assert.False(t, runs[0].FinishedAt.IsZero())
+			})
+		}
+	}
+}
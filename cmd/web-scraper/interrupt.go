@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// interruptHandler installs the signal handling used by the CLI. Tests
+// replace it to stop a run without sending a real signal.
+var interruptHandler = onInterrupt
+
+// onInterrupt calls stop on the first SIGINT or SIGTERM. Later signals get
+// the default behaviour, so a second Ctrl-C quits immediately. The returned
+// function removes the handler.
+func onInterrupt(stop func()) func() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	released := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			log.Printf("[SHUTDOWN] Received %s, finishing in-flight requests (press Ctrl-C again to quit)", sig)
+			stop()
+		case <-released:
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(released)
+		})
+	}
+}
+
+// partialPath returns the file an interrupted run exports to, e.g.
+// "products.partial.csv" for "products.csv"
+func partialPath(output string) string {
+	ext := filepath.Ext(output)
+	return strings.TrimSuffix(output, ext) + ".partial" + ext
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/crawler"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+)
+
+func TestOnInterrupt(t *testing.T) {
+	t.Run("stops on SIGTERM", func(t *testing.T) {
+		stopped := make(chan struct{})
+		release := onInterrupt(func() { close(stopped) })
+		defer release()
+
+		require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatal("stop was not called")
+		}
+	})
+
+	t.Run("release is idempotent", func(t *testing.T) {
+		release := onInterrupt(func() { t.Error("unexpected stop") })
+		release()
+		release()
+	})
+}
+
+func TestPartialPath(t *testing.T) {
+	tests := map[string]string{
+		"products.csv":         "products.partial.csv",
+		"out/products.json":    "out/products.partial.json",
+		"links":                "links.partial",
+		"archive.v2/links.txt": "archive.v2/links.partial.txt",
+	}
+	for output, expected := range tests {
+		assert.Equal(t, expected, partialPath(output))
+	}
+
+	assert.Equal(t, "products.csv", exportPath("products.csv", false))
+	assert.Equal(t, "products.partial.csv", exportPath("products.csv", true))
+}
+
+// interruptOnHit runs a CLI command whose run is stopped, as if by Ctrl-C,
+// once the server receives a request for path. The request is held until the
+// test ends so the run has to give up on it after the drain timeout.
+func interruptOnHit(t *testing.T, server *testutil.CountingServer, path string, args []string) int {
+	t.Helper()
+	release := server.Block(path)
+	defer release()
+
+	stops := make(chan func(), 1)
+	previous := interruptHandler
+	interruptHandler = func(stop func()) func() {
+		stops <- stop
+		return func() {}
+	}
+	t.Cleanup(func() { interruptHandler = previous })
+
+	done := make(chan int, 1)
+	go func() { done <- run(args, io.Discard) }()
+
+	require.Eventually(t, func() bool { return server.Count(path) > 0 }, 5*time.Second, 5*time.Millisecond)
+	(<-stops)()
+	return <-done
+}
+
+func TestInterruptedCommands(t *testing.T) {
+	t.Run("scrape writes a partial CSV", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{"/": testutil.MustGetFixture(t, "listing.html")})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "products.csv")
+		code := interruptOnHit(t, server, "/page/2", []string{
+			"scrape", "-url", server.URL + "/", "-output", output,
+			"-delay", "0", "-cache-dir", "", "-ignore-robots", "-drain-timeout", "50ms",
+		})
+		assert.Equal(t, exitInterrupted, code)
+		assert.False(t, testutil.FileExists(output))
+
+		rows, err := testutil.ReadCSVFile(partialPath(output))
+		require.NoError(t, err)
+		assert.Len(t, rows, 4) // Header + the 3 products of the first page
+	})
+
+	t.Run("crawl writes partial links", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{
+			"/":     `<html><body><a href="/a">A</a><a href="/slow">Slow</a></body></html>`,
+			"/a":    `<html><body>A</body></html>`,
+			"/slow": `<html><body><a href="/never">Never</a></body></html>`,
+		})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "links.txt")
+		code := interruptOnHit(t, server, "/slow", []string{
+			"crawl", "-url", server.URL + "/", "-output", output, "-ignore-robots", "-ignore-sitemaps", "-drain-timeout", "50ms",
+		})
+		assert.Equal(t, exitInterrupted, code)
+		assert.False(t, testutil.FileExists(output))
+		assert.Equal(t, 0, server.Count("/never"))
+
+		data, err := os.ReadFile(partialPath(output))
+		require.NoError(t, err)
+		assert.Contains(t, string(data), server.URL+"/a\n")
+
+		cp, err := crawler.LoadCheckpoint(output + ".checkpoint.json")
+		require.NoError(t, err)
+		assert.Contains(t, cp.Pending, server.URL+"/slow")
+	})
+
+	t.Run("deep-scrape writes an empty partial export", func(t *testing.T) {
+		server := testutil.CreateCountingServer(nil)
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "products.json")
+		code := interruptOnHit(t, server, "/", []string{
+			"deep-scrape", "-url", server.URL + "/", "-output", output,
+			"-delay", "0", "-detail-delay", "0", "-cache-dir", "", "-ignore-robots", "-drain-timeout", "50ms",
+		})
+		assert.Equal(t, exitInterrupted, code)
+
+		data, err := os.ReadFile(partialPath(output))
+		require.NoError(t, err)
+		var products []extract.ProductDetail
+		require.NoError(t, json.Unmarshal(data, &products))
+		assert.Empty(t, products)
+	})
+
+	t.Run("a complete run removes the stale partial export", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{"/": `<html><body><a href="/about">About</a></body></html>`})
+		defer server.Close()
+
+		output := filepath.Join(t.TempDir(), "links.txt")
+		require.NoError(t, os.WriteFile(partialPath(output), []byte("stale\n"), 0o644))
+
+		code := run([]string{"crawl", "-url", server.URL + "/", "-output", output, "-ignore-robots", "-ignore-sitemaps"}, io.Discard)
+		assert.NotEqual(t, exitInterrupted, code)
+		assert.True(t, testutil.FileExists(output))
+		assert.False(t, testutil.FileExists(partialPath(output)))
+	})
+}
+
+func TestDrainTimeoutFlag(t *testing.T) {
+	code := run([]string{"crawl", "-drain-timeout", "-1s"}, io.Discard)
+	assert.Equal(t, exitUsage, code)
+}
@@ -0,0 +1,9 @@
+// Command web-scraper scrapes product listings, product detail pages and
+// site links from the command line. Run it without arguments for usage.
+package main
+
+import "os"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
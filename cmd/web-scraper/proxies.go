@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"web-scraper/crawler"
+)
+
+// Environment variables read when the proxy flags are empty
+const (
+	proxiesEnv       = "WEB_SCRAPER_PROXIES"
+	detailProxiesEnv = "WEB_SCRAPER_DETAIL_PROXIES"
+)
+
+// proxyCheckTimeout bounds the startup check of each proxy
+const proxyCheckTimeout = 15 * time.Second
+
+// proxyFlags holds the flags of the proxy pools
+type proxyFlags struct {
+	proxies       *string
+	detailProxies *string // nil for commands without detail pages
+	sticky        *bool
+	noCheck       *bool
+}
+
+// proxyPools are the proxy pools of a run, nil when requests go direct
+type proxyPools struct {
+	listing *crawler.ProxyPool
+	detail  *crawler.ProxyPool // nil uses listing
+}
+
+// addProxyFlags registers -proxies, -sticky-proxies and -no-proxy-check on
+// fs, and -detail-proxies when the command has detail pages
+func addProxyFlags(fs *flag.FlagSet, detail bool) *proxyFlags {
+	f := &proxyFlags{
+		proxies: fs.String("proxies", "", "comma-separated proxy URLs (http, https, socks5), or @file with one per line (default: $"+proxiesEnv+")"),
+		sticky:  fs.Bool("sticky-proxies", false, "keep each domain on one proxy while it stays healthy"),
+		noCheck: fs.Bool("no-proxy-check", false, "do not check that the proxies can fetch the start URL before scraping"),
+	}
+	if detail {
+		f.detailProxies = fs.String("detail-proxies", "", "proxies for product detail pages and images, like -proxies (default: $"+detailProxiesEnv+", else -proxies)")
+	}
+	return f
+}
+
+// pools creates the proxy pools of the flags
+func (f *proxyFlags) pools(stderr io.Writer) (proxyPools, bool) {
+	var pools proxyPools
+	var ok bool
+	if pools.listing, ok = f.pool("-proxies", *f.proxies, proxiesEnv, stderr); !ok {
+		return pools, false
+	}
+	if f.detailProxies != nil {
+		if pools.detail, ok = f.pool("-detail-proxies", *f.detailProxies, detailProxiesEnv, stderr); !ok {
+			return pools, false
+		}
+	}
+	if *f.sticky && pools.listing == nil && pools.detail == nil {
+		fmt.Fprintln(stderr, "-sticky-proxies needs -proxies")
+		return pools, false
+	}
+	return pools, true
+}
+
+// pool creates the pool of a proxy flag's value, or of the environment
+// variable env when it is empty. It is nil when both are.
+func (f *proxyFlags) pool(name, value, env string, stderr io.Writer) (*crawler.ProxyPool, bool) {
+	if value == "" {
+		value = os.Getenv(env)
+	}
+	if strings.TrimSpace(value) == "" {
+		return nil, true
+	}
+
+	var proxies []crawler.Proxy
+	var err error
+	if filename, ok := strings.CutPrefix(value, "@"); ok {
+		proxies, err = crawler.LoadProxies(filename)
+	} else {
+		proxies, err = crawler.ParseProxies(value)
+	}
+	if err == nil {
+		var pool *crawler.ProxyPool
+		if pool, err = crawler.NewProxyPool(crawler.ProxyPoolConfig{Proxies: proxies, Sticky: *f.sticky}); err == nil {
+			return pool, true
+		}
+	}
+	fmt.Fprintf(stderr, "%s: %v\n", name, err)
+	return nil, false
+}
+
+// check fetches startURL through every proxy unless -no-proxy-check is set,
+// reporting the ones that can't. It fails when a pool has no working proxy.
+func (f *proxyFlags) check(pools proxyPools, startURL string) bool {
+	if *f.noCheck {
+		return true
+	}
+	ok := true
+	for _, pool := range []*crawler.ProxyPool{pools.listing, pools.detail} {
+		if pool == nil {
+			continue
+		}
+		working := 0
+		for _, check := range pool.Check(context.Background(), startURL, proxyCheckTimeout) {
+			if check.Err != nil {
+				log.Printf("[PROXY] %s: %v", check.URL, check.Err)
+				continue
+			}
+			working++
+		}
+		if working == 0 {
+			log.Printf("No working proxy, check the proxies or pass -no-proxy-check")
+			ok = false
+		}
+	}
+	return ok
+}
+
+// print prints how each proxy performed
+func (pools proxyPools) print() {
+	if pools.detail == nil {
+		printProxyStats("Proxies", pools.listing)
+		return
+	}
+	printProxyStats("Listing Proxies", pools.listing)
+	printProxyStats("Detail Proxies", pools.detail)
+}
+
+// printProxyStats prints how each proxy of pool performed under title
+func printProxyStats(title string, pool *crawler.ProxyPool) {
+	if pool == nil {
+		return
+	}
+
+	fmt.Printf("\n=== %s ===\n", title)
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Proxy\tRequests\tSuccess\tFailures\tBans\tLatency\tQuarantined")
+	for _, s := range pool.Stats() {
+		quarantined := "-"
+		if !s.QuarantinedUntil.IsZero() {
+			quarantined = "until " + s.QuarantinedUntil.Format(time.TimeOnly)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.0f%%\t%d\t%d\t%s\t%s\n", s.URL, s.Requests, s.SuccessRate*100,
+			s.Failures, s.Bans, s.AverageLatency.Round(time.Millisecond), quarantined)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+)
+
+func TestProxyFlags(t *testing.T) {
+	t.Run("rejects invalid proxies", func(t *testing.T) {
+		assert.Equal(t, exitUsage, run([]string{"scrape", "-proxies", "not-a-valid-url"}, io.Discard))
+		assert.Equal(t, exitUsage, run([]string{"deep-scrape", "-detail-proxies", "ftp://proxy.example.com:21"}, io.Discard))
+		assert.Equal(t, exitUsage, run([]string{"deep-scrape", "-proxies", "@" + filepath.Join(t.TempDir(), "missing.txt")}, io.Discard))
+		assert.Equal(t, exitUsage, run([]string{"deep-scrape", "-sticky-proxies"}, io.Discard))
+	})
+
+	t.Run("fails when no proxy works", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{"/": testutil.MustGetFixture(t, "listing.html")})
+		defer server.Close()
+		down := testutil.CreateProxyServer()
+		down.Close()
+
+		output := filepath.Join(t.TempDir(), "products.csv")
+		code := run([]string{"scrape", "-url", server.URL + "/", "-output", output, "-proxies", down.URL}, io.Discard)
+		assert.Equal(t, exitFailure, code)
+		assert.Equal(t, 0, server.Count("/"))
+	})
+
+	t.Run("deep-scrape takes detail proxies from a file and the environment", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+		listing := testutil.CreateProxyServer()
+		defer listing.Close()
+		detail := testutil.CreateSOCKS5Server(t, "user", "s3cret")
+		defer detail.Close()
+
+		t.Setenv("PROXY_TEST_PASSWORD", "s3cret")
+		t.Setenv(proxiesEnv, listing.URL)
+		dir := t.TempDir()
+		list := filepath.Join(dir, "detail-proxies.txt")
+		content := "# residential\n" + strings.Replace(detail.URL, "://", "://user:${PROXY_TEST_PASSWORD}@", 1) + "\n"
+		require.NoError(t, os.WriteFile(list, []byte(content), 0o644))
+
+		output := filepath.Join(dir, "products.json")
+		code := run([]string{
+			"deep-scrape", "-url", server.URL + "/", "-output", output, "-delay", "0", "-detail-delay", "0",
+			"-cache-dir", "", "-ignore-robots", "-no-checkpoint", "-detail-proxies", "@" + list,
+		}, io.Discard)
+		require.Equal(t, exitOK, code)
+
+		var products []extract.ProductDetail
+		data, err := os.ReadFile(output)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &products))
+		assert.Len(t, products, 3)
+		assert.Equal(t, 1+2, listing.Requests(), "the startup check and the listing pages")
+		assert.Positive(t, detail.Connections())
+	})
+
+	t.Run("scrapes through the proxies", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{"/": testutil.MustGetFixture(t, "listing.html")})
+		defer server.Close()
+		proxy := testutil.CreateProxyServer()
+		defer proxy.Close()
+
+		output := filepath.Join(t.TempDir(), "products.csv")
+		code := run([]string{
+			"scrape", "-url", server.URL + "/", "-output", output, "-depth", "1",
+			"-delay", "0", "-cache-dir", "", "-ignore-robots", "-proxies", proxy.URL, "-sticky-proxies",
+		}, io.Discard)
+		require.Equal(t, exitOK, code)
+
+		rows, err := testutil.ReadCSVFile(output)
+		require.NoError(t, err)
+		assert.Len(t, rows, 4)
+		assert.Equal(t, server.Count("/"), proxy.Requests())
+		assert.Equal(t, 2, proxy.Requests(), "the startup check and the listing")
+	})
+}
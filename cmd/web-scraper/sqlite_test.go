@@ -0,0 +1,39 @@
+//go:build sqlite
+
+package main
+
+import (
+	"database/sql"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/export"
+	"web-scraper/internal/testutil"
+)
+
+func TestSQLiteOutput(t *testing.T) {
+	server := testutil.CreateShopServer(t)
+	defer server.Close()
+
+	output := filepath.Join(t.TempDir(), "products.db")
+	args := []string{
+		"deep-scrape", "-url", server.URL + "/", "-output", output,
+		"-delay", "0", "-detail-delay", "0", "-cache-dir", "", "-ignore-robots",
+	}
+	require.Equal(t, exitOK, run(args, io.Discard))
+	require.Equal(t, exitOK, run(args, io.Discard))
+
+	db, err := sql.Open(export.SQLiteDriver, output)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// All three fixture products share one SKU, so they end up in one row
+	var products, runs int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM products`).Scan(&products))
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM runs WHERE products = 3`).Scan(&runs))
+	assert.Equal(t, 1, products)
+	assert.Equal(t, 2, runs)
+}
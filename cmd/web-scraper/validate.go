@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"web-scraper/export"
+	"web-scraper/validate"
+)
+
+// validationFlags holds the flags of the validation rules and their outputs
+type validationFlags struct {
+	rules   *string
+	rejects *string
+	quality *string
+}
+
+// addValidationFlags registers -rules, -rejects and -quality on fs
+func addValidationFlags(fs *flag.FlagSet) *validationFlags {
+	return &validationFlags{
+		rules:   fs.String("rules", "", "validation rules (YAML or JSON) products must pass to be exported"),
+		rejects: fs.String("rejects", "", "JSON Lines file for products failing -rules, with the reasons (default: <output>.rejects.jsonl)"),
+		quality: fs.String("quality", "", "file to write the data-quality report to as JSON"),
+	}
+}
+
+// validation is the validator of a run and the rejects file it writes to
+type validation struct {
+	validator *validate.Validator
+	rejects   *export.JSONLinesExporter // nil without rules
+	path      string
+	quality   string
+}
+
+// loadRules loads the -rules config, nil when the flag is empty
+func (f *validationFlags) loadRules(stderr io.Writer) (*validate.Config, bool) {
+	if *f.rules == "" {
+		if *f.rejects != "" {
+			fmt.Fprintln(stderr, "-rejects needs -rules")
+			return nil, false
+		}
+		return nil, true
+	}
+	config, err := validate.LoadConfig(*f.rules)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return nil, false
+	}
+	return config, true
+}
+
+// open starts validating a run writing to output against config. Without
+// rules every product is accepted and only the data-quality report is
+// produced. A resumed run appends to the rejects of the run it continues.
+func (f *validationFlags) open(config *validate.Config, output string, resume bool) (*validation, error) {
+	v := &validation{quality: *f.quality}
+	if config != nil {
+		v.path = *f.rejects
+		if v.path == "" {
+			v.path = output + ".rejects.jsonl"
+		}
+		rejects, err := export.CreateJSONLines(v.path, resume)
+		if err != nil {
+			return nil, err
+		}
+		v.rejects = rejects
+	}
+	v.validator = validate.New(config, v.rejects)
+	return v, nil
+}
+
+// finish closes the rejects file and prints the data-quality report, also
+// writing it to the -quality file. It reports false when either could not be
+// written.
+func (v *validation) finish() bool {
+	ok := true
+	if v.rejects != nil {
+		if err := v.rejects.Close(); err != nil {
+			log.Printf("Failed to close %s: %v", v.path, err)
+			ok = false
+		}
+	}
+
+	report := v.validator.Report()
+	report.Print(os.Stdout)
+	if report.Rejected > 0 && v.rejects != nil {
+		fmt.Printf("Rejected products written to %s\n", v.path)
+	}
+	if v.quality != "" {
+		if err := report.WriteJSON(v.quality); err != nil {
+			log.Printf("Failed to write %s: %v", v.quality, err)
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+	"web-scraper/validate"
+)
+
+func TestValidationFlags(t *testing.T) {
+	writeRules := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	t.Run("routes rejected products to the rejects file", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{"/": testutil.MustGetFixture(t, "listing.html")})
+		defer server.Close()
+
+		dir := t.TempDir()
+		output := filepath.Join(dir, "products.csv")
+		quality := filepath.Join(dir, "quality.json")
+		code := run([]string{
+			"scrape", "-url", server.URL + "/", "-output", output, "-depth", "1",
+			"-delay", "0", "-cache-dir", "", "-quality", quality,
+			"-rules", writeRules(t, "rules:\n  - field: amount\n    max: 30\n"),
+		}, io.Discard)
+		require.Equal(t, exitOK, code)
+
+		rows, err := testutil.ReadCSVFile(output)
+		require.NoError(t, err)
+		assert.Len(t, rows, 3) // header and the two products under 30
+
+		data, err := os.ReadFile(output + ".rejects.jsonl")
+		require.NoError(t, err)
+		var reject struct {
+			Record  struct{ Name string }
+			Reasons []string
+		}
+		require.NoError(t, json.Unmarshal(data, &reject))
+		assert.Equal(t, "Test Product 3", reject.Record.Name)
+		assert.Equal(t, []string{"amount: 39.99 is above the maximum of 30"}, reject.Reasons)
+
+		data, err = os.ReadFile(quality)
+		require.NoError(t, err)
+		var report validate.Report
+		require.NoError(t, json.Unmarshal(data, &report))
+		assert.Equal(t, 3, report.Products)
+		assert.Equal(t, 1, report.Rejected)
+	})
+
+	t.Run("writes rejects to -rejects", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		dir := t.TempDir()
+		rejects := filepath.Join(dir, "bad.jsonl")
+		code := run([]string{
+			"deep-scrape", "-url", server.URL + "/", "-output", filepath.Join(dir, "products.json"),
+			"-delay", "0", "-detail-delay", "0", "-cache-dir", "", "-no-checkpoint",
+			"-rules", writeRules(t, "rules:\n  - field: gtin\n    required: true\n"), "-rejects", rejects,
+		}, io.Discard)
+		assert.Equal(t, exitOK, code)
+
+		data, err := os.ReadFile(rejects)
+		require.NoError(t, err)
+		assert.Equal(t, 3, strings.Count(string(data), "\n"))
+	})
+
+	t.Run("rejects invalid flags", func(t *testing.T) {
+		assert.Equal(t, exitUsage, run([]string{"scrape", "-rejects", "bad.jsonl"}, io.Discard))
+		assert.Equal(t, exitUsage, run([]string{"deep-scrape", "-rules", writeRules(t, "rules: [{field: colour, required: true}]\n")}, io.Discard))
+	})
+}
@@ -0,0 +1,198 @@
+package crawler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"web-scraper/extract"
+)
+
+// checkpointVersion is bumped whenever the checkpoint format changes incompatibly
+const checkpointVersion = 1
+
+// DefaultCheckpointInterval is how often a running crawl saves its state
+const DefaultCheckpointInterval = 30 * time.Second
+
+// Checkpoint kinds, one per resumable runner
+const (
+	checkpointKindCrawl  = "crawl"
+	checkpointKindScrape = "scrape"
+)
+
+// Checkpoint is the on-disk state of an interrupted crawl or scrape. URLs in
+// Completed are never fetched again on resume; Pending URLs were queued but
+// not finished, including those that failed.
+type Checkpoint struct {
+	Version        int                     `json:"version"`
+	Kind           string                  `json:"kind"`
+	StartURL       string                  `json:"start_url"`
+	SavedAt        time.Time               `json:"saved_at"`
+	Completed      []string                `json:"completed"`
+	Pending        []string                `json:"pending"`                   // crawl frontier or listing pages
+	PendingDetails []string                `json:"pending_details,omitempty"` // product pages, scraper only
+	FoundLinks     []string                `json:"found_links,omitempty"`
+	SitemapURLs    []SitemapURL            `json:"sitemap_urls,omitempty"`
+	Products       []extract.ProductDetail `json:"products,omitempty"`
+	Pages          []extract.Page          `json:"pages,omitempty"` // crawler page records
+}
+
+// LoadCheckpoint reads a checkpoint written by SaveCheckpoint
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	cp := &Checkpoint{}
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %s has unsupported version %d", path, cp.Version)
+	}
+
+	return cp, nil
+}
+
+// SaveCheckpoint writes cp to path atomically, so a crash mid-write leaves
+// the previous checkpoint intact
+func SaveCheckpoint(path string, cp *Checkpoint) error {
+	cp.Version = checkpointVersion
+	cp.SavedAt = time.Now()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(cp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace checkpoint: %w", err)
+	}
+	return nil
+}
+
+// loadResumeCheckpoint loads the checkpoint at path and checks that it
+// belongs to a run of kind from startURL
+func loadResumeCheckpoint(path, kind, startURL string) (*Checkpoint, error) {
+	if path == "" {
+		return nil, errors.New("resume requires a checkpoint path")
+	}
+
+	cp, err := LoadCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	if cp.Kind != kind {
+		return nil, fmt.Errorf("checkpoint %s was written by a %s run, not %s", path, cp.Kind, kind)
+	}
+	if cp.StartURL != startURL {
+		return nil, fmt.Errorf("checkpoint %s is for %s, not %s", path, cp.StartURL, startURL)
+	}
+
+	log.Printf("[CHECKPOINT] Resuming from %s saved at %s (%d completed, %d pending)",
+		path, cp.SavedAt.Format(time.RFC3339), len(cp.Completed), len(cp.Pending)+len(cp.PendingDetails))
+	return cp, nil
+}
+
+// checkpointer saves a snapshot of a running crawl every interval and once
+// more when stopped
+type checkpointer struct {
+	path     string
+	snapshot func() *Checkpoint
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// startCheckpointer begins saving snapshots to path. It returns nil when path
+// is empty; a nil checkpointer is safe to stop.
+func startCheckpointer(path string, interval time.Duration, snapshot func() *Checkpoint) *checkpointer {
+	if path == "" {
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultCheckpointInterval
+	}
+
+	cp := &checkpointer{path: path, snapshot: snapshot, stop: make(chan struct{})}
+	cp.wg.Add(1)
+	go func() {
+		defer cp.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := cp.save(); err != nil {
+					log.Printf("[CHECKPOINT] %v", err)
+				}
+			case <-cp.stop:
+				return
+			}
+		}
+	}()
+	return cp
+}
+
+// save writes the current snapshot
+func (cp *checkpointer) save() error {
+	return SaveCheckpoint(cp.path, cp.snapshot())
+}
+
+// Stop ends the periodic saves and writes a final checkpoint. A complete run
+// has nothing left to resume, so its checkpoint is removed instead and a
+// later -resume can't pick up stale state.
+func (cp *checkpointer) Stop(complete bool) error {
+	if cp == nil {
+		return nil
+	}
+
+	close(cp.stop)
+	cp.wg.Wait()
+	if complete {
+		if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove checkpoint: %w", err)
+		}
+		return nil
+	}
+	return cp.save()
+}
+
+// sortedKeys returns the keys of set in lexical order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkpointSettings holds the checkpoint options of a crawler or scraper
+type checkpointSettings struct {
+	path     string
+	interval time.Duration
+	resume   bool
+}
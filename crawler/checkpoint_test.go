@@ -0,0 +1,214 @@
+package crawler
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+)
+
+// copyFile snapshots a checkpoint as if the process had died at this point
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(dst, data, 0o644))
+}
+
+func TestSaveCheckpoint(t *testing.T) {
+	t.Run("round trips through disk", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "run.checkpoint.json")
+		cp := &Checkpoint{
+			Kind:       checkpointKindCrawl,
+			StartURL:   "http://example.com/",
+			Completed:  []string{"http://example.com/"},
+			Pending:    []string{"http://example.com/a"},
+			FoundLinks: []string{"http://example.com/a"},
+		}
+		require.NoError(t, SaveCheckpoint(path, cp))
+
+		loaded, err := LoadCheckpoint(path)
+		require.NoError(t, err)
+		assert.Equal(t, checkpointVersion, loaded.Version)
+		assert.False(t, loaded.SavedAt.IsZero())
+		assert.Equal(t, cp.Completed, loaded.Completed)
+		assert.Equal(t, cp.Pending, loaded.Pending)
+		assert.Equal(t, cp.FoundLinks, loaded.FoundLinks)
+	})
+
+	t.Run("leaves no temporary files behind", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "run.checkpoint.json")
+		require.NoError(t, SaveCheckpoint(path, &Checkpoint{Kind: checkpointKindCrawl}))
+		require.NoError(t, SaveCheckpoint(path, &Checkpoint{Kind: checkpointKindCrawl}))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "run.checkpoint.json", entries[0].Name())
+	})
+
+	t.Run("write error", func(t *testing.T) {
+		err := SaveCheckpoint("/invalid/path/that/does/not/exist/run.json", &Checkpoint{})
+		assert.Error(t, err)
+	})
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadCheckpoint(filepath.Join(t.TempDir(), "missing.json"))
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects other versions", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "old.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"version": 99}`), 0o644))
+
+		_, err := LoadCheckpoint(path)
+		assert.ErrorContains(t, err, "unsupported version 99")
+	})
+
+	t.Run("rejects checkpoints of other runs", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "run.json")
+		require.NoError(t, SaveCheckpoint(path, &Checkpoint{Kind: checkpointKindCrawl, StartURL: "http://example.com/"}))
+
+		_, err := loadResumeCheckpoint(path, checkpointKindScrape, "http://example.com/")
+		assert.ErrorContains(t, err, "crawl run")
+
+		_, err = loadResumeCheckpoint(path, checkpointKindCrawl, "http://example.org/")
+		assert.ErrorContains(t, err, "is for http://example.com/")
+
+		_, err = loadResumeCheckpoint("", checkpointKindCrawl, "http://example.com/")
+		assert.Error(t, err)
+	})
+}
+
+func TestCrawlerCheckpoint(t *testing.T) {
+	server := testutil.CreateCountingServer(map[string]string{
+		"/":     `<html><body><a href="/a">A</a><a href="/slow">Slow</a></body></html>`,
+		"/a":    `<html><body><a href="/b">B</a></body></html>`,
+		"/b":    `<html><body>Page</body></html>`,
+		"/slow": `<html><body>Page</body></html>`,
+	})
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "crawl.checkpoint.json")
+	crashed := filepath.Join(dir, "crashed.checkpoint.json")
+
+	// Stall the first run on /slow and grab a periodic checkpoint meanwhile
+	release := server.Block("/slow")
+	defer release()
+
+	cfg := DefaultCrawlerConfig([]string{"127.0.0.1"}, 10)
+	cfg.CheckpointPath = path
+	cfg.CheckpointInterval = 10 * time.Millisecond
+
+	done := make(chan error, 1)
+	go func() { done <- NewWebCrawlerWithConfig(cfg).Crawl(server.URL + "/") }()
+
+	require.Eventually(t, func() bool {
+		cp, err := LoadCheckpoint(path)
+		return err == nil && len(cp.Pending) > 0 && cp.Pending[len(cp.Pending)-1] == server.URL+"/slow"
+	}, 5*time.Second, 10*time.Millisecond)
+	copyFile(t, path, crashed)
+
+	release()
+	require.NoError(t, <-done)
+
+	cp, err := LoadCheckpoint(crashed)
+	require.NoError(t, err)
+	assert.Contains(t, cp.Completed, server.URL+"/a")
+	assert.Contains(t, cp.Completed, server.URL+"/b")
+	assert.NotContains(t, cp.Completed, server.URL+"/slow")
+
+	// Resume from the mid-run checkpoint
+	server.Reset()
+	cfg.CheckpointPath = crashed
+	cfg.Resume = true
+	crawler := NewWebCrawlerWithConfig(cfg)
+	require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+	assert.Equal(t, 0, server.Count("/a"))
+	assert.Equal(t, 0, server.Count("/b"))
+	assert.Equal(t, 1, server.Count("/slow"))
+	assert.ElementsMatch(t, []string{server.URL + "/a", server.URL + "/b", server.URL + "/slow"}, crawler.GetFoundLinks())
+
+	// Pages recorded before the interruption come back from the checkpoint
+	var pages []string
+	for _, page := range crawler.GetPages() {
+		pages = append(pages, page.URL)
+	}
+	assert.ElementsMatch(t, []string{server.URL + "/", server.URL + "/a", server.URL + "/b", server.URL + "/slow"}, pages)
+
+	// Nothing is left to resume once the crawl completes
+	assert.False(t, testutil.FileExists(crashed))
+}
+
+func TestScraperCheckpoint(t *testing.T) {
+	server := testutil.CreateShopServer(t)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "scrape.checkpoint.json")
+	require.NoError(t, SaveCheckpoint(path, &Checkpoint{
+		Kind:           checkpointKindScrape,
+		StartURL:       server.URL + "/",
+		Completed:      []string{server.URL + "/product/test-product-1"},
+		Pending:        []string{server.URL + "/"},
+		PendingDetails: []string{server.URL + "/product/test-product-2"},
+		Products:       []extract.ProductDetail{{URL: server.URL + "/product/test-product-1", Name: "Test Product 1"}},
+	}))
+
+	cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+	cfg.CheckpointPath = path
+	cfg.Resume = true
+	scraper := NewScraperWithConfig(cfg)
+	require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+	assert.Equal(t, 1, server.Count("/"))
+	assert.Equal(t, 0, server.Count("/product/test-product-1"))
+	assert.Equal(t, 1, server.Count("/product/test-product-2"))
+	assert.Equal(t, 1, server.Count("/product/test-product-3"))
+
+	var urls []string
+	for _, product := range scraper.GetProducts() {
+		urls = append(urls, product.URL)
+	}
+	assert.ElementsMatch(t, []string{server.URL + "/product/test-product-1", server.URL + "/product/test-product-2", server.URL + "/product/test-product-3"}, urls)
+
+	assert.False(t, testutil.FileExists(path))
+}
+
+func TestScraperCheckpointPagination(t *testing.T) {
+	server := testutil.CreateMockServerWithRoutes(map[string]string{
+		"/":       testutil.MustGetFixture(t, "listing.html"),
+		"/page/2": testutil.MustGetFixture(t, "listing_page2.html"),
+	})
+	defer server.Close()
+
+	cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+	cfg.IgnoreRobotsTxt = true
+	scraper := NewScraperWithConfig(cfg)
+
+	// Snapshot right after the first page completes, before the second page's
+	// request may have started
+	var cp *Checkpoint
+	scraper.collector.OnScraped(func(r *colly.Response) {
+		if r.Request.URL.Path == "/" {
+			cp = scraper.snapshot(server.URL + "/")
+		}
+	})
+	require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+	require.NotNil(t, cp)
+	assert.Contains(t, cp.Completed, server.URL+"/")
+	assert.Contains(t, cp.Pending, server.URL+"/page/2")
+}
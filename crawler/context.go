@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// IncompleteError is returned by ScrapeContext and CrawlContext when their
+// context ends the run early. It tells how far the run got; the results
+// collected so far stay available from the Scraper or WebCrawler.
+type IncompleteError struct {
+	Err       error // the context's error, context.Canceled or context.DeadlineExceeded
+	Completed int   // pages fetched and processed
+	Pending   int   // pages queued but not finished
+	Collected int   // products scraped or links found
+}
+
+// newIncompleteError describes a run ended by err from its last snapshot
+func newIncompleteError(err error, cp *Checkpoint, collected int) *IncompleteError {
+	return &IncompleteError{
+		Err:       err,
+		Completed: len(cp.Completed),
+		Pending:   len(cp.Pending) + len(cp.PendingDetails),
+		Collected: collected,
+	}
+}
+
+func (e *IncompleteError) Error() string {
+	return fmt.Sprintf("run ended early: %v (%d pages completed, %d pending, %d collected)",
+		e.Err, e.Completed, e.Pending, e.Collected)
+}
+
+// Unwrap lets errors.Is match the context's error
+func (e *IncompleteError) Unwrap() error {
+	return e.Err
+}
+
+// abortTransport cancels requests in flight once abort is closed, which
+// colly can't do itself. It embeds the http.Transport so proxies and
+// timeouts are still configured on it directly. With a cookie jar it also
+// handles cookies, in the scope of the proxy each request goes through.
+type abortTransport struct {
+	*http.Transport
+	abort   <-chan struct{}
+	proxies *ProxyPool    // nil when Transport.Proxy is used as is
+	cookies *CookieJar    // nil leaves cookies to the client
+	login   *loginSession // nil when the site needs no login
+}
+
+// newAbortTransport creates a transport with the default settings that is
+// cancelled by abort
+func newAbortTransport(abort <-chan struct{}) *abortTransport {
+	return &abortTransport{
+		Transport: http.DefaultTransport.(*http.Transport).Clone(),
+		abort:     abort,
+	}
+}
+
+// setProxyPool sends requests through the proxies of pool
+func (t *abortTransport) setProxyPool(pool *ProxyPool) {
+	t.proxies = pool
+	t.Transport.Proxy = proxyFromContext
+}
+
+// setCookieJar keeps the cookies of requests in jar. The collectors using
+// the transport must have their own jar disabled.
+func (t *abortTransport) setCookieJar(jar *CookieJar) {
+	t.cookies = jar
+}
+
+// setLogin checks responses for an expired login session, logging in again
+// through session when one is found
+func (t *abortTransport) setLogin(session *loginSession) {
+	t.login = session
+}
+
+// RoundTrip sends req, cancelling it, including the body read, on abort
+func (t *abortTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	go func() {
+		select {
+		case <-t.abort:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	reqCtx := ctx
+	var proxy *poolProxy
+	scope := "" // of the cookies
+	if t.proxies != nil {
+		proxy = t.proxies.pick(req.URL.Hostname())
+		reqCtx = context.WithValue(ctx, proxyContextKey{}, proxy)
+		scope = proxy.url.Redacted()
+	}
+	out := req.WithContext(reqCtx)
+	if t.cookies != nil {
+		out.Header = req.Header.Clone()
+		for _, cookie := range t.cookies.cookiesFor(scope, req.URL) {
+			out.AddCookie(cookie)
+		}
+	}
+	start := time.Now()
+	resp, err := t.Transport.RoundTrip(out)
+	if proxy != nil {
+		resp, err = t.proxies.observe(proxy, start, resp, err)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if t.cookies != nil {
+		if cookies := resp.Cookies(); len(cookies) > 0 {
+			t.cookies.setCookies(scope, req.URL, cookies)
+		}
+	}
+	if t.login != nil {
+		if resp, err = t.login.check(req, resp, start); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelBody releases a request's context once its body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
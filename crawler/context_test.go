@@ -0,0 +1,110 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+)
+
+func TestScrapeContext(t *testing.T) {
+	scraperConfig := func() ScraperConfig {
+		cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+		cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+		cfg.IgnoreRobotsTxt = true
+		cfg.DrainTimeout = 10 * time.Second // Aborting must not wait for the drain
+		return cfg
+	}
+
+	t.Run("deadline aborts requests in flight", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+		release := server.Block("/product/test-product-1")
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		scraper := NewScraperWithConfig(scraperConfig())
+		start := time.Now()
+		err := scraper.ScrapeContext(ctx, server.URL+"/")
+		assert.Less(t, time.Since(start), 2*time.Second)
+
+		var incomplete *IncompleteError
+		require.ErrorAs(t, err, &incomplete)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.GreaterOrEqual(t, incomplete.Completed, 1)
+		assert.GreaterOrEqual(t, incomplete.Pending, 1)
+		assert.Equal(t, len(scraper.GetProducts()), incomplete.Collected)
+		assert.True(t, scraper.Stopped())
+	})
+
+	t.Run("cancelled context makes no requests", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := NewScraperWithConfig(scraperConfig()).ScrapeContext(ctx, server.URL+"/")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, server.Count("/"))
+	})
+
+	t.Run("finished run returns nil", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		scraper := NewScraperWithConfig(scraperConfig())
+		require.NoError(t, scraper.ScrapeContext(ctx, server.URL+"/"))
+		assert.Len(t, scraper.GetProducts(), 3)
+	})
+}
+
+func TestCrawlContext(t *testing.T) {
+	server := testutil.CreateCountingServer(map[string]string{
+		"/":     `<html><body><a href="/a">A</a><a href="/slow">Slow</a></body></html>`,
+		"/a":    `<html><body>A</body></html>`,
+		"/slow": `<html><body>Slow</body></html>`,
+	})
+	defer server.Close()
+	release := server.Block("/slow")
+	defer release()
+
+	cfg := DefaultCrawlerConfig([]string{"127.0.0.1"}, 10)
+	cfg.IgnoreRobotsTxt, cfg.IgnoreSitemaps = true, true
+	cfg.DrainTimeout = 10 * time.Second
+	crawler := NewWebCrawlerWithConfig(cfg)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for server.Count("/slow") == 0 {
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+	}()
+
+	err := crawler.CrawlContext(ctx, server.URL+"/")
+
+	var incomplete *IncompleteError
+	require.ErrorAs(t, err, &incomplete)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Equal(t, 2, incomplete.Completed)
+	assert.Equal(t, 1, incomplete.Pending)
+	assert.Equal(t, 2, incomplete.Collected)
+	assert.Contains(t, crawler.GetFoundLinks(), server.URL+"/a")
+}
+
+func TestIncompleteError(t *testing.T) {
+	err := &IncompleteError{Err: context.DeadlineExceeded, Completed: 3, Pending: 2, Collected: 7}
+	assert.Equal(t, "run ended early: context deadline exceeded (3 pages completed, 2 pending, 7 collected)", err.Error())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
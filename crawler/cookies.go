@@ -0,0 +1,407 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cookie is a cookie of a CookieJar as it is saved to disk
+type Cookie struct {
+	// Scope is the proxy the cookie was set through, with the password
+	// masked. It is empty for direct requests and for seed cookies, which
+	// every scope starts with.
+	Scope    string     `json:"scope,omitempty"`
+	Domain   string     `json:"domain"`
+	HostOnly bool       `json:"host_only,omitempty"` // not sent to subdomains
+	Path     string     `json:"path"`
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Expires  *time.Time `json:"expires,omitempty"` // nil for a session cookie
+	Secure   bool       `json:"secure,omitempty"`
+	HttpOnly bool       `json:"http_only,omitempty"`
+}
+
+// cookieKey identifies a cookie within a scope
+type cookieKey struct {
+	domain, path, name string
+}
+
+// CookieJar keeps the cookies of a run so they can be seeded from and saved
+// to a file. Each proxy gets a scope of its own, so a site never sees one
+// session come from several addresses. It is safe for concurrent use and may
+// be shared between collectors.
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies map[string]map[cookieKey]Cookie // by scope
+	jars    map[string]*cookiejar.Jar       // matching the cookies of a scope to URLs, built on first use
+	now     func() time.Time
+}
+
+// NewCookieJar creates an empty cookie jar
+func NewCookieJar() *CookieJar {
+	return &CookieJar{
+		cookies: make(map[string]map[cookieKey]Cookie),
+		jars:    make(map[string]*cookiejar.Jar),
+		now:     time.Now,
+	}
+}
+
+// LoadCookieJar creates a cookie jar seeded from filename, which holds the
+// JSON written by Save or a Netscape cookies.txt as exported by browsers and
+// curl. A missing file gives an empty jar, so the same file can be loaded
+// before and saved after each run.
+func LoadCookieJar(filename string) (*CookieJar, error) {
+	jar := NewCookieJar()
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cookies: %w", err)
+	}
+
+	var cookies []Cookie
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		err = json.Unmarshal(data, &cookies)
+	} else {
+		cookies, err = parseNetscapeCookies(string(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cookies %s: %w", filename, err)
+	}
+
+	now := jar.now()
+	for _, c := range cookies {
+		if c.Expires != nil && !c.Expires.After(now) {
+			continue
+		}
+		if c.Name == "" || c.Domain == "" {
+			return nil, fmt.Errorf("cookies %s: cookie %q needs a name and domain", filename, c.Name)
+		}
+		c.Domain = strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		jar.store(c.Scope, c)
+	}
+	return jar, nil
+}
+
+// Save writes the unexpired cookies to filename atomically, as a Netscape
+// cookies.txt when it ends in .txt and as JSON otherwise. Session cookies
+// are saved too.
+func (j *CookieJar) Save(filename string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create cookie file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if strings.EqualFold(filepath.Ext(filename), ".txt") {
+		err = writeNetscapeCookies(tmp, j.Cookies())
+	} else {
+		encoder := json.NewEncoder(tmp)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(j.Cookies())
+	}
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write cookies: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write cookies: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("failed to replace cookie file: %w", err)
+	}
+	return nil
+}
+
+// Cookies returns the unexpired cookies of every scope, ordered by scope,
+// domain, path and name
+func (j *CookieJar) Cookies() []Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := j.now()
+	var cookies []Cookie
+	for _, scope := range j.cookies {
+		for _, c := range scope {
+			if c.Expires == nil || c.Expires.After(now) {
+				cookies = append(cookies, c)
+			}
+		}
+	}
+	sort.Slice(cookies, func(a, b int) bool {
+		x, y := cookies[a], cookies[b]
+		if x.Scope != y.Scope {
+			return x.Scope < y.Scope
+		}
+		if x.Domain != y.Domain {
+			return x.Domain < y.Domain
+		}
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		return x.Name < y.Name
+	})
+	return cookies
+}
+
+// Scope returns the jar of one scope as an http.CookieJar, for clients
+// outside the collectors that should share its session
+func (j *CookieJar) Scope(scope string) http.CookieJar {
+	return scopedJar{jar: j, scope: scope}
+}
+
+// scopedJar is one scope of a CookieJar
+type scopedJar struct {
+	jar   *CookieJar
+	scope string
+}
+
+func (s scopedJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.jar.setCookies(s.scope, u, cookies)
+}
+
+func (s scopedJar) Cookies(u *url.URL) []*http.Cookie {
+	return s.jar.cookiesFor(s.scope, u)
+}
+
+// cookiesFor returns the cookies of scope to send to u
+func (j *CookieJar) cookiesFor(scope string, u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.jar(scope).Cookies(u)
+}
+
+// setCookies stores the cookies a response from u set in scope
+func (j *CookieJar) setCookies(scope string, u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jar := j.jar(scope)
+	jar.SetCookies(u, cookies)
+
+	host := strings.ToLower(u.Hostname())
+	now := j.now()
+	for _, hc := range cookies {
+		c := Cookie{Scope: scope, Domain: host, HostOnly: true, Name: hc.Name, Value: hc.Value, Secure: hc.Secure, HttpOnly: hc.HttpOnly}
+		if hc.Domain != "" {
+			domain := strings.ToLower(strings.TrimPrefix(hc.Domain, "."))
+			if domain != host && (net.ParseIP(host) != nil || !strings.HasSuffix(host, "."+domain)) {
+				continue // rejected by the jar too
+			}
+			c.Domain, c.HostOnly = domain, false
+		}
+		c.Path = hc.Path
+		if !strings.HasPrefix(c.Path, "/") {
+			c.Path = defaultCookiePath(u.Path)
+		}
+
+		key := cookieKey{c.Domain, c.Path, c.Name}
+		switch {
+		case hc.MaxAge < 0:
+			delete(j.cookies[scope], key)
+			continue
+		case hc.MaxAge > 0:
+			expires := now.Add(time.Duration(hc.MaxAge) * time.Second)
+			c.Expires = &expires
+		case !hc.Expires.IsZero():
+			if !hc.Expires.After(now) {
+				delete(j.cookies[scope], key)
+				continue
+			}
+			expires := hc.Expires
+			c.Expires = &expires
+		}
+		j.cookies[scope][key] = c
+	}
+}
+
+// jar returns the cookiejar of scope, building it from the stored cookies
+// on first use. A scope without cookies of its own starts with the seed
+// cookies. The caller holds j.mu.
+func (j *CookieJar) jar(scope string) *cookiejar.Jar {
+	if jar, ok := j.jars[scope]; ok {
+		return jar
+	}
+
+	jar, _ := cookiejar.New(nil) // never fails without options
+	if _, ok := j.cookies[scope]; !ok {
+		j.cookies[scope] = make(map[cookieKey]Cookie)
+		for key, c := range j.cookies[""] {
+			c.Scope = scope
+			j.cookies[scope][key] = c
+		}
+	}
+	now := j.now()
+	for _, c := range j.cookies[scope] {
+		if c.Expires != nil && !c.Expires.After(now) {
+			continue
+		}
+		jar.SetCookies(c.url(), []*http.Cookie{c.httpCookie()})
+	}
+	j.jars[scope] = jar
+	return jar
+}
+
+// shareSeed copies the seed cookies, such as those of a login, to every
+// other scope
+func (j *CookieJar) shareSeed() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for scope, cookies := range j.cookies {
+		if scope == "" {
+			continue
+		}
+		jar := j.jars[scope]
+		for key, c := range j.cookies[""] {
+			c.Scope = scope
+			cookies[key] = c
+			if jar != nil {
+				jar.SetCookies(c.url(), []*http.Cookie{c.httpCookie()})
+			}
+		}
+	}
+}
+
+// store adds c to scope, replacing a cookie of the same domain, path and name
+func (j *CookieJar) store(scope string, c Cookie) {
+	if j.cookies[scope] == nil {
+		j.cookies[scope] = make(map[cookieKey]Cookie)
+	}
+	j.cookies[scope][cookieKey{c.Domain, c.Path, c.Name}] = c
+}
+
+// url is a URL the cookie was set from
+func (c Cookie) url() *url.URL {
+	scheme := "http"
+	if c.Secure {
+		scheme = "https"
+	}
+	return &url.URL{Scheme: scheme, Host: c.Domain, Path: c.Path}
+}
+
+// httpCookie is the Set-Cookie that recreates c
+func (c Cookie) httpCookie() *http.Cookie {
+	hc := &http.Cookie{Name: c.Name, Value: c.Value, Path: c.Path, Secure: c.Secure, HttpOnly: c.HttpOnly}
+	if !c.HostOnly {
+		hc.Domain = c.Domain
+	}
+	if c.Expires != nil {
+		hc.Expires = *c.Expires
+	}
+	return hc
+}
+
+// defaultCookiePath is the path of a cookie set without one from a request
+// to path, as defined by RFC 6265 section 5.1.4
+func defaultCookiePath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+// netscapeScope marks the scope of the cookies that follow in a cookies.txt.
+// Other readers skip it as a comment.
+const netscapeScope = "# scope: "
+
+// parseNetscapeCookies parses a Netscape cookies.txt: one cookie per line
+// with tab-separated domain, subdomain flag, path, secure flag, expiry in
+// Unix seconds, name and value
+func parseNetscapeCookies(data string) ([]Cookie, error) {
+	var cookies []Cookie
+	scope := ""
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := false
+		switch {
+		case strings.HasPrefix(line, netscapeScope):
+			scope = strings.TrimSpace(strings.TrimPrefix(line, netscapeScope))
+			continue
+		case strings.HasPrefix(line, "#HttpOnly_"):
+			line, httpOnly = strings.TrimPrefix(line, "#HttpOnly_"), true
+		case strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#"):
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", n, len(fields))
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", n, fields[4])
+		}
+		c := Cookie{
+			Scope:    scope,
+			Domain:   fields[0],
+			HostOnly: !strings.EqualFold(fields[1], "TRUE") && !strings.HasPrefix(fields[0], "."),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		if expiry > 0 {
+			expires := time.Unix(expiry, 0).UTC()
+			c.Expires = &expires
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, scanner.Err()
+}
+
+// writeNetscapeCookies writes cookies, ordered by scope, as a cookies.txt
+func writeNetscapeCookies(w io.Writer, cookies []Cookie) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	scope := ""
+	for _, c := range cookies {
+		if c.Scope != scope {
+			scope = c.Scope
+			fmt.Fprintln(bw, netscapeScope+scope)
+		}
+		domain, prefix := c.Domain, ""
+		if !c.HostOnly {
+			domain = "." + domain
+		}
+		if c.HttpOnly {
+			prefix = "#HttpOnly_"
+		}
+		var expiry int64
+		if c.Expires != nil {
+			expiry = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s%s\t%s\t%s\t%s\t%d\t%s\t%s\n", prefix, domain, netscapeFlag(!c.HostOnly), c.Path,
+			netscapeFlag(c.Secure), expiry, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
+
+// netscapeFlag is the cookies.txt form of b
+func netscapeFlag(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
@@ -0,0 +1,226 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+)
+
+// cookieShop serves a shop of one product that sets a session cookie on its
+// listing page and refuses requests without the cookies it requires
+type cookieShop struct {
+	require []string // cookie names every page needs
+	mu      sync.Mutex
+	refused []string
+}
+
+func (s *cookieShop) serve(t *testing.T) http.Handler {
+	product := testutil.MustGetFixture(t, "product_variable.html")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := s.require
+		if r.URL.Path != "/" {
+			required = append(required, "session")
+		}
+		for _, name := range required {
+			if _, err := r.Cookie(name); err != nil {
+				s.mu.Lock()
+				s.refused = append(s.refused, r.URL.Path)
+				s.mu.Unlock()
+				http.Error(w, "cookie "+name+" required", http.StatusForbidden)
+				return
+			}
+		}
+
+		switch r.URL.Path {
+		case "/":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/", HttpOnly: true})
+			http.SetCookie(w, &http.Cookie{Name: "consent", Value: "yes", Path: "/", MaxAge: 3600})
+			w.Write([]byte(`<html><body><ul><li class="product"><a class="woocommerce-LoopProduct-link" href="/product/hoodie">Hoodie</a></li></ul></body></html>`))
+		case "/product/hoodie":
+			w.Write([]byte(product))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestScraperCookies(t *testing.T) {
+	scrape := func(t *testing.T, shop *cookieShop, jar *CookieJar) *Scraper {
+		server := testutil.CreateMockServer(shop.serve(t))
+		t.Cleanup(server.Close)
+		cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+		cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+		cfg.IgnoreRobotsTxt = true
+		cfg.Cookies = jar
+		scraper := NewScraperWithConfig(cfg)
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+		return scraper
+	}
+
+	t.Run("sends the listing's cookies with detail pages", func(t *testing.T) {
+		shop := &cookieShop{}
+		scraper := scrape(t, shop, nil)
+		assert.Len(t, scraper.GetProducts(), 1)
+		assert.Empty(t, shop.refused)
+	})
+
+	t.Run("seeds the run from a file and saves it", func(t *testing.T) {
+		seed := filepath.Join(t.TempDir(), "cookies.json")
+		require.NoError(t, os.WriteFile(seed, []byte(`[{"domain": "127.0.0.1", "path": "/", "name": "region", "value": "de"}]`), 0o644))
+		jar, err := LoadCookieJar(seed)
+		require.NoError(t, err)
+
+		shop := &cookieShop{require: []string{"region"}}
+		scraper := scrape(t, shop, jar)
+		assert.Len(t, scraper.GetProducts(), 1)
+		assert.Empty(t, shop.refused)
+
+		require.NoError(t, jar.Save(seed))
+		saved, err := LoadCookieJar(seed)
+		require.NoError(t, err)
+		var names []string
+		for _, c := range saved.Cookies() {
+			names = append(names, c.Name+"="+c.Value)
+			assert.Equal(t, "127.0.0.1", c.Domain)
+		}
+		assert.Equal(t, []string{"consent=yes", "region=de", "session=abc123"}, names)
+	})
+
+	t.Run("without the seed the shop refuses", func(t *testing.T) {
+		shop := &cookieShop{require: []string{"region"}}
+		scraper := scrape(t, shop, NewCookieJar())
+		assert.Empty(t, scraper.GetProducts())
+		assert.Equal(t, []string{"/"}, shop.refused)
+	})
+}
+
+func TestCookieJarProxyScopes(t *testing.T) {
+	// The server gives each visitor without a cookie an id of its own
+	var mu sync.Mutex
+	issued := 0
+	server := testutil.CreateMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("visitor"); err != nil {
+			mu.Lock()
+			issued++
+			http.SetCookie(w, &http.Cookie{Name: "visitor", Value: fmt.Sprint(issued)})
+			mu.Unlock()
+		}
+	}))
+	defer server.Close()
+	first := testutil.CreateProxyServer()
+	defer first.Close()
+	second := testutil.CreateProxyServer()
+	defer second.Close()
+
+	pool, err := NewProxyPool(ProxyPoolConfig{Proxies: []Proxy{
+		{URL: strings.Replace(first.URL, "://", "://user:secret@", 1)},
+		{URL: second.URL},
+	}})
+	require.NoError(t, err)
+	jar := NewCookieJar()
+	transport := newAbortTransport(make(chan struct{}))
+	transport.setProxyPool(pool)
+	transport.setCookieJar(jar)
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 20; i++ {
+		resp, err := client.Get(server.URL + "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+	require.Positive(t, first.Requests())
+	require.Positive(t, second.Requests())
+
+	// One visitor per proxy, each keeping its own cookie
+	assert.Equal(t, 2, issued)
+	cookies := jar.Cookies()
+	require.Len(t, cookies, 2)
+	assert.NotEqual(t, cookies[0].Value, cookies[1].Value)
+	scopes := []string{cookies[0].Scope, cookies[1].Scope}
+	assert.ElementsMatch(t, []string{strings.Replace(first.URL, "://", "://user:xxxxx@", 1), second.URL}, scopes)
+}
+
+func TestCookieJarFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("a missing file gives an empty jar", func(t *testing.T) {
+		jar, err := LoadCookieJar(filepath.Join(dir, "missing.json"))
+		require.NoError(t, err)
+		assert.Empty(t, jar.Cookies())
+	})
+
+	t.Run("reads a browser's cookies.txt", func(t *testing.T) {
+		path := filepath.Join(dir, "browser.txt")
+		expires := time.Now().Add(24 * time.Hour).Unix()
+		require.NoError(t, os.WriteFile(path, []byte(fmt.Sprintf("# Netscape HTTP Cookie File\n\n"+
+			".shop.example\tTRUE\t/\tTRUE\t%d\tcurrency\tEUR\n"+
+			"#HttpOnly_shop.example\tFALSE\t/account\tFALSE\t0\tsid\txyz\n"+
+			"shop.example\tFALSE\t/\tFALSE\t1\texpired\tgone\n", expires)), 0o644))
+
+		jar, err := LoadCookieJar(path)
+		require.NoError(t, err)
+		cookies := jar.Cookies()
+		require.Len(t, cookies, 2)
+		assert.Equal(t, "shop.example", cookies[0].Domain)
+		assert.False(t, cookies[0].HostOnly)
+		assert.True(t, cookies[0].Secure)
+		assert.Equal(t, expires, cookies[0].Expires.Unix())
+		assert.Equal(t, "/account", cookies[1].Path)
+		assert.True(t, cookies[1].HostOnly)
+		assert.True(t, cookies[1].HttpOnly)
+		assert.Nil(t, cookies[1].Expires)
+
+		// Seed cookies are sent to every subdomain they match
+		sent := jar.Scope("").Cookies(mustParseURL(t, "https://www.shop.example/account/orders"))
+		assert.Len(t, sent, 1, "sid is host-only")
+	})
+
+	t.Run("round-trips both formats with scopes", func(t *testing.T) {
+		jar := NewCookieJar()
+		jar.Scope("").SetCookies(mustParseURL(t, "https://shop.example/cart/add"), []*http.Cookie{
+			{Name: "cart", Value: "1"},
+			{Name: "currency", Value: "EUR", Domain: "shop.example", Path: "/", MaxAge: 3600},
+			{Name: "other", Value: "x", Domain: "elsewhere.example"},
+		})
+		jar.Scope("http://proxy.example:8080").SetCookies(mustParseURL(t, "https://shop.example/"), []*http.Cookie{
+			{Name: "cart", Value: "2", Path: "/"},
+			{Name: "currency", Value: "", Domain: "shop.example", Path: "/", MaxAge: -1},
+		})
+		want := jar.Cookies()
+		require.Len(t, want, 4, "the proxy scope starts with the seed and deletes currency")
+		assert.Equal(t, "/cart", want[1].Path, "the default path of /cart/add")
+
+		for _, name := range []string{"cookies.json", "cookies.txt"} {
+			path := filepath.Join(dir, name)
+			require.NoError(t, jar.Save(path))
+			loaded, err := LoadCookieJar(path)
+			require.NoError(t, err)
+			got := loaded.Cookies()
+			require.Len(t, got, len(want), name)
+			for i := range want {
+				assert.Equal(t, want[i].Scope, got[i].Scope, name)
+				assert.Equal(t, want[i].Domain, got[i].Domain, name)
+				assert.Equal(t, want[i].Path, got[i].Path, name)
+				assert.Equal(t, want[i].Value, got[i].Value, name)
+				assert.Equal(t, want[i].HostOnly, got[i].HostOnly, name)
+				assert.Equal(t, want[i].Expires == nil, got[i].Expires == nil, name)
+			}
+		}
+	})
+
+	t.Run("rejects a malformed file", func(t *testing.T) {
+		path := filepath.Join(dir, "bad.txt")
+		require.NoError(t, os.WriteFile(path, []byte("shop.example\tFALSE\t/\n"), 0o644))
+		_, err := LoadCookieJar(path)
+		assert.ErrorContains(t, err, "line 1")
+	})
+}
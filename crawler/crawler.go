@@ -0,0 +1,540 @@
+// Package crawler fetches product listings, product detail pages and site
+// links with colly, honouring robots.txt, retries, checkpoints and cancellation.
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"web-scraper/export"
+	"web-scraper/extract"
+)
+
+// DefaultMaxPages is how many pages NewWebCrawler visits unless told otherwise
+const DefaultMaxPages = 10
+
+// WebCrawler implements a basic web crawler that follows links
+type WebCrawler struct {
+	collector    *colly.Collector
+	visitedURLs  map[string]bool
+	foundLinks   []string
+	mu           sync.Mutex
+	maxPages     int
+	pagesVisited int
+	failed       int
+	policy       *RobotsPolicy // nil when robots.txt is ignored
+	robots       *robotsGate
+	sitemaps     *SitemapReader // nil when sitemaps are ignored
+	sitemapURLs  []SitemapURL
+	completed    map[string]bool // pages fetched and parsed, skipped on resume
+	checkpoint   checkpointSettings
+	retries      *retrier
+	stopper      *stopper
+	pages        []extract.Page
+	exporter     export.Exporter // nil keeps page records in memory
+	exportErr    error           // first failed export
+	urls         extract.URLPolicy
+	headers      *headerRotator
+}
+
+// CrawlerConfig holds the tunable settings for a WebCrawler
+type CrawlerConfig struct {
+	AllowedDomains  []string
+	MaxPages        int
+	MaxDepth        int
+	Parallelism     int // values above 1 switch the collector to async mode
+	Delay           time.Duration
+	IgnoreRobotsTxt bool
+	IgnoreSitemaps  bool
+	Retry           RetryPolicy
+	DrainTimeout    time.Duration // how long Stop waits for in-flight requests, 0 selects DefaultDrainTimeout
+	// CheckpointPath is where the crawl state is saved periodically, empty disables checkpoints
+	CheckpointPath     string
+	CheckpointInterval time.Duration // 0 selects DefaultCheckpointInterval
+	Resume             bool          // continue from the checkpoint at CheckpointPath
+	// Exporter receives the record of each visited page instead of the
+	// crawler keeping it; GetPages is then empty
+	Exporter export.Exporter
+	URLs     extract.URLPolicy // canonicalizes links before they are deduplicated
+	// Headers picks the browser profiles the crawler poses as; the zero
+	// value picks one from the whole catalogue for the run
+	Headers extract.HeaderPolicy
+}
+
+// DefaultCrawlerConfig returns the configuration used by NewWebCrawler
+func DefaultCrawlerConfig(allowedDomains []string, maxPages int) CrawlerConfig {
+	return CrawlerConfig{
+		AllowedDomains: allowedDomains,
+		MaxPages:       maxPages,
+		MaxDepth:       3,
+		Retry:          DefaultRetryPolicy(),
+	}
+}
+
+// NewWebCrawler creates a new web crawler. It starts from
+// DefaultCrawlerConfig with DefaultMaxPages and applies opts, e.g.
+//
+//	NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(100))
+func NewWebCrawler(opts ...Option) *WebCrawler {
+	cfg := DefaultCrawlerConfig(nil, DefaultMaxPages)
+	applyCrawlerOptions(&cfg, opts)
+	return NewWebCrawlerWithConfig(cfg)
+}
+
+// NewWebCrawlerWithConfig creates a new web crawler from an explicit configuration
+func NewWebCrawlerWithConfig(cfg CrawlerConfig) *WebCrawler {
+	stopper := newStopper(cfg.DrainTimeout)
+	wc := &WebCrawler{
+		visitedURLs: make(map[string]bool),
+		foundLinks:  make([]string, 0),
+		maxPages:    cfg.MaxPages,
+		completed:   make(map[string]bool),
+		retries:     newRetrier(cfg.Retry, stopper.done()),
+		stopper:     stopper,
+		exporter:    cfg.Exporter,
+		urls:        cfg.URLs,
+		headers:     newHeaderRotator(cfg.Headers),
+		checkpoint: checkpointSettings{
+			path:     cfg.CheckpointPath,
+			interval: cfg.CheckpointInterval,
+			resume:   cfg.Resume,
+		},
+	}
+
+	wc.collector = colly.NewCollector(
+		colly.AllowedDomains(cfg.AllowedDomains...),
+		colly.MaxDepth(cfg.MaxDepth),
+		colly.Async(cfg.Parallelism > 1),
+	)
+	wc.collector.WithTransport(newAbortTransport(stopper.aborted))
+
+	if !cfg.IgnoreRobotsTxt {
+		wc.policy = NewRobotsPolicy(robotsUserAgent)
+	}
+
+	// Only install a rate limit when one was requested or robots.txt asks for one
+	wc.robots = applyLimit(wc.collector, wc.policy, colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: cfg.Parallelism,
+		Delay:       cfg.Delay,
+	})
+
+	if !cfg.IgnoreSitemaps {
+		wc.sitemaps = NewSitemapReader(cfg.MaxPages, cfg.AllowedDomains)
+		wc.sitemaps.stop = wc.stopper.done()
+	}
+
+	wc.retries.attach(wc.collector)
+	wc.setupCallbacks()
+	return wc
+}
+
+func (wc *WebCrawler) setupCallbacks() {
+	// Log each request
+	wc.collector.OnRequest(func(r *colly.Request) {
+		if !wc.stopper.allow(r) {
+			return
+		}
+		if wc.robots != nil && !wc.robots.allow(r) {
+			return
+		}
+		wc.headers.page(r)
+
+		// Retries of a page don't count towards MaxPages
+		if attempt := retryAttempt(r); attempt > 0 {
+			fmt.Printf("[retry %d] Crawling: %s\n", attempt, r.URL)
+			return
+		}
+
+		wc.mu.Lock()
+		wc.pagesVisited++
+		current := wc.pagesVisited
+		wc.mu.Unlock()
+		
+		fmt.Printf("[%d] Crawling: %s\n", current, r.URL)
+	})
+
+	// Find and follow all links
+	wc.collector.OnHTML("a[href]", func(e *colly.HTMLElement) {
+		link := e.Attr("href")
+		
+		// Skip empty links, anchors, and javascript
+		if link == "" || strings.HasPrefix(link, "#") || strings.HasPrefix(link, "javascript:") {
+			return
+		}
+
+		// Convert to a canonical absolute URL, skipping mailto: and the like
+		normalizedURL, ok := wc.urls.Canonicalize(e.Request.AbsoluteURL(link))
+		if !ok {
+			return
+		}
+
+		wc.mu.Lock()
+		// Check if we've reached max pages
+		if wc.pagesVisited >= wc.maxPages {
+			wc.mu.Unlock()
+			return
+		}
+		
+		// Track unique links
+		if !wc.visitedURLs[normalizedURL] {
+			wc.visitedURLs[normalizedURL] = true
+			wc.foundLinks = append(wc.foundLinks, normalizedURL)
+			wc.mu.Unlock()
+			
+			// Visit the link
+			e.Request.Visit(normalizedURL)
+		} else {
+			wc.mu.Unlock()
+		}
+	})
+
+	// Record every page: HTML pages with their metadata, other content with
+	// the response fields only
+	wc.collector.OnHTML("html", func(e *colly.HTMLElement) {
+		page := extract.ReadPage(e.Response, e.DOM, wc.urls)
+		wc.mu.Lock()
+		wc.record(page)
+		wc.mu.Unlock()
+	})
+	wc.collector.OnResponse(func(r *colly.Response) {
+		if !strings.Contains(strings.ToLower(r.Headers.Get("Content-Type")), "html") {
+			page := extract.ReadPage(r, nil, wc.urls)
+			wc.mu.Lock()
+			wc.record(page)
+			wc.mu.Unlock()
+		}
+	})
+
+	// Handle errors
+	wc.collector.OnError(func(r *colly.Response, err error) {
+		log.Printf("Error crawling %s: %v", r.Request.URL, err)
+		if wc.retries.retry(wc.collector, r, err) {
+			return
+		}
+
+		page := extract.ReadPage(r, nil, wc.urls)
+		page.Error = err.Error()
+
+		wc.mu.Lock()
+		wc.record(page)
+		wc.failed++
+		wc.mu.Unlock()
+	})
+
+	// Log when a page is fully scraped
+	wc.collector.OnScraped(func(r *colly.Response) {
+		if normalizedURL, ok := wc.urls.Canonicalize(r.Request.URL.String()); ok {
+			wc.mu.Lock()
+			wc.completed[normalizedURL] = true
+			wc.mu.Unlock()
+		}
+		fmt.Printf("Completed: %s\n", r.Request.URL)
+	})
+}
+
+// Crawl starts crawling from the given URL
+func (wc *WebCrawler) Crawl(startURL string) error {
+	return wc.CrawlContext(context.Background(), startURL)
+}
+
+// CrawlContext is Crawl bound to ctx. Once ctx is done, requests in flight
+// are aborted, no new ones are made, and it returns an *IncompleteError
+// wrapping ctx.Err(). The links found so far stay available.
+func (wc *WebCrawler) CrawlContext(ctx context.Context, startURL string) error {
+	release := wc.stopper.watch(ctx)
+	defer release()
+
+	err := wc.crawl(startURL)
+	if wc.stopper.wasAborted() {
+		return newIncompleteError(ctx.Err(), wc.snapshot(startURL), len(wc.GetFoundLinks()))
+	}
+	return err
+}
+
+// crawl runs the crawl from startURL until it finishes or is stopped
+func (wc *WebCrawler) crawl(startURL string) error {
+	var resumed *Checkpoint
+	if wc.checkpoint.resume {
+		cp, err := loadResumeCheckpoint(wc.checkpoint.path, checkpointKindCrawl, startURL)
+		if err != nil {
+			return err
+		}
+		wc.restore(cp)
+		resumed = cp
+	}
+
+	if err := checkStartURL(wc.policy, startURL); err != nil {
+		return err
+	}
+
+	// Read sitemaps up front so poorly linked pages still get crawled.
+	// A resumed crawl already has them in its frontier.
+	var seeds []SitemapURL
+	if resumed == nil {
+		seeds = wc.readSitemaps(startURL)
+	}
+
+	checkpoints := startCheckpointer(wc.checkpoint.path, wc.checkpoint.interval, func() *Checkpoint {
+		return wc.snapshot(startURL)
+	})
+
+	// Synchronous collectors do all their work inside Visit, so the whole
+	// crawl runs under the stopper
+	var visitErr error
+	drained := wc.stopper.wait(func() {
+		if !wc.isCompleted(startURL) {
+			visitURL := wc.canonical(startURL)
+			if err := wc.retries.visitError(visitURL, wc.collector.Visit(visitURL)); err != nil {
+				visitErr = err
+				return
+			}
+		}
+
+		wc.seedFrontier(seeds)
+		if resumed != nil {
+			wc.visitPending(resumed.Pending)
+		}
+
+		// Wait for outstanding requests when running asynchronously, and
+		// for retries either way
+		wc.retries.wait(wc.collector)
+	})
+	if drained && visitErr != nil {
+		checkpoints.Stop(false)
+		return visitErr
+	}
+
+	if err := checkpoints.Stop(wc.complete()); err != nil {
+		return err
+	}
+	return wc.exportError()
+}
+
+// record keeps page, or hands it to the exporter when one is set. The caller
+// must hold wc.mu.
+func (wc *WebCrawler) record(page extract.Page) {
+	if wc.exporter == nil {
+		wc.pages = append(wc.pages, page)
+		return
+	}
+	if err := wc.exporter.Export(page); err != nil && wc.exportErr == nil {
+		log.Printf("[EXPORT] %v", err)
+		wc.exportErr = err
+	}
+}
+
+// complete reports whether the crawl ran to the end without failed requests
+// or exports
+func (wc *WebCrawler) complete() bool {
+	return !wc.Stopped() && wc.GetFailedRequests() == 0 && wc.exportError() == nil
+}
+
+// exportError returns the first error from the exporter
+func (wc *WebCrawler) exportError() error {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	if wc.exportErr != nil {
+		return fmt.Errorf("failed to export pages: %w", wc.exportErr)
+	}
+	return nil
+}
+
+// Stop asks a running Crawl to finish early. No new pages are requested,
+// in-flight ones get DrainTimeout to complete, and Crawl then returns with
+// the links found so far. Unvisited links stay pending in the checkpoint.
+func (wc *WebCrawler) Stop() {
+	wc.stopper.stop()
+}
+
+// Stopped reports whether Stop was called
+func (wc *WebCrawler) Stopped() bool {
+	return wc.stopper.stopped()
+}
+
+// restore loads the state of an interrupted crawl. Pages already crawled
+// count towards MaxPages.
+func (wc *WebCrawler) restore(cp *Checkpoint) {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	for _, link := range cp.Completed {
+		wc.completed[link] = true
+	}
+	for _, link := range cp.FoundLinks {
+		if !wc.visitedURLs[link] {
+			wc.visitedURLs[link] = true
+			wc.foundLinks = append(wc.foundLinks, link)
+		}
+	}
+	wc.sitemapURLs = cp.SitemapURLs
+	wc.pagesVisited = len(cp.Completed)
+	for _, page := range cp.Pages {
+		wc.record(page)
+	}
+}
+
+// snapshot captures the crawl state for a checkpoint
+func (wc *WebCrawler) snapshot(startURL string) *Checkpoint {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	cp := &Checkpoint{
+		Kind:        checkpointKindCrawl,
+		StartURL:    startURL,
+		Completed:   sortedKeys(wc.completed),
+		Pending:     make([]string, 0),
+		FoundLinks:  append([]string(nil), wc.foundLinks...),
+		SitemapURLs: append([]SitemapURL(nil), wc.sitemapURLs...),
+	}
+	for _, link := range wc.foundLinks {
+		if !wc.completed[link] {
+			cp.Pending = append(cp.Pending, link)
+		}
+	}
+	// Pages are only included once completed, so none is recorded twice
+	for _, page := range wc.pages {
+		if normalizedURL, ok := wc.urls.Canonicalize(page.URL); ok && wc.completed[normalizedURL] {
+			cp.Pages = append(cp.Pages, page)
+		}
+	}
+	return cp
+}
+
+// isCompleted reports whether rawURL was crawled before the last checkpoint
+func (wc *WebCrawler) isCompleted(rawURL string) bool {
+	normalizedURL, ok := wc.urls.Canonicalize(rawURL)
+	if !ok {
+		return false
+	}
+
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return wc.completed[normalizedURL]
+}
+
+// visitPending requeues the frontier of a resumed crawl. The link depth of
+// these pages is not saved, so they are followed as if found on the start page.
+func (wc *WebCrawler) visitPending(pending []string) {
+	for _, link := range pending {
+		wc.mu.Lock()
+		full := wc.pagesVisited >= wc.maxPages
+		wc.mu.Unlock()
+		if full {
+			return
+		}
+
+		wc.collector.Visit(link)
+	}
+}
+
+// readSitemaps collects the page URLs listed in the site's sitemaps
+func (wc *WebCrawler) readSitemaps(startURL string) []SitemapURL {
+	if wc.sitemaps == nil {
+		return nil
+	}
+
+	base, err := url.Parse(startURL)
+	if err != nil {
+		return nil
+	}
+
+	seeds := wc.sitemaps.Read(DiscoverSitemaps(base, wc.policy))
+	if len(seeds) > 0 {
+		fmt.Printf("Found %d URLs in sitemaps\n", len(seeds))
+	}
+
+	wc.mu.Lock()
+	wc.sitemapURLs = seeds
+	wc.mu.Unlock()
+	return seeds
+}
+
+// seedFrontier visits the sitemap URLs not already reached by following links
+func (wc *WebCrawler) seedFrontier(seeds []SitemapURL) {
+	for _, seed := range seeds {
+		normalizedURL, ok := wc.urls.Canonicalize(seed.Loc)
+		if !ok {
+			continue
+		}
+
+		wc.mu.Lock()
+		if wc.pagesVisited >= wc.maxPages {
+			wc.mu.Unlock()
+			return
+		}
+		if wc.visitedURLs[normalizedURL] {
+			wc.mu.Unlock()
+			continue
+		}
+		wc.visitedURLs[normalizedURL] = true
+		wc.foundLinks = append(wc.foundLinks, normalizedURL)
+		wc.mu.Unlock()
+
+		wc.collector.Visit(normalizedURL)
+	}
+}
+
+// canonical returns the canonical form of rawURL, or rawURL when it has none
+func (wc *WebCrawler) canonical(rawURL string) string {
+	if canonicalURL, ok := wc.urls.Canonicalize(rawURL); ok {
+		return canonicalURL
+	}
+	return rawURL
+}
+
+// GetFoundLinks returns all discovered links
+func (wc *WebCrawler) GetFoundLinks() []string {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	
+	// Return a copy
+	links := make([]string, len(wc.foundLinks))
+	copy(links, wc.foundLinks)
+	return links
+}
+
+// GetPages returns a copy of the records of the pages crawled so far
+func (wc *WebCrawler) GetPages() []extract.Page {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return append([]extract.Page(nil), wc.pages...)
+}
+
+// GetPagesVisited returns the number of pages visited
+func (wc *WebCrawler) GetPagesVisited() int {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return wc.pagesVisited
+}
+
+// GetFailedRequests returns the number of requests that ended in an error
+func (wc *WebCrawler) GetFailedRequests() int {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+	return wc.failed
+}
+
+// GetBlockedURLs returns the URLs skipped because of robots.txt
+func (wc *WebCrawler) GetBlockedURLs() []BlockedURL {
+	if wc.policy == nil {
+		return []BlockedURL{}
+	}
+	return wc.policy.Blocked()
+}
+
+// GetSitemapURLs returns the pages listed in the site's sitemaps, with their lastmod dates
+func (wc *WebCrawler) GetSitemapURLs() []SitemapURL {
+	wc.mu.Lock()
+	defer wc.mu.Unlock()
+
+	urls := make([]SitemapURL, len(wc.sitemapURLs))
+	copy(urls, wc.sitemapURLs)
+	return urls
+}
@@ -1,6 +1,8 @@
-package main
+package crawler
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -10,11 +12,15 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/export"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
 )
 
 func TestNewWebCrawler(t *testing.T) {
 	t.Run("initializes with single domain", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 10)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(10))
 
 		assert.NotNil(t, crawler)
 		assert.NotNil(t, crawler.collector)
@@ -26,7 +32,7 @@ func TestNewWebCrawler(t *testing.T) {
 
 	t.Run("initializes with multiple domains", func(t *testing.T) {
 		domains := []string{"example.com", "test.com", "scraper.com"}
-		crawler := NewWebCrawler(domains, 20)
+		crawler := NewWebCrawler(WithAllowedDomains(domains...), WithMaxPages(20))
 
 		assert.NotNil(t, crawler)
 		assert.Equal(t, 20, crawler.maxPages)
@@ -36,13 +42,13 @@ func TestNewWebCrawler(t *testing.T) {
 		testCases := []int{1, 5, 10, 50, 100}
 
 		for _, maxPages := range testCases {
-			crawler := NewWebCrawler([]string{"example.com"}, maxPages)
+			crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(maxPages))
 			assert.Equal(t, maxPages, crawler.maxPages)
 		}
 	})
 
 	t.Run("initializes empty slices and maps", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 10)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(10))
 
 		assert.Empty(t, crawler.visitedURLs)
 		assert.Empty(t, crawler.foundLinks)
@@ -53,7 +59,7 @@ func TestNewWebCrawler(t *testing.T) {
 
 func TestGetFoundLinks(t *testing.T) {
 	t.Run("returns discovered links", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 10)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(10))
 
 		// Manually add some links
 		crawler.mu.Lock()
@@ -72,7 +78,7 @@ func TestGetFoundLinks(t *testing.T) {
 	})
 
 	t.Run("returns copy not reference", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 10)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(10))
 
 		crawler.mu.Lock()
 		crawler.foundLinks = []string{"http://example.com/page1"}
@@ -90,7 +96,7 @@ func TestGetFoundLinks(t *testing.T) {
 	})
 
 	t.Run("returns empty slice when no links", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 10)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(10))
 
 		links := crawler.GetFoundLinks()
 		assert.NotNil(t, links)
@@ -100,7 +106,7 @@ func TestGetFoundLinks(t *testing.T) {
 
 func TestGetPagesVisited(t *testing.T) {
 	t.Run("counts pages correctly", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 10)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(10))
 
 		assert.Equal(t, 0, crawler.GetPagesVisited())
 
@@ -112,7 +118,7 @@ func TestGetPagesVisited(t *testing.T) {
 	})
 
 	t.Run("thread-safe increments", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 100)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(100))
 
 		var wg sync.WaitGroup
 		numGoroutines := 50
@@ -178,8 +184,8 @@ func TestCrawlerLinkFiltering(t *testing.T) {
 		}))
 		defer server.Close()
 
-		domain := ExtractDomain(server.URL)
-		crawler := NewWebCrawler([]string{domain}, 10)
+		domain := testutil.ExtractDomain(server.URL)
+		crawler := NewWebCrawler(WithAllowedDomains(domain), WithMaxPages(10))
 
 		done := make(chan bool)
 		go func() {
@@ -201,7 +207,7 @@ func TestCrawlerLinkFiltering(t *testing.T) {
 
 func TestCrawlerURLNormalization(t *testing.T) {
 	t.Run("handles relative URLs", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 10)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(10))
 
 		// The crawler should convert relative URLs to absolute
 		// This is handled by Colly's AbsoluteURL method
@@ -232,8 +238,8 @@ func TestCrawlerURLNormalization(t *testing.T) {
 		}))
 		defer server.Close()
 
-		domain := ExtractDomain(server.URL)
-		crawler := NewWebCrawler([]string{domain}, 10)
+		domain := testutil.ExtractDomain(server.URL)
+		crawler := NewWebCrawler(WithAllowedDomains(domain), WithMaxPages(10))
 
 		done := make(chan bool)
 		go func() {
@@ -255,11 +261,35 @@ func TestCrawlerURLNormalization(t *testing.T) {
 			// Timeout is acceptable
 		}
 	})
+
+	t.Run("canonicalizes tracking parameters and query order", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{
+			"/": `<html><body>
+				<a href="/shoes?color=red&size=9">Shoes</a>
+				<a href="/shoes?size=9&color=red&utm_source=newsletter">Shoes again</a>
+				<a href="/shoes/?color=red&size=9#reviews">Shoes with a slash</a>
+			</body></html>`,
+			"/shoes":  "<html><body>Shoes</body></html>",
+			"/shoes/": "<html><body>Shoes</body></html>",
+		})
+		defer server.Close()
+
+		crawler := NewWebCrawler(
+			WithAllowedDomains("127.0.0.1"),
+			WithoutRobotsTxt(), WithoutSitemaps(),
+			WithURLPolicy(extract.URLPolicy{TrailingSlash: extract.StripTrailingSlash}),
+		)
+		require.NoError(t, crawler.Crawl(server.URL))
+
+		assert.Equal(t, 1, server.Count("/shoes"))
+		assert.Equal(t, 0, server.Count("/shoes/"))
+		assert.Contains(t, crawler.GetFoundLinks(), server.URL+"/shoes?color=red&size=9")
+	})
 }
 
 func TestCrawlerConcurrency(t *testing.T) {
 	t.Run("thread-safe URL tracking", func(t *testing.T) {
-		crawler := NewWebCrawler([]string{"example.com"}, 100)
+		crawler := NewWebCrawler(WithAllowedDomains("example.com"), WithMaxPages(100))
 
 		var wg sync.WaitGroup
 		numGoroutines := 20
@@ -294,6 +324,82 @@ func TestCrawlerConcurrency(t *testing.T) {
 	})
 }
 
+func TestCrawlerPages(t *testing.T) {
+	crawlerConfig := func() CrawlerConfig {
+		cfg := DefaultCrawlerConfig([]string{"127.0.0.1"}, 10)
+		cfg.IgnoreRobotsTxt, cfg.IgnoreSitemaps = true, true
+		cfg.Retry = fastRetries
+		return cfg
+	}
+
+	t.Run("records every visited page", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{
+			"/": `<html lang="en"><head><title>Home</title>
+				<meta name="description" content="The home page">
+				<meta property="og:title" content="Home OG">
+				<link rel="canonical" href="/"></head>
+				<body><a href="/about">About</a><a href="/missing">Missing</a></body></html>`,
+			"/about": `<html><body>About</body></html>`,
+		})
+		defer server.Close()
+
+		crawler := NewWebCrawlerWithConfig(crawlerConfig())
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		pages := make(map[string]extract.Page)
+		for _, page := range crawler.GetPages() {
+			pages[page.URL] = page
+		}
+		require.Len(t, pages, 3)
+
+		home := pages[server.URL+"/"]
+		assert.Equal(t, http.StatusOK, home.StatusCode)
+		assert.Equal(t, "Home", home.Title)
+		assert.Equal(t, "The home page", home.Description)
+		assert.Equal(t, "Home OG", home.OpenGraph.Title)
+		assert.Equal(t, server.URL+"/", home.Canonical)
+		assert.Equal(t, "en", home.Language)
+		assert.Positive(t, home.Size)
+
+		missing := pages[server.URL+"/missing"]
+		assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+		assert.NotEmpty(t, missing.Error)
+	})
+
+	t.Run("streams pages to the exporter", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/":      `<html><head><title>Home</title></head><body><a href="/about">About</a></body></html>`,
+			"/about": `<html><head><title>About</title></head><body>About</body></html>`,
+		})
+		defer server.Close()
+
+		var buf bytes.Buffer
+		cfg := crawlerConfig()
+		cfg.Exporter = export.NewJSONLinesExporter(&buf)
+		crawler := NewWebCrawlerWithConfig(cfg)
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		assert.Empty(t, crawler.GetPages())
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		var page extract.Page
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &page))
+		assert.Equal(t, "Home", page.Title)
+	})
+
+	t.Run("returns the first export error", func(t *testing.T) {
+		server := testutil.CreateMockServerWithRoutes(map[string]string{
+			"/": `<html><body>Home</body></html>`,
+		})
+		defer server.Close()
+
+		cfg := crawlerConfig()
+		cfg.Exporter = export.NewJSONLinesExporter(failingWriter{})
+		err := NewWebCrawlerWithConfig(cfg).Crawl(server.URL + "/")
+		assert.ErrorContains(t, err, "disk full")
+	})
+}
+
 func TestCrawlerErrorHandling(t *testing.T) {
 	t.Run("handles server errors gracefully", func(t *testing.T) {
 		t.Skip("Skipping mock server test - domain restrictions in Colly")
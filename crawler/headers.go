@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"web-scraper/extract"
+)
+
+// headerRotator gives requests the headers of a browser profile. Every
+// request of a session, or of a domain when the policy rotates per domain,
+// poses as the same browser, so its headers stay consistent.
+type headerRotator struct {
+	policy   extract.HeaderPolicy
+	profiles []extract.BrowserProfile
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	session *extract.BrowserProfile            // picked on the first request
+	domains map[string]*extract.BrowserProfile // when rotating per domain
+	visited map[string]bool                    // hosts a page was requested from
+}
+
+// newHeaderRotator creates a rotator picking from the profiles of policy
+func newHeaderRotator(policy extract.HeaderPolicy) *headerRotator {
+	profiles := policy.Profiles()
+	if len(profiles) == 0 {
+		profiles = extract.HeaderPolicy{AcceptLanguage: policy.AcceptLanguage}.Profiles()
+	}
+	return &headerRotator{
+		policy:   policy,
+		profiles: profiles,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		domains:  make(map[string]*extract.BrowserProfile),
+		visited:  make(map[string]bool),
+	}
+}
+
+// profile returns the browser profile of requests to host, and whether a
+// page of host was requested before
+func (h *headerRotator) profile(host string, page bool) (*extract.BrowserProfile, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seen := h.visited[host]
+	if page {
+		h.visited[host] = true
+	}
+
+	if h.policy.Rotate == extract.RotatePerDomain {
+		if p, ok := h.domains[host]; ok {
+			return p, seen
+		}
+		p := h.pick()
+		h.domains[host] = p
+		log.Printf("[HEADERS] Posing as %s for %s", p.Name, host)
+		return p, seen
+	}
+	if h.session == nil {
+		h.session = h.pick()
+		log.Printf("[HEADERS] Posing as %s", h.session.Name)
+	}
+	return h.session, seen
+}
+
+// pick chooses a profile at random
+func (h *headerRotator) pick() *extract.BrowserProfile {
+	p := h.profiles[h.rand.Intn(len(h.profiles))]
+	return &p
+}
+
+// page sets the headers of a top-level page navigation on r
+func (h *headerRotator) page(r *colly.Request) {
+	h.navigate(r.URL, *r.Headers)
+}
+
+// navigate sets the headers of a top-level navigation to u on header
+func (h *headerRotator) navigate(u *url.URL, header http.Header) {
+	p, seen := h.profile(u.Hostname(), true)
+	h.setCommon(header, p)
+	header.Set("Accept", p.Accept)
+	header.Set("Upgrade-Insecure-Requests", "1")
+	header.Set("Sec-Fetch-Dest", "document")
+	header.Set("Sec-Fetch-Mode", "navigate")
+	header.Set("Sec-Fetch-User", "?1")
+	// The first page of a site is typed in, the others are followed links
+	if seen {
+		header.Set("Sec-Fetch-Site", "same-origin")
+	} else {
+		header.Set("Sec-Fetch-Site", "none")
+	}
+	h.setExtra(header)
+}
+
+// image sets the headers of an image embedded in a page of pageHost on r.
+// The image is fetched as the page's browser, even from another host.
+func (h *headerRotator) image(r *colly.Request, pageHost string) {
+	if pageHost == "" {
+		pageHost = r.URL.Hostname()
+	}
+	p, _ := h.profile(pageHost, false)
+	h.setCommon(*r.Headers, p)
+	r.Headers.Set("Accept", p.ImageAccept)
+	r.Headers.Set("Sec-Fetch-Dest", "image")
+	r.Headers.Set("Sec-Fetch-Mode", "no-cors")
+	if r.URL.Hostname() == pageHost {
+		r.Headers.Set("Sec-Fetch-Site", "same-origin")
+	} else {
+		r.Headers.Set("Sec-Fetch-Site", "cross-site")
+	}
+	h.setExtra(*r.Headers)
+}
+
+// setCommon sets the headers every request of p carries on header
+func (h *headerRotator) setCommon(header http.Header, p *extract.BrowserProfile) {
+	header.Set("User-Agent", p.UserAgent)
+	header.Set("Accept-Language", p.AcceptLanguage)
+	for name, value := range p.ClientHints {
+		header.Set(name, value)
+	}
+}
+
+// setExtra sets the policy's extra headers on header
+func (h *headerRotator) setExtra(header http.Header) {
+	for name, value := range h.policy.Extra {
+		header.Set(name, value)
+	}
+}
@@ -0,0 +1,192 @@
+package crawler
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/export"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+)
+
+// headerRecorder serves a shop of one product and records the headers of
+// every request by path
+type headerRecorder struct {
+	mu      sync.Mutex
+	headers map[string]http.Header
+}
+
+func (h *headerRecorder) serve(t *testing.T) *http.ServeMux {
+	product := testutil.MustGetFixture(t, "product_variable.html")
+	png := testPNG(t, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		h.headers[r.URL.Path] = r.Header.Clone()
+		h.mu.Unlock()
+		switch {
+		case r.URL.Path == "/":
+			w.Write([]byte(`<html><body><ul><li class="product"><a class="woocommerce-LoopProduct-link" href="/product/hoodie">Hoodie</a></li></ul></body></html>`))
+		case r.URL.Path == "/product/hoodie":
+			w.Write([]byte(product))
+		case strings.HasPrefix(r.URL.Path, "/images/"):
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(png))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	return mux
+}
+
+// scrapeHeaders scrapes the shop, images included, with policy and returns
+// the headers of each request
+func scrapeHeaders(t *testing.T, policy extract.HeaderPolicy) map[string]http.Header {
+	recorder := &headerRecorder{headers: make(map[string]http.Header)}
+	server := testutil.CreateMockServer(recorder.serve(t))
+	defer server.Close()
+
+	store, err := export.NewImageStore(t.TempDir())
+	require.NoError(t, err)
+	profile := extract.DefaultSiteProfile()
+	profile.Headers = policy
+	cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+	cfg.IgnoreRobotsTxt = true
+	cfg.Profile = profile
+	cfg.Images = store
+	require.NoError(t, NewScraperWithConfig(cfg).Scrape(server.URL+"/"))
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	require.Contains(t, recorder.headers, "/product/hoodie")
+	require.Contains(t, recorder.headers, "/images/hoodie.jpg")
+	return recorder.headers
+}
+
+func TestScraperHeaders(t *testing.T) {
+	t.Run("poses as one browser for the whole session", func(t *testing.T) {
+		headers := scrapeHeaders(t, extract.HeaderPolicy{})
+		userAgent := headers["/"].Get("User-Agent")
+		chromium := strings.Contains(userAgent, "Chrome/")
+		for path, h := range headers {
+			assert.Equal(t, userAgent, h.Get("User-Agent"), path)
+			assert.Equal(t, chromium, h.Get("Sec-CH-UA") != "", path)
+		}
+	})
+
+	t.Run("sends a firefox profile without client hints", func(t *testing.T) {
+		headers := scrapeHeaders(t, extract.HeaderPolicy{
+			Browsers:       []string{"firefox-windows"},
+			AcceptLanguage: "de-DE,de;q=0.9",
+			Extra:          map[string]string{"DNT": "1"},
+		})
+		firefox := extract.BrowserProfiles["firefox-windows"]
+		for path, h := range headers {
+			assert.Equal(t, firefox.UserAgent, h.Get("User-Agent"), path)
+			assert.Equal(t, "de-DE,de;q=0.9", h.Get("Accept-Language"), path)
+			assert.Equal(t, "1", h.Get("DNT"), path)
+			assert.Empty(t, h.Get("Sec-CH-UA"), path)
+			assert.Empty(t, h.Get("Sec-CH-UA-Platform"), path)
+		}
+
+		page := headers["/product/hoodie"]
+		assert.Equal(t, firefox.Accept, page.Get("Accept"))
+		assert.Equal(t, "document", page.Get("Sec-Fetch-Dest"))
+		assert.Equal(t, "same-origin", page.Get("Sec-Fetch-Site"))
+		assert.Equal(t, "none", headers["/"].Get("Sec-Fetch-Site"))
+
+		image := headers["/images/hoodie.jpg"]
+		assert.Equal(t, firefox.ImageAccept, image.Get("Accept"))
+		assert.Equal(t, "image", image.Get("Sec-Fetch-Dest"))
+		assert.Equal(t, "no-cors", image.Get("Sec-Fetch-Mode"))
+		assert.Empty(t, image.Get("Upgrade-Insecure-Requests"))
+	})
+}
+
+func TestCrawlerHeaders(t *testing.T) {
+	var mu sync.Mutex
+	headers := make(map[string]http.Header)
+	server := testutil.CreateMockServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers[r.Host+r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+		_, port, _ := net.SplitHostPort(r.Host)
+		w.Write([]byte(`<html><body><a href="/a">A</a> <a href="http://localhost:` + port + `/b">B</a></body></html>`))
+	}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":")+1:]
+
+	wc := NewWebCrawler(
+		WithAllowedDomains("127.0.0.1", "localhost"),
+		WithoutRobotsTxt(),
+		WithoutSitemaps(),
+		WithHeaderPolicy(extract.HeaderPolicy{
+			Browsers: []string{"chrome-windows", "firefox-mac"},
+			Rotate:   extract.RotatePerDomain,
+		}),
+	)
+	require.NoError(t, wc.Crawl(server.URL+"/"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	first, second := headers["127.0.0.1:"+port+"/"], headers["127.0.0.1:"+port+"/a"]
+	require.NotNil(t, first)
+	require.NotNil(t, second)
+	userAgent := first.Get("User-Agent")
+	assert.Contains(t, []string{
+		extract.BrowserProfiles["chrome-windows"].UserAgent,
+		extract.BrowserProfiles["firefox-mac"].UserAgent,
+	}, userAgent)
+	assert.Equal(t, userAgent, second.Get("User-Agent"), "one browser per domain")
+	assert.Equal(t, strings.Contains(userAgent, "Chrome/"), first.Get("Sec-CH-UA") != "")
+	assert.Equal(t, "document", first.Get("Sec-Fetch-Dest"))
+	assert.Equal(t, "none", first.Get("Sec-Fetch-Site"))
+	assert.Equal(t, "same-origin", second.Get("Sec-Fetch-Site"))
+
+	other := headers["localhost:"+port+"/b"]
+	require.NotNil(t, other)
+	assert.Equal(t, "none", other.Get("Sec-Fetch-Site"), "the first page of another domain")
+}
+
+func TestHeaderRotator(t *testing.T) {
+	request := func(rawURL string) *colly.Request {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		return &colly.Request{URL: u, Headers: &http.Header{}}
+	}
+
+	t.Run("keeps a profile per domain", func(t *testing.T) {
+		rotator := newHeaderRotator(extract.HeaderPolicy{Rotate: extract.RotatePerDomain})
+		picked := make(map[string]string)
+		for i := 0; i < 50; i++ {
+			for _, host := range []string{"a.example", "b.example", "c.example"} {
+				r := request("https://" + host + "/page")
+				rotator.page(r)
+				if ua, ok := picked[host]; ok {
+					assert.Equal(t, ua, r.Headers.Get("User-Agent"), host)
+				}
+				picked[host] = r.Headers.Get("User-Agent")
+			}
+		}
+	})
+
+	t.Run("fetches images from another host as the page's browser", func(t *testing.T) {
+		rotator := newHeaderRotator(extract.HeaderPolicy{Rotate: extract.RotatePerDomain})
+		page := request("https://shop.example/product")
+		rotator.page(page)
+		for i := 0; i < 20; i++ {
+			image := request("https://cdn.example/image.jpg")
+			rotator.image(image, "shop.example")
+			assert.Equal(t, page.Headers.Get("User-Agent"), image.Headers.Get("User-Agent"))
+			assert.Equal(t, "cross-site", image.Headers.Get("Sec-Fetch-Site"))
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package crawler
+
+import (
+	"log"
+	"sync"
+
+	"github.com/gocolly/colly/v2"
+	"web-scraper/export"
+	"web-scraper/extract"
+)
+
+// Request context keys of an image download
+const (
+	imageBatchKey = "image_batch"
+	imageIndexKey = "image_index"
+	imagePageKey  = "image_page" // host of the page showing the image
+)
+
+// imageFetcher downloads the images of scraped products into a store. It
+// uses a clone of a page collector, so downloads share that collector's rate
+// limits, transport and robots.txt rules.
+type imageFetcher struct {
+	collector *colly.Collector
+	store     *export.ImageStore
+}
+
+// imageBatch is the images of one product, handed to done once every
+// download has finished or failed
+type imageBatch struct {
+	mu        sync.Mutex
+	images    []extract.Image
+	remaining int
+	done      func(images []extract.Image)
+}
+
+// newImageFetcher clones parent for image downloads. allow runs first for
+// every request and aborts those that must not be made; headers dresses the
+// others up as the browser of their page. failed counts the downloads that
+// failed for good.
+func newImageFetcher(parent *colly.Collector, store *export.ImageStore, retries *retrier, headers *headerRotator, allow func(r *colly.Request) bool, failed func()) *imageFetcher {
+	c := parent.Clone()
+	c.AllowedDomains = nil   // images are often served from a CDN
+	c.AllowURLRevisit = true // an image may belong to several products
+	f := &imageFetcher{collector: c, store: store}
+
+	retries.attach(c)
+	c.OnRequest(func(r *colly.Request) {
+		if !allow(r) {
+			f.finish(r, nil)
+			return
+		}
+		headers.image(r, r.Ctx.Get(imagePageKey))
+	})
+	c.OnResponse(func(r *colly.Response) {
+		image, err := store.Save(r.Request.URL.String(), r.Body, r.Headers.Get("Content-Type"))
+		if err != nil {
+			log.Printf("[IMAGE] %v", err)
+			failed()
+			f.finish(r.Request, nil)
+			return
+		}
+		log.Printf("[IMAGE] Saved %s as %s", r.Request.URL, image.Path)
+		f.finish(r.Request, &image)
+	})
+	c.OnError(func(r *colly.Response, err error) {
+		log.Printf("[ERROR] Image %s: %v", r.Request.URL, err)
+		if retries.retry(c, r, err) {
+			return
+		}
+		failed()
+		f.finish(r.Request, nil)
+	})
+	return f
+}
+
+// fetch downloads urls, shown on a page of pageHost, and calls done with
+// their images, in the same order. Images that could not be downloaded only
+// have their URL. done runs at once when there is nothing to download.
+func (f *imageFetcher) fetch(pageHost string, urls []string, done func(images []extract.Image)) {
+	batch := &imageBatch{images: make([]extract.Image, len(urls)), remaining: len(urls), done: done}
+	if len(urls) == 0 {
+		done(batch.images)
+		return
+	}
+
+	for i, imageURL := range urls {
+		batch.images[i].URL = imageURL
+		ctx := colly.NewContext()
+		ctx.Put(imageBatchKey, batch)
+		ctx.Put(imageIndexKey, i)
+		ctx.Put(imagePageKey, pageHost)
+		if err := f.collector.Request("GET", imageURL, nil, ctx, nil); err != nil {
+			log.Printf("[IMAGE] Skipping %s: %v", imageURL, err)
+			batch.finish(i, nil)
+		}
+	}
+}
+
+// finish records the outcome of the download behind r
+func (f *imageFetcher) finish(r *colly.Request, image *extract.Image) {
+	batch, ok := r.Ctx.GetAny(imageBatchKey).(*imageBatch)
+	if !ok {
+		return
+	}
+	i, _ := r.Ctx.GetAny(imageIndexKey).(int)
+	batch.finish(i, image)
+}
+
+// finish stores image, nil for a failed download, as the i'th image and
+// calls done after the last one
+func (b *imageBatch) finish(i int, image *extract.Image) {
+	b.mu.Lock()
+	if image != nil {
+		sourceURL := b.images[i].URL // the request may have been redirected
+		b.images[i] = *image
+		b.images[i].URL = sourceURL
+	}
+	b.remaining--
+	last := b.remaining == 0
+	b.mu.Unlock()
+
+	if last {
+		b.done(b.images)
+	}
+}
+
+// stored reports whether every image was downloaded
+func stored(images []extract.Image) bool {
+	for _, image := range images {
+		if image.Path == "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/export"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+)
+
+// testPNG encodes a blank PNG of the given width
+func testPNG(t *testing.T, width int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, 1))))
+	return buf.String()
+}
+
+func TestScraperImages(t *testing.T) {
+	server := testutil.CreateCountingServer(map[string]string{
+		"/product/hoodie":         testutil.MustGetFixture(t, "product_variable.html"),
+		"/images/hoodie.jpg":      testPNG(t, 1),
+		"/images/hoodie-back.jpg": testPNG(t, 2),
+		"/images/hoodie-blue.jpg": testPNG(t, 1), // the same file as hoodie.jpg
+	})
+	defer server.Close()
+	server.Route("/", `<html><body><ul>
+		<li class="product"><a class="woocommerce-LoopProduct-link" href="`+server.URL+`/product/hoodie">Hoodie</a></li>
+	</ul></body></html>`)
+
+	store, err := export.NewImageStore(t.TempDir())
+	require.NoError(t, err)
+	cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+	cfg.IgnoreRobotsTxt = true
+	cfg.Images = store
+	scraper := NewScraperWithConfig(cfg)
+	require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+	products := scraper.GetProducts()
+	require.Len(t, products, 1)
+	product := products[0]
+	require.Len(t, product.Images, 4)
+
+	main := product.Images[0]
+	assert.Equal(t, server.URL+"/images/hoodie.jpg", main.URL)
+	assert.Equal(t, "image/png", main.MIME)
+	assert.Equal(t, 1, main.Width)
+	assert.FileExists(t, main.Path)
+	assert.Equal(t, main.Path, product.ImageURL)
+	assert.Equal(t, 2, product.Images[1].Width)
+	assert.Equal(t, main.Path, product.Images[2].Path)
+	assert.Equal(t, main.Path, product.Variants[0].ImageURL)
+
+	// The missing image keeps its URL and counts as a failed request
+	assert.Equal(t, extract.Image{URL: server.URL + "/images/hoodie-large.jpg"}, product.Images[3])
+	assert.Equal(t, server.URL+"/images/hoodie-large.jpg", product.Variants[1].ImageURL)
+	assert.Equal(t, 1, scraper.GetFailedRequests())
+
+	entries, err := filepath.Glob(filepath.Join(filepath.Dir(filepath.Dir(main.Path)), "*", "*"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+}
+
+func TestListingScraperImages(t *testing.T) {
+	server := testutil.CreateCountingServer(map[string]string{
+		"/":                          testutil.MustGetFixture(t, "listing.html"),
+		"/images/test-product-1.jpg": testPNG(t, 1),
+		"/images/test-product-2.jpg": testPNG(t, 2),
+		"/images/test-product-3.jpg": testPNG(t, 3),
+	})
+	defer server.Close()
+
+	store, err := export.NewImageStore(t.TempDir())
+	require.NoError(t, err)
+	cfg := listingConfig()
+	cfg.MaxDepth = 1
+	cfg.Images = store
+	ls := NewListingScraperWithConfig(cfg)
+	require.NoError(t, ls.Scrape(server.URL+"/"))
+
+	products := ls.GetProducts()
+	require.Len(t, products, 3)
+	for _, product := range products {
+		require.Len(t, product.Images, 1)
+		assert.Equal(t, product.Images[0].Path, product.Image)
+		data, err := os.ReadFile(product.Image)
+		require.NoError(t, err)
+		assert.Equal(t, product.Images[0].Size, len(data))
+	}
+	assert.Equal(t, 0, ls.GetFailedRequests())
+}
@@ -0,0 +1,314 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"web-scraper/extract"
+)
+
+// ListingScraper is the basic scraper: it reads the product cards of listing
+// pages and follows pagination, without visiting product detail pages. The
+// Detail* and Checkpoint* settings of its ScraperConfig are not used, and
+// images are downloaded under the listing limits.
+type ListingScraper struct {
+	cfg       ScraperConfig
+	products  []extract.Product
+	mu        sync.Mutex
+	failed    int
+	completed int // listing pages fetched and parsed
+	stopper   *stopper
+	exportErr error // first failed export
+	collected int   // products scraped, kept or exported
+}
+
+// DefaultListingConfig returns the configuration used by NewListingScraper
+func DefaultListingConfig(allowedDomains []string) ScraperConfig {
+	return ScraperConfig{
+		AllowedDomains: allowedDomains,
+		MaxDepth:       2,
+		Parallelism:    2,
+		Delay:          1 * time.Second,
+		CacheDir:       "./cache",
+		Profile:        extract.DefaultSiteProfile(),
+		Retry:          DefaultRetryPolicy(),
+	}
+}
+
+// NewListingScraper creates a listing scraper configured by opts
+func NewListingScraper(opts ...Option) *ListingScraper {
+	cfg := DefaultListingConfig(nil)
+	applyScraperOptions(&cfg, opts)
+	return NewListingScraperWithConfig(cfg)
+}
+
+// NewListingScraperWithConfig creates a listing scraper from an explicit configuration
+func NewListingScraperWithConfig(cfg ScraperConfig) *ListingScraper {
+	if cfg.Profile == nil {
+		cfg.Profile = extract.DefaultSiteProfile()
+	}
+	return &ListingScraper{cfg: cfg, stopper: newStopper(cfg.DrainTimeout)}
+}
+
+// Scrape scrapes the listing at startURL and the pages it links to as next
+func (ls *ListingScraper) Scrape(startURL string) error {
+	return ls.ScrapeContext(context.Background(), startURL)
+}
+
+// ScrapeContext is Scrape bound to ctx. Once ctx is done, requests in flight
+// are aborted, no new ones are made, and it returns an *IncompleteError
+// wrapping ctx.Err(). The products scraped so far stay available.
+func (ls *ListingScraper) ScrapeContext(ctx context.Context, startURL string) error {
+	release := ls.stopper.watch(ctx)
+	defer release()
+
+	err := ls.scrape(startURL)
+	if ls.stopper.wasAborted() {
+		ls.mu.Lock()
+		defer ls.mu.Unlock()
+		return &IncompleteError{Err: ctx.Err(), Completed: ls.completed, Collected: ls.collected}
+	}
+	return err
+}
+
+// scrape runs the scrape from startURL until it finishes or is stopped
+func (ls *ListingScraper) scrape(startURL string) error {
+	cfg := ls.cfg
+	stop := ls.stopper
+
+	fmt.Println("Starting Go Web Scraper...")
+	fmt.Printf("Target: %s\n", startURL)
+	fmt.Printf("Profile: %s\n", cfg.Profile.Name)
+
+	// Create a new collector with configuration
+	c := colly.NewCollector(
+		// Only allow scraping from the target domain
+		colly.AllowedDomains(cfg.AllowedDomains...),
+		// Enable URL revisiting (useful for pagination)
+		colly.AllowURLRevisit(),
+		// Set max depth for crawling
+		colly.MaxDepth(cfg.MaxDepth),
+		// Fetch up to cfg.Parallelism pages at once
+		colly.Async(true),
+	)
+	transport := newAbortTransport(stop.aborted)
+	if cfg.Proxies != nil {
+		transport.setProxyPool(cfg.Proxies)
+	}
+	cookies := cfg.Cookies
+	if cookies == nil {
+		cookies = NewCookieJar()
+	}
+	transport.setCookieJar(cookies)
+	c.DisableCookies()
+	c.WithTransport(transport)
+
+	// Cache responses to avoid repeated requests during development
+	if cfg.CacheDir != "" {
+		c.CacheDir = cfg.CacheDir
+	}
+
+	// Honour robots.txt unless told otherwise
+	var policy *RobotsPolicy
+	if !cfg.IgnoreRobotsTxt {
+		policy = NewRobotsPolicy(robotsUserAgent)
+	}
+
+	// Set rate limiting to be a good citizen
+	robots := applyLimit(c, policy, colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: cfg.Parallelism,
+		Delay:       cfg.Delay,
+		RandomDelay: cfg.RandomDelay,
+	})
+
+	// Retry throttled and transient failures with backoff
+	retries := newRetrier(cfg.Retry, stop.done())
+	retries.attach(c)
+
+	// Pose as one browser to avoid being blocked
+	headers := newHeaderRotator(cfg.Profile.Headers)
+	allow := func(r *colly.Request) bool {
+		if !stop.allow(r) {
+			return false
+		}
+		return robots == nil || robots.allow(r)
+	}
+	c.OnRequest(func(r *colly.Request) {
+		if allow(r) {
+			headers.page(r)
+			fmt.Printf("Visiting: %s\n", r.URL)
+		}
+	})
+
+	// Download product images through a clone sharing the rate limits
+	var images *imageFetcher
+	if cfg.Images != nil {
+		images = newImageFetcher(c, cfg.Images, retries, headers, allow, func() {
+			ls.mu.Lock()
+			ls.failed++
+			ls.mu.Unlock()
+		})
+	}
+
+	// Handle response errors
+	c.OnError(func(r *colly.Response, err error) {
+		log.Printf("Error scraping %s: %v", r.Request.URL, err)
+		if retries.retry(c, r, err) {
+			return
+		}
+		ls.mu.Lock()
+		ls.failed++
+		ls.mu.Unlock()
+	})
+
+	// Handle successful responses
+	c.OnResponse(func(r *colly.Response) {
+		fmt.Printf("Got response from: %s [Status: %d]\n", r.Request.URL, r.StatusCode)
+	})
+
+	// Scrape product items from the product listing using the site profile
+	listing := cfg.Profile.Listing
+	c.OnHTML(listing.Item, func(e *colly.HTMLElement) {
+		product := extract.Product{
+			URL:       cfg.Profile.URLs.Resolve(e.Request, listing.URL.Extract(e)),
+			Image:     cfg.Profile.URLs.Resolve(e.Request, listing.Image.Extract(e)),
+			Name:      listing.Name.Extract(e),
+			Price:     extract.CleanPrice(listing.Price.Extract(e)),
+			ScrapedAt: time.Now(),
+		}
+		// Unparseable prices keep only the raw text
+		product.Pricing, _ = cfg.Profile.PriceFormat.Parse(product.Price)
+
+		// Only add if we got valid data
+		if product.Name == "" {
+			return
+		}
+		fmt.Printf("Found product: %s - %s\n", product.Name, product.Price)
+		if images == nil || product.Image == "" {
+			ls.mu.Lock()
+			ls.collect(product)
+			ls.mu.Unlock()
+			return
+		}
+		images.fetch(e.Request.URL.Hostname(), []string{product.Image}, func(downloaded []extract.Image) {
+			product.SetImages(downloaded)
+			ls.mu.Lock()
+			ls.collect(product)
+			ls.mu.Unlock()
+		})
+	})
+
+	// Handle pagination - find and visit "next" page links
+	if listing.NextPage != "" {
+		c.OnHTML(listing.NextPage, func(e *colly.HTMLElement) {
+			nextPage := cfg.Profile.URLs.Resolve(e.Request, e.Attr("href"))
+			if nextPage != "" {
+				fmt.Printf("Found next page: %s\n", nextPage)
+				e.Request.Visit(nextPage)
+			}
+		})
+	}
+
+	// Callback when scraping is complete for a page
+	c.OnScraped(func(r *colly.Response) {
+		ls.mu.Lock()
+		ls.completed++
+		ls.mu.Unlock()
+		fmt.Printf("Finished scraping: %s\n", r.Request.URL)
+	})
+
+	// Start scraping from the listing page
+	if err := checkStartURL(policy, startURL); err != nil {
+		return err
+	}
+	if err := startLogin(cfg.Profile.Login, startURL, stop.aborted, cfg.Proxies, cookies, headers, transport); err != nil {
+		return err
+	}
+	if canonicalURL, ok := cfg.Profile.URLs.Canonicalize(startURL); ok {
+		startURL = canonicalURL
+	}
+	if err := c.Visit(startURL); err != nil {
+		return fmt.Errorf("failed to start scraping: %w", err)
+	}
+
+	// Wait for all requests to complete, or for the drain after stop
+	stop.wait(func() {
+		if images != nil {
+			retries.wait(c, images.collector)
+		} else {
+			retries.wait(c)
+		}
+	})
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if ls.exportErr != nil {
+		return fmt.Errorf("failed to export products: %w", ls.exportErr)
+	}
+	return nil
+}
+
+// collect keeps product, or hands it to the exporter when one is set, unless
+// the validator rejects it. The caller must hold ls.mu.
+func (ls *ListingScraper) collect(product extract.Product) {
+	if ls.cfg.Validator != nil {
+		accepted, err := ls.cfg.Validator.Check(product)
+		if err != nil && ls.exportErr == nil {
+			log.Printf("[EXPORT] %v", err)
+			ls.exportErr = err
+		}
+		if !accepted {
+			return
+		}
+	}
+	ls.collected++
+	if ls.cfg.Exporter == nil {
+		ls.products = append(ls.products, product)
+		return
+	}
+	if err := ls.cfg.Exporter.Export(product); err != nil && ls.exportErr == nil {
+		log.Printf("[EXPORT] %v", err)
+		ls.exportErr = err
+	}
+}
+
+// Stop asks a running Scrape to finish early. No new pages are requested,
+// in-flight ones get DrainTimeout to complete, and Scrape then returns with
+// the products found so far.
+func (ls *ListingScraper) Stop() {
+	ls.stopper.stop()
+}
+
+// Stopped reports whether Stop was called
+func (ls *ListingScraper) Stopped() bool {
+	return ls.stopper.stopped()
+}
+
+// GetProducts returns a copy of the products scraped so far
+func (ls *ListingScraper) GetProducts() []extract.Product {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	// Copy so requests still running after an abandoned drain can't race the caller
+	return append([]extract.Product(nil), ls.products...)
+}
+
+// GetProductCount returns the number of products scraped, including those
+// handed to the exporter
+func (ls *ListingScraper) GetProductCount() int {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.collected
+}
+
+// GetFailedRequests returns the number of requests that failed for good
+func (ls *ListingScraper) GetFailedRequests() int {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.failed
+}
@@ -0,0 +1,92 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+)
+
+// listingConfig returns a listing configuration suited to the mock servers
+func listingConfig() ScraperConfig {
+	cfg := DefaultListingConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.CacheDir = 0, ""
+	cfg.IgnoreRobotsTxt = true
+	return cfg
+}
+
+func TestNewListingScraper(t *testing.T) {
+	t.Run("uses the listing defaults", func(t *testing.T) {
+		ls := NewListingScraper(WithAllowedDomains("example.com"))
+
+		assert.Equal(t, []string{"example.com"}, ls.cfg.AllowedDomains)
+		assert.Equal(t, 2, ls.cfg.MaxDepth)
+		assert.Equal(t, 2, ls.cfg.Parallelism)
+		assert.Equal(t, time.Second, ls.cfg.Delay)
+		assert.Equal(t, "woocommerce", ls.cfg.Profile.Name)
+		assert.Empty(t, ls.GetProducts())
+	})
+
+	t.Run("fills in a missing profile", func(t *testing.T) {
+		ls := NewListingScraperWithConfig(ScraperConfig{})
+		require.NotNil(t, ls.cfg.Profile)
+		assert.Equal(t, "woocommerce", ls.cfg.Profile.Name)
+	})
+}
+
+func TestListingScraper(t *testing.T) {
+	t.Run("scrapes products and follows pagination", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{
+			"/":       testutil.MustGetFixture(t, "listing.html"),
+			"/page/2": testutil.MustGetFixture(t, "listing_page2.html"),
+		})
+		defer server.Close()
+
+		ls := NewListingScraperWithConfig(listingConfig())
+		require.NoError(t, ls.Scrape(server.URL+"/"))
+
+		products := ls.GetProducts()
+		require.GreaterOrEqual(t, len(products), 3)
+		assert.Equal(t, "Test Product 1", products[0].Name)
+		assert.Equal(t, "$19.99", products[0].Price)
+		assert.Equal(t, int64(1999), products[0].Pricing.Amount)
+		assert.Equal(t, server.URL+"/product/test-product-1", products[0].URL)
+		assert.Equal(t, server.URL+"/images/test-product-1.jpg", products[0].Image)
+		assert.Equal(t, 1, server.Count("/page/2"))
+		assert.Equal(t, 0, ls.GetFailedRequests())
+	})
+
+	t.Run("counts failed requests", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{"/": "<html></html>"})
+		defer server.Close()
+		server.Fail("/", http.StatusInternalServerError, 1, "")
+
+		cfg := listingConfig()
+		cfg.Retry.MaxAttempts = 1
+		ls := NewListingScraperWithConfig(cfg)
+		require.NoError(t, ls.Scrape(server.URL+"/"))
+
+		assert.Empty(t, ls.GetProducts())
+		assert.Equal(t, 1, ls.GetFailedRequests())
+	})
+
+	t.Run("cancelled context makes no requests", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		ls := NewListingScraperWithConfig(listingConfig())
+		err := ls.ScrapeContext(ctx, server.URL+"/")
+
+		var incomplete *IncompleteError
+		require.ErrorAs(t, err, &incomplete)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 0, server.Count("/"))
+	})
+}
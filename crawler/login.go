@@ -0,0 +1,234 @@
+package crawler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"web-scraper/extract"
+)
+
+// ErrSessionExpired is returned for a page served to a logged-out visitor
+// in the middle of a run. The scraper has logged in again by then, so the
+// page is retried like after a transient error.
+var ErrSessionExpired = errors.New("login session expired")
+
+// loginTimeout bounds each request of a login
+const loginTimeout = 30 * time.Second
+
+// loginSession logs in to a shop before a scrape and again whenever a page
+// shows the session has expired. Its cookies go to the seed scope of the
+// jar and are shared with every proxy from there.
+type loginSession struct {
+	cfg      *extract.LoginConfig
+	loginURL *url.URL
+	client   *http.Client
+	cookies  *CookieJar
+	headers  *headerRotator
+
+	mu       sync.Mutex // held while logging in
+	loggedIn time.Time  // when the last login succeeded
+}
+
+// newLoginSession prepares the login of cfg, whose URL may be relative to
+// startURL. Its requests go through transport, which must not handle
+// cookies itself.
+func newLoginSession(cfg *extract.LoginConfig, startURL string, transport http.RoundTripper, cookies *CookieJar, headers *headerRotator) (*loginSession, error) {
+	base, err := url.Parse(startURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	loginURL, err := base.Parse(strings.TrimSpace(cfg.URL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid login URL: %w", err)
+	}
+	return &loginSession{
+		cfg:      cfg,
+		loginURL: loginURL,
+		client:   &http.Client{Transport: transport, Jar: cookies.Scope(""), Timeout: loginTimeout},
+		cookies:  cookies,
+		headers:  headers,
+	}, nil
+}
+
+// startLogin logs in to the site of startURL as cfg describes, through
+// proxies when it isn't nil, and makes transports log in again when the
+// session expires. It does nothing for a nil cfg.
+func startLogin(cfg *extract.LoginConfig, startURL string, abort <-chan struct{}, proxies *ProxyPool, cookies *CookieJar, headers *headerRotator, transports ...*abortTransport) error {
+	if cfg == nil {
+		return nil
+	}
+	transport := newAbortTransport(abort)
+	if proxies != nil {
+		transport.setProxyPool(proxies)
+	}
+	session, err := newLoginSession(cfg, startURL, transport, cookies, headers)
+	if err != nil {
+		return err
+	}
+	if err := session.login(context.Background()); err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+	for _, t := range transports {
+		t.setLogin(session)
+	}
+	return nil
+}
+
+// login logs in, failing when the success check doesn't match afterwards
+func (s *loginSession) login(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.submit(ctx)
+}
+
+// relogin logs in again after a request started at since found the
+// session expired. Requests that started before a later login don't log in
+// once more.
+func (s *loginSession) relogin(ctx context.Context, since time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.loggedIn.After(since) {
+		return nil
+	}
+	log.Printf("[LOGIN] Session expired, logging in again")
+	return s.submit(ctx)
+}
+
+// submit fills in and submits the login form. The caller holds s.mu.
+func (s *loginSession) submit(ctx context.Context) error {
+	username, password, err := s.cfg.Credentials()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.loginURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	page, doc, err := s.fetch(req)
+	if err != nil {
+		return fmt.Errorf("failed to load login page: %w", err)
+	}
+	form, err := s.cfg.BuildForm(doc, page, username, password)
+	if err != nil {
+		return err
+	}
+
+	if form.Method == http.MethodGet {
+		action := *form.Action
+		action.RawQuery = form.Values.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, action.String(), nil)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, form.Action.String(), strings.NewReader(form.Values.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("Origin", page.Scheme+"://"+page.Host)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Referer", page.String())
+	for name := range form.Header {
+		req.Header.Set(name, form.Header.Get(name))
+	}
+	landed, doc, err := s.fetch(req)
+	if err != nil {
+		return fmt.Errorf("failed to submit login form: %w", err)
+	}
+	if doc.Find(s.cfg.Success).Length() == 0 {
+		return fmt.Errorf("login failed: %s has no %q, check the credentials in $%s and $%s",
+			landed, s.cfg.Success, s.cfg.UsernameEnv, s.cfg.PasswordEnv)
+	}
+
+	s.cookies.shareSeed()
+	s.loggedIn = time.Now()
+	log.Printf("[LOGIN] Logged in at %s", s.loginURL)
+	return nil
+}
+
+// fetch sends req as a browser navigation and parses the HTML it ends on,
+// returning the final URL
+func (s *loginSession) fetch(req *http.Request) (*url.URL, *goquery.Document, error) {
+	if s.headers != nil {
+		s.headers.navigate(req.URL, req.Header)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, nil, fmt.Errorf("%s: status %d", resp.Request.URL, resp.StatusCode)
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", resp.Request.URL, err)
+	}
+	return resp.Request.URL, doc, nil
+}
+
+// check looks for signs that resp, the response to a request started at
+// start, was served to a logged-out visitor: a redirect to the login page
+// or a page matching the Expired selector. It then logs in again and
+// returns ErrSessionExpired, and otherwise resp with its body intact.
+func (s *loginSession) check(req *http.Request, resp *http.Response, start time.Time) (*http.Response, error) {
+	expired, err := s.expired(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if !expired {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	log.Printf("[LOGIN] %s was served to a logged-out visitor", req.URL)
+	if err := s.relogin(req.Context(), start); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSessionExpired, err)
+	}
+	return nil, ErrSessionExpired
+}
+
+// expired reports whether resp shows the session has expired. It reads the
+// body of HTML responses when the Expired selector is set, replacing it
+// with a copy.
+func (s *loginSession) expired(resp *http.Response) (bool, error) {
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		location, err := resp.Location()
+		return err == nil && s.isLoginPage(location), nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if s.cfg.Expired == "" || mediaType != "text/html" {
+		return false, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return false, nil // not for us to judge
+	}
+	return doc.Find(s.cfg.Expired).Length() > 0, nil
+}
+
+// isLoginPage reports whether u is the login page, whatever its query
+func (s *loginSession) isLoginPage(u *url.URL) bool {
+	return strings.EqualFold(u.Host, s.loginURL.Host) && u.Path == s.loginURL.Path
+}
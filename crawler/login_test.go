@@ -0,0 +1,210 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+)
+
+// loginShop is a B2B shop of one product that needs a login. The login form
+// carries a CSRF token tied to a pre-login cookie, and logged-out visitors
+// are redirected to it, or shown it when expiredPage is set.
+type loginShop struct {
+	expiredPage bool
+
+	mu       sync.Mutex
+	tokens   map[string]string // CSRF token by pre-login cookie
+	sessions map[string]bool
+	logins   int
+	expireAt string // path whose next request finds the sessions expired
+}
+
+func newLoginShop() *loginShop {
+	return &loginShop{tokens: make(map[string]string), sessions: make(map[string]bool)}
+}
+
+func (s *loginShop) serve(t *testing.T) http.Handler {
+	product := testutil.MustGetFixture(t, "product_variable.html")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if r.URL.Path == "/login" {
+			s.serveLogin(w, r)
+			return
+		}
+		if r.URL.Path == s.expireAt {
+			s.sessions, s.expireAt = make(map[string]bool), ""
+		}
+		if c, err := r.Cookie("auth"); err != nil || !s.sessions[c.Value] {
+			if s.expiredPage {
+				w.Write([]byte(`<html><body><p>Please log in.</p><form id="login" action="/login" method="post"></form></body></html>`))
+				return
+			}
+			http.Redirect(w, r, "/login?next="+r.URL.Path, http.StatusFound)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/":
+			w.Write([]byte(`<html><body><ul><li class="product"><a class="woocommerce-LoopProduct-link" href="/product/hoodie"><h2 class="woocommerce-loop-product__title">Hoodie</h2></a></li></ul></body></html>`))
+		case "/account":
+			w.Write([]byte(`<html><body><a class="logout" href="/logout">Log out</a></body></html>`))
+		case "/product/hoodie":
+			w.Write([]byte(product))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// serveLogin shows the login form or checks its submission. The caller
+// holds s.mu.
+func (s *loginShop) serveLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		pre := fmt.Sprint("pre", len(s.tokens))
+		s.tokens[pre] = fmt.Sprint("token", len(s.tokens))
+		http.SetCookie(w, &http.Cookie{Name: "pre", Value: pre})
+		fmt.Fprintf(w, `<html><head><meta name="csrf-token" content="%s"></head><body>
+			<form class="search"><input name="q"></form>
+			<form action="/login" method="post">
+				<input type="hidden" name="_token" value="%s">
+				<input name="email"><input type="password" name="pass">
+				<input type="checkbox" name="remember" value="yes" checked>
+				<button type="submit" name="go">Log in</button>
+			</form></body></html>`, s.tokens[pre], s.tokens[pre])
+		return
+	}
+
+	pre, err := r.Cookie("pre")
+	if err != nil || s.tokens[pre.Value] == "" || r.PostFormValue("_token") != s.tokens[pre.Value] ||
+		r.Header.Get("X-CSRF-Token") != s.tokens[pre.Value] || r.PostFormValue("remember") != "yes" {
+		http.Error(w, "CSRF token mismatch", http.StatusForbidden)
+		return
+	}
+	if r.PostFormValue("email") != "buyer@example.com" || r.PostFormValue("pass") != "s3cret" {
+		w.Write([]byte(`<html><body><p class="error">Wrong password</p></body></html>`))
+		return
+	}
+	s.logins++
+	session := fmt.Sprint("session", s.logins)
+	s.sessions[session] = true
+	http.SetCookie(w, &http.Cookie{Name: "auth", Value: session, Path: "/"})
+	http.Redirect(w, r, "/account", http.StatusSeeOther)
+}
+
+func (s *loginShop) loginCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+// loginProfile is the default profile with the login of loginShop
+func loginProfile() *extract.SiteProfile {
+	profile := extract.DefaultSiteProfile()
+	profile.Login = &extract.LoginConfig{
+		URL:           "/login",
+		UsernameField: "email",
+		PasswordField: "pass",
+		UsernameEnv:   "SHOP_USER",
+		PasswordEnv:   "SHOP_PASSWORD",
+		CSRF:          extract.FieldSelector{Selector: `meta[name="csrf-token"]`, Attr: "content"},
+		CSRFHeader:    "X-CSRF-Token",
+		Success:       "a.logout",
+	}
+	return profile
+}
+
+func TestScraperLogin(t *testing.T) {
+	scrape := func(t *testing.T, shop *loginShop, profile *extract.SiteProfile) (*Scraper, error) {
+		server := testutil.CreateMockServer(shop.serve(t))
+		t.Cleanup(server.Close)
+		cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+		cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+		cfg.IgnoreRobotsTxt = true
+		cfg.Profile = profile
+		scraper := NewScraperWithConfig(cfg)
+		return scraper, scraper.Scrape(server.URL + "/")
+	}
+
+	t.Run("logs in before scraping", func(t *testing.T) {
+		t.Setenv("SHOP_USER", "buyer@example.com")
+		t.Setenv("SHOP_PASSWORD", "s3cret")
+		shop := newLoginShop()
+		scraper, err := scrape(t, shop, loginProfile())
+		require.NoError(t, err)
+		assert.Len(t, scraper.GetProducts(), 1)
+		assert.Equal(t, 1, shop.loginCount())
+		assert.Zero(t, scraper.GetFailedRequests())
+	})
+
+	t.Run("logs in again when redirected to the login page", func(t *testing.T) {
+		t.Setenv("SHOP_USER", "buyer@example.com")
+		t.Setenv("SHOP_PASSWORD", "s3cret")
+		shop := newLoginShop()
+		shop.expireAt = "/product/hoodie"
+		scraper, err := scrape(t, shop, loginProfile())
+		require.NoError(t, err)
+		assert.Len(t, scraper.GetProducts(), 1)
+		assert.Equal(t, 2, shop.loginCount())
+		assert.Zero(t, scraper.GetFailedRequests())
+	})
+
+	t.Run("logs in again on a page matching the expired selector", func(t *testing.T) {
+		t.Setenv("SHOP_USER", "buyer@example.com")
+		t.Setenv("SHOP_PASSWORD", "s3cret")
+		shop := newLoginShop()
+		shop.expiredPage = true
+		shop.expireAt = "/product/hoodie"
+		profile := loginProfile()
+		profile.Login.Expired = "form#login"
+		scraper, err := scrape(t, shop, profile)
+		require.NoError(t, err)
+		assert.Len(t, scraper.GetProducts(), 1)
+		assert.Equal(t, 2, shop.loginCount())
+	})
+
+	t.Run("fails on wrong credentials", func(t *testing.T) {
+		t.Setenv("SHOP_USER", "buyer@example.com")
+		t.Setenv("SHOP_PASSWORD", "wrong")
+		shop := newLoginShop()
+		scraper, err := scrape(t, shop, loginProfile())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `login failed`)
+		assert.Contains(t, err.Error(), "$SHOP_PASSWORD")
+		assert.Empty(t, scraper.GetProducts())
+	})
+
+	t.Run("fails without credentials", func(t *testing.T) {
+		t.Setenv("SHOP_USER", "")
+		t.Setenv("SHOP_PASSWORD", "")
+		_, err := scrape(t, newLoginShop(), loginProfile())
+		assert.ErrorContains(t, err, "$SHOP_USER is not set")
+	})
+}
+
+func TestListingScraperLogin(t *testing.T) {
+	t.Setenv("SHOP_USER", "buyer@example.com")
+	t.Setenv("SHOP_PASSWORD", "s3cret")
+	shop := newLoginShop()
+	server := testutil.CreateMockServer(shop.serve(t))
+	defer server.Close()
+
+	cfg := DefaultListingConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.CacheDir = 0, ""
+	cfg.IgnoreRobotsTxt = true
+	cfg.Profile = loginProfile()
+	ls := NewListingScraperWithConfig(cfg)
+	require.NoError(t, ls.Scrape(server.URL+"/"))
+	assert.Equal(t, 1, shop.loginCount())
+	assert.Zero(t, ls.GetFailedRequests())
+	require.Len(t, ls.GetProducts(), 1)
+	assert.True(t, strings.HasSuffix(ls.GetProducts()[0].URL, "/product/hoodie"))
+}
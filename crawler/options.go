@@ -0,0 +1,266 @@
+package crawler
+
+import (
+	"time"
+
+	"web-scraper/export"
+	"web-scraper/extract"
+	"web-scraper/validate"
+)
+
+// Option configures a Scraper, ListingScraper or WebCrawler. Options that
+// don't apply to what is being built are ignored, e.g. WithMaxPages for a
+// Scraper or WithProfile for a WebCrawler.
+type Option func(*options)
+
+// options holds the configuration being built; exactly one field is set
+type options struct {
+	scraper *ScraperConfig
+	crawler *CrawlerConfig
+}
+
+// applyScraperOptions applies opts to cfg
+func applyScraperOptions(cfg *ScraperConfig, opts []Option) {
+	o := &options{scraper: cfg}
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// applyCrawlerOptions applies opts to cfg
+func applyCrawlerOptions(cfg *CrawlerConfig, opts []Option) {
+	o := &options{crawler: cfg}
+	for _, opt := range opts {
+		opt(o)
+	}
+}
+
+// WithAllowedDomains restricts requests to the given hosts
+func WithAllowedDomains(domains ...string) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.AllowedDomains = domains
+		}
+		if o.crawler != nil {
+			o.crawler.AllowedDomains = domains
+		}
+	}
+}
+
+// WithMaxDepth limits how many links deep pages are followed
+func WithMaxDepth(depth int) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.MaxDepth = depth
+		}
+		if o.crawler != nil {
+			o.crawler.MaxDepth = depth
+		}
+	}
+}
+
+// WithParallelism sets the number of concurrent requests per domain. For a
+// Scraper it applies to listing pages only, see WithDetailLimits.
+func WithParallelism(parallelism int) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Parallelism = parallelism
+		}
+		if o.crawler != nil {
+			o.crawler.Parallelism = parallelism
+		}
+	}
+}
+
+// WithDelay sets the delay between requests to the same domain, and
+// randomDelay the extra random delay added on top of it. WebCrawler has no
+// random delay and ignores it.
+func WithDelay(delay, randomDelay time.Duration) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Delay, o.scraper.RandomDelay = delay, randomDelay
+		}
+		if o.crawler != nil {
+			o.crawler.Delay = delay
+		}
+	}
+}
+
+// WithDetailLimits sets the parallelism and delay of a Scraper's product
+// detail requests
+func WithDetailLimits(parallelism int, delay time.Duration) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.DetailParallelism, o.scraper.DetailDelay = parallelism, delay
+		}
+	}
+}
+
+// WithCacheDir caches responses in dir; an empty dir disables the cache
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.CacheDir = dir
+		}
+	}
+}
+
+// WithProfile sets the selectors used to scrape products
+func WithProfile(profile *extract.SiteProfile) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Profile = profile
+		}
+	}
+}
+
+// WithExporter hands each scraped product, or each page record of a
+// WebCrawler, to exporter instead of keeping it in memory
+func WithExporter(exporter export.Exporter) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Exporter = exporter
+		}
+		if o.crawler != nil {
+			o.crawler.Exporter = exporter
+		}
+	}
+}
+
+// WithImageStore downloads the images of scraped products into store
+func WithImageStore(store *export.ImageStore) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Images = store
+		}
+	}
+}
+
+// WithValidator checks each scraped product with validator, leaving out the
+// ones it rejects
+func WithValidator(validator *validate.Validator) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Validator = validator
+		}
+	}
+}
+
+// WithProxyPool sends the requests of a Scraper or ListingScraper through
+// the proxies of pool
+func WithProxyPool(pool *ProxyPool) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Proxies = pool
+		}
+	}
+}
+
+// WithCookieJar keeps the cookies of a Scraper or ListingScraper in jar, so
+// they can be seeded before and saved after the run
+func WithCookieJar(jar *CookieJar) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Cookies = jar
+		}
+	}
+}
+
+// WithMaxPages limits how many pages a WebCrawler visits
+func WithMaxPages(maxPages int) Option {
+	return func(o *options) {
+		if o.crawler != nil {
+			o.crawler.MaxPages = maxPages
+		}
+	}
+}
+
+// WithoutRobotsTxt neither fetches nor obeys robots.txt
+func WithoutRobotsTxt() Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.IgnoreRobotsTxt = true
+		}
+		if o.crawler != nil {
+			o.crawler.IgnoreRobotsTxt = true
+		}
+	}
+}
+
+// WithoutSitemaps stops a WebCrawler seeding its frontier from sitemaps
+func WithoutSitemaps() Option {
+	return func(o *options) {
+		if o.crawler != nil {
+			o.crawler.IgnoreSitemaps = true
+		}
+	}
+}
+
+// WithURLPolicy sets how a WebCrawler canonicalizes links. Scrapers take
+// theirs from the site profile.
+func WithURLPolicy(policy extract.URLPolicy) Option {
+	return func(o *options) {
+		if o.crawler != nil {
+			o.crawler.URLs = policy
+		}
+	}
+}
+
+// WithHeaderPolicy sets the browser profiles a WebCrawler poses as.
+// Scrapers take theirs from the site profile.
+func WithHeaderPolicy(policy extract.HeaderPolicy) Option {
+	return func(o *options) {
+		if o.crawler != nil {
+			o.crawler.Headers = policy
+		}
+	}
+}
+
+// WithRetryPolicy sets how failed requests are retried
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Retry = policy
+		}
+		if o.crawler != nil {
+			o.crawler.Retry = policy
+		}
+	}
+}
+
+// WithDrainTimeout sets how long Stop waits for in-flight requests
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.DrainTimeout = timeout
+		}
+		if o.crawler != nil {
+			o.crawler.DrainTimeout = timeout
+		}
+	}
+}
+
+// WithCheckpoint saves the run state to path every interval; 0 selects
+// DefaultCheckpointInterval. ListingScraper does not checkpoint.
+func WithCheckpoint(path string, interval time.Duration) Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.CheckpointPath, o.scraper.CheckpointInterval = path, interval
+		}
+		if o.crawler != nil {
+			o.crawler.CheckpointPath, o.crawler.CheckpointInterval = path, interval
+		}
+	}
+}
+
+// WithResume continues from the checkpoint set by WithCheckpoint
+func WithResume() Option {
+	return func(o *options) {
+		if o.scraper != nil {
+			o.scraper.Resume = true
+		}
+		if o.crawler != nil {
+			o.crawler.Resume = true
+		}
+	}
+}
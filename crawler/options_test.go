@@ -0,0 +1,81 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"web-scraper/extract"
+)
+
+func TestOptions(t *testing.T) {
+	profile := &extract.SiteProfile{Name: "custom"}
+	policy := RetryPolicy{MaxAttempts: 5}
+	opts := []Option{
+		WithAllowedDomains("a.com", "b.com"),
+		WithMaxDepth(4),
+		WithParallelism(3),
+		WithDelay(2*time.Second, time.Second),
+		WithDetailLimits(6, 100*time.Millisecond),
+		WithCacheDir(""),
+		WithProfile(profile),
+		WithMaxPages(25),
+		WithoutRobotsTxt(),
+		WithoutSitemaps(),
+		WithRetryPolicy(policy),
+		WithDrainTimeout(time.Second),
+		WithCheckpoint("run.json", time.Minute),
+		WithResume(),
+	}
+
+	t.Run("configure a scraper", func(t *testing.T) {
+		cfg := DefaultScraperConfig(nil)
+		applyScraperOptions(&cfg, opts)
+
+		assert.Equal(t, ScraperConfig{
+			AllowedDomains:     []string{"a.com", "b.com"},
+			MaxDepth:           4,
+			Parallelism:        3,
+			Delay:              2 * time.Second,
+			RandomDelay:        time.Second,
+			DetailParallelism:  6,
+			DetailDelay:        100 * time.Millisecond,
+			Profile:            profile,
+			IgnoreRobotsTxt:    true,
+			Retry:              policy,
+			DrainTimeout:       time.Second,
+			CheckpointPath:     "run.json",
+			CheckpointInterval: time.Minute,
+			Resume:             true,
+		}, cfg)
+	})
+
+	t.Run("configure a crawler", func(t *testing.T) {
+		cfg := DefaultCrawlerConfig(nil, DefaultMaxPages)
+		applyCrawlerOptions(&cfg, opts)
+
+		assert.Equal(t, CrawlerConfig{
+			AllowedDomains:     []string{"a.com", "b.com"},
+			MaxPages:           25,
+			MaxDepth:           4,
+			Parallelism:        3,
+			Delay:              2 * time.Second,
+			IgnoreRobotsTxt:    true,
+			IgnoreSitemaps:     true,
+			Retry:              policy,
+			DrainTimeout:       time.Second,
+			CheckpointPath:     "run.json",
+			CheckpointInterval: time.Minute,
+			Resume:             true,
+		}, cfg)
+	})
+
+	t.Run("defaults apply without options", func(t *testing.T) {
+		scraper := NewScraper()
+		assert.Equal(t, "woocommerce", scraper.profile.Name)
+		assert.NotNil(t, scraper.policy)
+
+		crawler := NewWebCrawler()
+		assert.Equal(t, DefaultMaxPages, crawler.maxPages)
+	})
+}
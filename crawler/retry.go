@@ -0,0 +1,299 @@
+package crawler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// retryableStatus lists the HTTP statuses worth retrying. 500 is left out as
+// it usually means the page itself is broken.
+var retryableStatus = map[int]bool{
+	http.StatusRequestTimeout:     true,
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// RetryPolicy decides whether and when a failed request is tried again
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first, 1 or less disables retries
+	BaseDelay   time.Duration // delay before the first retry, doubled for each later one
+	MaxDelay    time.Duration // upper bound for any delay, including Retry-After
+}
+
+// DefaultRetryPolicy returns the policy used by the scrapers and crawler
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   1 * time.Second,
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// Retryable reports whether a request that failed with status and err may
+// succeed when tried again. status is 0 when no response was received.
+func (p RetryPolicy) Retryable(status int, err error) bool {
+	if status != 0 {
+		return retryableStatus[status]
+	}
+	return isTransientError(err)
+}
+
+// Delay returns how long to wait before retry number attempt (starting at 1).
+// A Retry-After header wins over the exponential backoff; it reports false
+// when the server asks for a longer wait than MaxDelay, as retrying earlier
+// would only be throttled again. The backoff is capped at MaxDelay and
+// jittered between half and all of its value.
+func (p RetryPolicy) Delay(attempt int, headers *http.Header) (time.Duration, bool) {
+	if headers != nil {
+		if delay, ok := parseRetryAfter(headers.Get("Retry-After"), time.Now()); ok {
+			return delay, p.MaxDelay <= 0 || delay <= p.MaxDelay
+		}
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if half := int64(delay / 2); half > 0 {
+		delay = time.Duration(half + rand.Int63n(half+1))
+	}
+	return delay, true
+}
+
+// isTransientError reports whether err is a network failure that may clear
+// up on its own, such as a timeout or a reset connection, or a block page
+// that another proxy may get past
+func isTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrProxyBanned) || errors.Is(err, ErrSessionExpired) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// parseRetryAfter parses a Retry-After value given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	when, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := when.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+// retrier applies a RetryPolicy to collectors. Async collectors get failed
+// requests back straight away, so collector.Wait covers them, and the request
+// waits out its backoff in OnRequest. That runs in the request's own goroutine
+// before the collector takes a LimitRule slot, so a throttled URL doesn't
+// hold up the other workers. Synchronous collectors would block in OnRequest,
+// so their retries are fetched from a timer instead and retrier.wait covers them.
+type retrier struct {
+	policy  RetryPolicy
+	stop    <-chan struct{} // cancels retries still waiting out their backoff
+	mu      sync.Mutex
+	retried map[string]bool // URLs handed back for another attempt
+	pending int             // synchronous retries waiting for their timer or fetch
+	idle    *sync.Cond      // signalled when pending drops to zero
+}
+
+// newRetrier creates a retrier applying policy until stop is closed
+func newRetrier(policy RetryPolicy, stop <-chan struct{}) *retrier {
+	rt := &retrier{policy: policy, stop: stop, retried: make(map[string]bool)}
+	rt.idle = sync.NewCond(&rt.mu)
+	return rt
+}
+
+// retryAttemptKey returns the request context key holding the retry count of
+// rawURL. Child requests share their parent's context, so the key includes the URL.
+func retryAttemptKey(rawURL string) string {
+	return "retry_attempt:" + rawURL
+}
+
+// retryDueKey returns the request context key holding when rawURL may be retried
+func retryDueKey(rawURL string) string {
+	return "retry_due:" + rawURL
+}
+
+// retryAttempt returns how many times r has been retried so far
+func retryAttempt(r *colly.Request) int {
+	attempt, _ := r.Ctx.GetAny(retryAttemptKey(r.URL.String())).(int)
+	return attempt
+}
+
+// attach makes an async c hold retried requests back until their backoff has
+// passed, and makes any c drop cached responses with a retryable status.
+// colly caches those like any other response below 500, which would answer
+// the retry. It must be called before the collector's other OnRequest and
+// OnError callbacks are registered.
+func (rt *retrier) attach(c *colly.Collector) {
+	if c.Async {
+		c.OnRequest(func(r *colly.Request) {
+			due, ok := r.Ctx.GetAny(retryDueKey(r.URL.String())).(time.Time)
+			if ok {
+				rt.sleep(time.Until(due))
+			}
+		})
+	}
+
+	c.OnError(func(r *colly.Response, err error) {
+		if retryableStatus[r.StatusCode] {
+			evictCached(c.CacheDir, r.Request.URL.String())
+		}
+	})
+}
+
+// sleep waits for delay, returning early when the run is stopped
+func (rt *retrier) sleep(delay time.Duration) {
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-rt.stop:
+	}
+}
+
+// evictCached removes the response colly cached for rawURL under cacheDir
+func evictCached(cacheDir, rawURL string) {
+	if cacheDir == "" {
+		return
+	}
+	sum := sha1.Sum([]byte(rawURL))
+	hash := hex.EncodeToString(sum[:])
+	if err := os.Remove(filepath.Join(cacheDir, hash[:2], hash)); err != nil && !os.IsNotExist(err) {
+		log.Printf("[RETRY] Failed to evict cached %s: %v", rawURL, err)
+	}
+}
+
+// visitError filters the error a synchronous Visit returned for rawURL. Such
+// a Visit reports the first attempt's error even when the request was handed
+// back for a retry, whose outcome is left to the OnError callbacks.
+func (rt *retrier) visitError(rawURL string, err error) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	if err != nil && rt.retried[rawURL] {
+		return nil
+	}
+	return err
+}
+
+// retry hands the request behind r back to c if the policy allows it, and
+// reports whether it did. Callers count the request as failed when it
+// returns false.
+func (rt *retrier) retry(c *colly.Collector, r *colly.Response, err error) bool {
+	attempt := retryAttempt(r.Request) + 1
+	if attempt >= rt.policy.MaxAttempts || !rt.policy.Retryable(r.StatusCode, err) {
+		return false
+	}
+
+	rawURL := r.Request.URL.String()
+	delay, ok := rt.policy.Delay(attempt, r.Headers)
+	if !ok {
+		log.Printf("[RETRY] Giving up on %s: Retry-After %s exceeds %s", r.Request.URL, delay, rt.policy.MaxDelay)
+		return false
+	}
+	r.Request.Ctx.Put(retryAttemptKey(rawURL), attempt)
+	log.Printf("[RETRY] %s in %s (attempt %d of %d): %v", r.Request.URL, delay, attempt+1, rt.policy.MaxAttempts, err)
+
+	rt.mu.Lock()
+	rt.retried[rawURL] = true
+	rt.mu.Unlock()
+
+	if c.Async {
+		r.Request.Ctx.Put(retryDueKey(rawURL), time.Now().Add(delay))
+		r.Request.Retry()
+		return true
+	}
+
+	rt.mu.Lock()
+	rt.pending++
+	rt.mu.Unlock()
+
+	go func() {
+		defer rt.done()
+		rt.sleep(delay)
+		select {
+		case <-rt.stop:
+			// Left pending for the checkpoint
+		default:
+			r.Request.Retry()
+		}
+	}()
+	return true
+}
+
+// done marks a synchronous retry as finished
+func (rt *retrier) done() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.pending--
+	if rt.pending == 0 {
+		rt.idle.Broadcast()
+	}
+}
+
+// wait blocks until collectors are done and no synchronous retry is left.
+// Those retries only queue further requests from within their own fetch, so
+// once none is left the collectors can be waited for safely.
+func (rt *retrier) wait(collectors ...*colly.Collector) {
+	rt.mu.Lock()
+	for rt.pending > 0 {
+		rt.idle.Wait()
+	}
+	rt.mu.Unlock()
+
+	for _, c := range collectors {
+		c.Wait()
+	}
+}
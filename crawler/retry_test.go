@@ -0,0 +1,271 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+)
+
+// timeoutError is a net.Error that reports a timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// fastRetries keeps retry delays short in tests
+var fastRetries = RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+func TestRetryPolicyRetryable(t *testing.T) {
+	policy := DefaultRetryPolicy()
+
+	tests := []struct {
+		name     string
+		status   int
+		err      error
+		expected bool
+	}{
+		{name: "too many requests", status: 429, expected: true},
+		{name: "service unavailable", status: 503, expected: true},
+		{name: "bad gateway", status: 502, expected: true},
+		{name: "gateway timeout", status: 504, expected: true},
+		{name: "not found", status: 404, expected: false},
+		{name: "internal server error", status: 500, expected: false},
+		{name: "timeout", err: &url.Error{Op: "Get", URL: "http://example.com", Err: timeoutError{}}, expected: true},
+		{name: "connection reset", err: &url.Error{Op: "Get", URL: "http://example.com", Err: syscall.ECONNRESET}, expected: true},
+		{name: "unexpected EOF", err: fmt.Errorf("read: %w", io.ErrUnexpectedEOF), expected: true},
+		{name: "connection refused", err: &url.Error{Op: "Get", URL: "http://example.com", Err: syscall.ECONNREFUSED}, expected: false},
+		{name: "other error", err: errors.New("boom"), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, policy.Retryable(tt.status, tt.err))
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	t.Run("backs off exponentially with jitter", func(t *testing.T) {
+		for attempt, expected := range map[int]time.Duration{1: 100, 2: 200, 3: 400, 4: 800} {
+			expected *= time.Millisecond
+			for i := 0; i < 20; i++ {
+				delay, ok := policy.Delay(attempt, nil)
+				assert.True(t, ok)
+				assert.GreaterOrEqual(t, delay, expected/2)
+				assert.LessOrEqual(t, delay, expected)
+			}
+		}
+	})
+
+	t.Run("caps the backoff", func(t *testing.T) {
+		delay, _ := policy.Delay(10, nil)
+		assert.GreaterOrEqual(t, delay, 500*time.Millisecond)
+		assert.LessOrEqual(t, delay, time.Second)
+	})
+
+	t.Run("honours Retry-After seconds", func(t *testing.T) {
+		headers := http.Header{"Retry-After": []string{"0"}}
+		delay, ok := policy.Delay(3, &headers)
+		assert.True(t, ok)
+		assert.Equal(t, time.Duration(0), delay)
+	})
+
+	t.Run("gives up when Retry-After exceeds the cap", func(t *testing.T) {
+		headers := http.Header{"Retry-After": []string{"120"}}
+		delay, ok := policy.Delay(1, &headers)
+		assert.False(t, ok)
+		assert.Equal(t, 120*time.Second, delay)
+	})
+
+	t.Run("ignores invalid Retry-After", func(t *testing.T) {
+		headers := http.Header{"Retry-After": []string{"soon"}}
+		delay, ok := policy.Delay(1, &headers)
+		assert.True(t, ok)
+		assert.LessOrEqual(t, delay, 100*time.Millisecond)
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{name: "seconds", value: "30", expected: 30 * time.Second, ok: true},
+		{name: "padded seconds", value: " 5 ", expected: 5 * time.Second, ok: true},
+		{name: "http date", value: "Mon, 01 Jan 2024 12:00:45 GMT", expected: 45 * time.Second, ok: true},
+		{name: "date in the past", value: "Mon, 01 Jan 2024 11:00:00 GMT", expected: 0, ok: true},
+		{name: "negative seconds", value: "-5"},
+		{name: "empty", value: ""},
+		{name: "garbage", value: "later"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, ok := parseRetryAfter(tt.value, now)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, delay)
+		})
+	}
+}
+
+func retryScraperConfig(policy RetryPolicy) ScraperConfig {
+	cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+	cfg.IgnoreRobotsTxt = true
+	cfg.Retry = policy
+	return cfg
+}
+
+func TestScraperRetries(t *testing.T) {
+	t.Run("retries throttled listing and detail pages", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+		server.Fail("/", http.StatusTooManyRequests, 1, "0")
+		server.Fail("/product/test-product-1", http.StatusTooManyRequests, 2, "0")
+
+		scraper := NewScraperWithConfig(retryScraperConfig(fastRetries))
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Len(t, scraper.GetProducts(), 3)
+		assert.Equal(t, 0, scraper.GetFailedRequests())
+		assert.Equal(t, 2, server.Count("/"))
+		assert.Equal(t, 3, server.Count("/product/test-product-1"))
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+		server.Fail("/product/test-product-1", http.StatusServiceUnavailable, -1, "")
+
+		scraper := NewScraperWithConfig(retryScraperConfig(fastRetries))
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Len(t, scraper.GetProducts(), 2)
+		assert.Equal(t, 1, scraper.GetFailedRequests())
+		assert.Equal(t, fastRetries.MaxAttempts, server.Count("/product/test-product-1"))
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		server := testutil.CreateCountingServer(nil)
+		defer server.Close()
+
+		scraper := NewScraperWithConfig(retryScraperConfig(fastRetries))
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Equal(t, 1, scraper.GetFailedRequests())
+		assert.Equal(t, 1, server.Count("/"))
+	})
+
+	t.Run("gives up when Retry-After exceeds the max delay", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+		server.Fail("/", http.StatusTooManyRequests, 1, "3600")
+
+		scraper := NewScraperWithConfig(retryScraperConfig(fastRetries))
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Equal(t, 1, scraper.GetFailedRequests())
+		assert.Equal(t, 1, server.Count("/"))
+	})
+
+	t.Run("retries are not answered from the cache", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+		server.Fail("/product/test-product-1", http.StatusTooManyRequests, 1, "0")
+
+		cfg := retryScraperConfig(fastRetries)
+		cfg.CacheDir = t.TempDir()
+		scraper := NewScraperWithConfig(cfg)
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Len(t, scraper.GetProducts(), 3)
+		assert.Equal(t, 2, server.Count("/product/test-product-1"))
+
+		// The cached 200 is served from now on, the 429 was never kept
+		again := NewScraperWithConfig(cfg)
+		require.NoError(t, again.Scrape(server.URL+"/"))
+		assert.Len(t, again.GetProducts(), 3)
+		assert.Equal(t, 2, server.Count("/product/test-product-1"))
+	})
+
+	t.Run("a throttled page does not stall the other workers", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+		server.Fail("/product/test-product-1", http.StatusTooManyRequests, 1, "1")
+
+		cfg := retryScraperConfig(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Second})
+		cfg.DetailParallelism = 1
+		scraper := NewScraperWithConfig(cfg)
+
+		start := time.Now()
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Len(t, scraper.GetProducts(), 3)
+		for _, path := range []string{"/product/test-product-2", "/product/test-product-3"} {
+			hits := server.Hits(path)
+			require.NotEmpty(t, hits)
+			assert.Less(t, hits[0].Sub(start), 500*time.Millisecond)
+		}
+		assert.GreaterOrEqual(t, time.Since(start), time.Second) // Retry-After was honoured
+	})
+}
+
+func TestCrawlerRetries(t *testing.T) {
+	t.Run("retries do not count as visited pages", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{
+			"/":      `<html><body><a href="/flaky">Flaky</a></body></html>`,
+			"/flaky": `<html><body>Flaky</body></html>`,
+		})
+		defer server.Close()
+		server.Fail("/flaky", http.StatusServiceUnavailable, 2, "0")
+
+		cfg := DefaultCrawlerConfig([]string{"127.0.0.1"}, 10)
+		cfg.IgnoreRobotsTxt, cfg.IgnoreSitemaps = true, true
+		cfg.Retry = fastRetries
+		crawler := NewWebCrawlerWithConfig(cfg)
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		assert.Equal(t, 3, server.Count("/flaky"))
+		assert.Equal(t, 2, crawler.GetPagesVisited())
+		assert.Equal(t, 0, crawler.GetFailedRequests())
+	})
+
+	t.Run("backoff does not block a synchronous crawl", func(t *testing.T) {
+		server := testutil.CreateCountingServer(map[string]string{
+			"/":      `<html><body><a href="/flaky">Flaky</a><a href="/other">Other</a></body></html>`,
+			"/flaky": `<html><body>Flaky</body></html>`,
+			"/other": `<html><body>Other</body></html>`,
+		})
+		defer server.Close()
+		server.Fail("/flaky", http.StatusServiceUnavailable, 1, "1")
+
+		cfg := DefaultCrawlerConfig([]string{"127.0.0.1"}, 10)
+		cfg.IgnoreRobotsTxt, cfg.IgnoreSitemaps = true, true
+		cfg.Parallelism = 1
+		cfg.Retry = RetryPolicy{MaxAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Second}
+		crawler := NewWebCrawlerWithConfig(cfg)
+
+		start := time.Now()
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		assert.Equal(t, 2, server.Count("/flaky"))
+		assert.Less(t, server.Hits("/other")[0].Sub(start), 500*time.Millisecond)
+		assert.Equal(t, 0, crawler.GetFailedRequests())
+	})
+}
@@ -0,0 +1,231 @@
+package crawler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/gocolly/colly/v2"
+	"github.com/temoto/robotstxt"
+)
+
+// robotsUserAgent is the product token matched against robots.txt groups.
+// Requests still send the browser User-Agent set by the scrapers.
+const robotsUserAgent = "web-scraper"
+
+// BlockedURL records a request that was skipped by the robots policy
+type BlockedURL struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// robotsFile is the cached robots.txt of a single host
+type robotsFile struct {
+	once   sync.Once // guards the fetch, so concurrent requests for the host wait for one download
+	data   *robotstxt.RobotsData
+	status int // HTTP status of the robots.txt response, 0 if the fetch failed
+}
+
+// RobotsPolicy fetches robots.txt once per host and decides whether URLs may
+// be requested. It is safe for concurrent use and may be shared between
+// collectors.
+type RobotsPolicy struct {
+	userAgent string
+	client    *http.Client
+	mu        sync.Mutex
+	hosts     map[string]*robotsFile
+	blocked   []BlockedURL
+}
+
+// NewRobotsPolicy creates a policy that matches robots.txt groups for userAgent
+func NewRobotsPolicy(userAgent string) *RobotsPolicy {
+	return &RobotsPolicy{
+		userAgent: userAgent,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		hosts:     make(map[string]*robotsFile),
+	}
+}
+
+// robots returns the cached robots.txt for the host of u, fetching it on first
+// use. The download happens outside p.mu so a slow host doesn't hold up others.
+func (p *RobotsPolicy) robots(u *url.URL) *robotsFile {
+	key := u.Scheme + "://" + u.Host
+
+	p.mu.Lock()
+	file, ok := p.hosts[key]
+	if !ok {
+		file = &robotsFile{}
+		p.hosts[key] = file
+	}
+	p.mu.Unlock()
+
+	file.once.Do(func() {
+		file.data, file.status = p.fetch(key + "/robots.txt")
+	})
+	return file
+}
+
+// fetch downloads and parses a robots.txt file. Network and parse errors
+// are treated as "allow all" so an unreachable robots.txt doesn't stop a run.
+func (p *RobotsPolicy) fetch(robotsURL string) (*robotstxt.RobotsData, int) {
+	resp, err := p.client.Get(robotsURL)
+	if err != nil {
+		log.Printf("[ROBOTS] Failed to fetch %s: %v", robotsURL, err)
+		data, _ := robotstxt.FromStatusAndBytes(http.StatusNotFound, nil)
+		return data, 0
+	}
+	defer resp.Body.Close()
+
+	data, err := robotstxt.FromResponse(resp)
+	if err != nil {
+		log.Printf("[ROBOTS] Ignoring invalid %s: %v", robotsURL, err)
+		data, _ = robotstxt.FromStatusAndBytes(http.StatusNotFound, nil)
+	}
+	return data, resp.StatusCode
+}
+
+// Allowed reports whether u may be requested and, if not, why
+func (p *RobotsPolicy) Allowed(u *url.URL) (bool, string) {
+	file := p.robots(u)
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	if file.data.TestAgent(path, p.userAgent) {
+		return true, ""
+	}
+	if file.status >= 500 {
+		return false, fmt.Sprintf("robots.txt unavailable (HTTP %d)", file.status)
+	}
+	return false, fmt.Sprintf("disallowed by robots.txt for %s", p.userAgent)
+}
+
+// CrawlDelay returns the Crawl-delay that applies to the host of u
+func (p *RobotsPolicy) CrawlDelay(u *url.URL) time.Duration {
+	return p.robots(u).data.FindGroup(p.userAgent).CrawlDelay
+}
+
+// Sitemaps returns the Sitemap: locations listed in the robots.txt for the host of u
+func (p *RobotsPolicy) Sitemaps(u *url.URL) []string {
+	return p.robots(u).data.Sitemaps
+}
+
+// record adds a blocked URL to the report
+func (p *RobotsPolicy) record(u string, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blocked = append(p.blocked, BlockedURL{URL: u, Reason: reason})
+}
+
+// Blocked returns the URLs skipped by the policy so far
+func (p *RobotsPolicy) Blocked() []BlockedURL {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	blocked := make([]BlockedURL, len(p.blocked))
+	copy(blocked, p.blocked)
+	return blocked
+}
+
+// robotsGate applies a RobotsPolicy to a single collector. Disallowed
+// requests are aborted, and each host gets its own LimitRule whose delay is
+// raised to the host's Crawl-delay.
+type robotsGate struct {
+	policy    *RobotsPolicy
+	collector *colly.Collector
+	rule      colly.LimitRule // template for the per-host rules
+	mu        sync.Mutex
+	limited   map[string]bool
+}
+
+// newRobotsGate creates a gate for c. rule replaces the collector's usual
+// "*" LimitRule, which must not be installed as it would shadow the
+// per-host rules.
+func newRobotsGate(policy *RobotsPolicy, c *colly.Collector, rule colly.LimitRule) *robotsGate {
+	return &robotsGate{
+		policy:    policy,
+		collector: c,
+		rule:      rule,
+		limited:   make(map[string]bool),
+	}
+}
+
+// allow checks r against the policy from an OnRequest callback. Blocked
+// requests are aborted and recorded; allowed ones get their host limited.
+func (g *robotsGate) allow(r *colly.Request) bool {
+	allowed, reason := g.policy.Allowed(r.URL)
+	if !allowed {
+		r.Abort()
+		g.policy.record(r.URL.String(), reason)
+		log.Printf("[ROBOTS] Skipping %s: %s", r.URL, reason)
+		return false
+	}
+
+	g.limitHost(r.URL)
+	return true
+}
+
+// limitHost installs the LimitRule for the host of u once
+func (g *robotsGate) limitHost(u *url.URL) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.limited[u.Host] {
+		return
+	}
+	g.limited[u.Host] = true
+
+	rule := g.rule
+	if delay := g.policy.CrawlDelay(u); delay > rule.Delay {
+		log.Printf("[ROBOTS] Using Crawl-delay of %s for %s", delay, u.Host)
+		rule.Delay = delay
+		rule.Parallelism = 1
+	}
+	if rule.Parallelism == 0 && rule.Delay == 0 && rule.RandomDelay == 0 {
+		return
+	}
+
+	rule.DomainGlob = glob.QuoteMeta(u.Host)
+	if err := g.collector.Limit(&rule); err != nil {
+		log.Printf("[ROBOTS] Failed to limit %s: %v", u.Host, err)
+	}
+}
+
+// applyLimit installs rule on c, routing it through a robotsGate unless
+// policy is nil. It returns the gate, or nil when robots.txt is ignored.
+func applyLimit(c *colly.Collector, policy *RobotsPolicy, rule colly.LimitRule) *robotsGate {
+	if policy != nil {
+		return newRobotsGate(policy, c, rule)
+	}
+
+	if rule.Parallelism != 0 || rule.Delay != 0 || rule.RandomDelay != 0 {
+		c.Limit(&rule)
+	}
+	return nil
+}
+
+// checkStartURL returns an error when the policy blocks the start URL
+func checkStartURL(policy *RobotsPolicy, startURL string) error {
+	if policy == nil {
+		return nil
+	}
+
+	u, err := url.Parse(startURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if allowed, reason := policy.Allowed(u); !allowed {
+		policy.record(startURL, reason)
+		return fmt.Errorf("start URL %s blocked: %s", startURL, reason)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package crawler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+)
+
+// robotsSite returns the routes of a small site served with the given robots.txt
+func robotsSite(robots string) map[string]string {
+	return map[string]string{
+		"/robots.txt":     robots,
+		"/":               `<html><body><a href="/public">Public</a><a href="/private/secret">Private</a></body></html>`,
+		"/public":         "<html><body>Page</body></html>",
+		"/private/secret": "<html><body>Page</body></html>",
+	}
+}
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	return u
+}
+
+func TestRobotsPolicy(t *testing.T) {
+	t.Run("applies disallow rules", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite("User-agent: *\nDisallow: /private\n"))
+		defer server.Close()
+
+		policy := NewRobotsPolicy(robotsUserAgent)
+
+		allowed, reason := policy.Allowed(mustParseURL(t, server.URL+"/public"))
+		assert.True(t, allowed)
+		assert.Empty(t, reason)
+
+		allowed, reason = policy.Allowed(mustParseURL(t, server.URL+"/private/page"))
+		assert.False(t, allowed)
+		assert.Contains(t, reason, "disallowed by robots.txt")
+	})
+
+	t.Run("matches the most specific user agent group", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite("User-agent: *\nDisallow:\n\nUser-agent: web-scraper\nDisallow: /\n"))
+		defer server.Close()
+
+		policy := NewRobotsPolicy(robotsUserAgent)
+		allowed, _ := policy.Allowed(mustParseURL(t, server.URL+"/public"))
+		assert.False(t, allowed)
+	})
+
+	t.Run("caches robots.txt per host", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite("User-agent: *\nDisallow: /private\n"))
+		defer server.Close()
+
+		policy := NewRobotsPolicy(robotsUserAgent)
+		for i := 0; i < 5; i++ {
+			policy.Allowed(mustParseURL(t, server.URL+"/public"))
+		}
+		policy.CrawlDelay(mustParseURL(t, server.URL+"/"))
+
+		assert.Equal(t, 1, server.Count("/robots.txt"))
+	})
+
+	t.Run("missing robots.txt allows everything", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite(""))
+		defer server.Close()
+		server.Fail("/robots.txt", http.StatusNotFound, -1, "")
+
+		policy := NewRobotsPolicy(robotsUserAgent)
+		allowed, _ := policy.Allowed(mustParseURL(t, server.URL+"/private/page"))
+		assert.True(t, allowed)
+	})
+
+	t.Run("server error disallows everything", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite(""))
+		defer server.Close()
+		server.Fail("/robots.txt", http.StatusServiceUnavailable, -1, "")
+
+		policy := NewRobotsPolicy(robotsUserAgent)
+		allowed, reason := policy.Allowed(mustParseURL(t, server.URL+"/"))
+		assert.False(t, allowed)
+		assert.Equal(t, "robots.txt unavailable (HTTP 503)", reason)
+	})
+
+	t.Run("unreachable host allows everything", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite(""))
+		serverURL := server.URL
+		server.Close()
+
+		policy := NewRobotsPolicy(robotsUserAgent)
+		allowed, _ := policy.Allowed(mustParseURL(t, serverURL+"/"))
+		assert.True(t, allowed)
+	})
+
+	t.Run("a slow robots.txt does not block other hosts", func(t *testing.T) {
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-release
+		}))
+		defer slow.Close()
+		defer close(release)
+		fast := testutil.CreateCountingServer(robotsSite("User-agent: *\nDisallow: /private\n"))
+		defer fast.Close()
+
+		policy := NewRobotsPolicy(robotsUserAgent)
+		go policy.Allowed(mustParseURL(t, slow.URL+"/"))
+		require.Eventually(t, func() bool {
+			policy.mu.Lock()
+			defer policy.mu.Unlock()
+			return len(policy.hosts) == 1
+		}, time.Second, time.Millisecond)
+
+		done := make(chan bool)
+		go func() {
+			allowed, _ := policy.Allowed(mustParseURL(t, fast.URL+"/private"))
+			policy.Blocked()
+			done <- allowed
+		}()
+		select {
+		case allowed := <-done:
+			assert.False(t, allowed)
+		case <-time.After(2 * time.Second):
+			t.Fatal("fetching one host's robots.txt blocked another host")
+		}
+	})
+
+	t.Run("reads crawl delay", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite("User-agent: *\nCrawl-delay: 2.5\n"))
+		defer server.Close()
+
+		policy := NewRobotsPolicy(robotsUserAgent)
+		assert.Equal(t, 2500*time.Millisecond, policy.CrawlDelay(mustParseURL(t, server.URL+"/")))
+	})
+}
+
+func TestCrawlerRobots(t *testing.T) {
+	t.Run("skips disallowed links and records them", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite("User-agent: *\nDisallow: /private\n"))
+		defer server.Close()
+
+		crawler := NewWebCrawler(WithAllowedDomains("127.0.0.1"), WithMaxPages(10))
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		assert.Equal(t, 1, server.Count("/public"))
+		assert.Equal(t, 0, server.Count("/private/secret"))
+		assert.Equal(t, 2, crawler.GetPagesVisited())
+
+		blocked := crawler.GetBlockedURLs()
+		require.Len(t, blocked, 1)
+		assert.Equal(t, server.URL+"/private/secret", blocked[0].URL)
+		assert.Contains(t, blocked[0].Reason, "robots.txt")
+	})
+
+	t.Run("can ignore robots.txt", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite("User-agent: *\nDisallow: /private\n"))
+		defer server.Close()
+
+		cfg := DefaultCrawlerConfig([]string{"127.0.0.1"}, 10)
+		cfg.IgnoreRobotsTxt = true
+		crawler := NewWebCrawlerWithConfig(cfg)
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		assert.Equal(t, 1, server.Count("/private/secret"))
+		assert.Equal(t, 0, server.Count("/robots.txt"))
+		assert.Empty(t, crawler.GetBlockedURLs())
+	})
+
+	t.Run("refuses a blocked start URL", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite("User-agent: *\nDisallow: /\n"))
+		defer server.Close()
+
+		crawler := NewWebCrawler(WithAllowedDomains("127.0.0.1"), WithMaxPages(10))
+		err := crawler.Crawl(server.URL + "/")
+		assert.Error(t, err)
+		assert.Equal(t, 0, server.Count("/"))
+	})
+
+	t.Run("honours crawl delay", func(t *testing.T) {
+		server := testutil.CreateCountingServer(robotsSite("User-agent: *\nCrawl-delay: 0.3\n"))
+		defer server.Close()
+
+		crawler := NewWebCrawler(WithAllowedDomains("127.0.0.1"), WithMaxPages(10))
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		times := append(server.Hits("/"), server.Hits("/public")...)
+		times = append(times, server.Hits("/private/secret")...)
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		require.Len(t, times, 3)
+		for i := 1; i < len(times); i++ {
+			assert.GreaterOrEqual(t, times[i].Sub(times[i-1]), 300*time.Millisecond)
+		}
+	})
+}
+
+func TestScraperRobots(t *testing.T) {
+	t.Run("skips disallowed detail pages", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+		server.Route("/robots.txt", "User-agent: *\nDisallow: /product/test-product-2\n")
+
+		cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+		cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+		scraper := NewScraperWithConfig(cfg)
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Len(t, scraper.GetProducts(), 2)
+		assert.Equal(t, 0, server.Count("/product/test-product-2"))
+
+		blocked := scraper.GetBlockedURLs()
+		require.Len(t, blocked, 1)
+		assert.Equal(t, server.URL+"/product/test-product-2", blocked[0].URL)
+	})
+}
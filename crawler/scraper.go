@@ -0,0 +1,647 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"web-scraper/export"
+	"web-scraper/extract"
+	"web-scraper/validate"
+)
+
+// Scraper holds the scraper configuration and state
+type Scraper struct {
+	collector   *colly.Collector
+	detailCollector *colly.Collector
+	products    []extract.ProductDetail
+	mu          sync.Mutex
+	visited     map[string]bool
+	failed      int
+	profile     *extract.SiteProfile
+	policy      *RobotsPolicy // nil when robots.txt is ignored
+	listRobots  *robotsGate
+	detailRobots *robotsGate // shares policy with listRobots
+	listings    map[string]bool // listing pages requested so far
+	completed   map[string]bool // listing and detail pages fetched and parsed
+	checkpoint  checkpointSettings
+	retries     *retrier // shared by both collectors
+	stopper     *stopper
+	transport   *abortTransport // listing requests
+	detailTransport *abortTransport // detail pages and images, the listing transport unless DetailProxies is set
+	exporter    export.Exporter // nil keeps products in memory
+	validator   *validate.Validator // nil accepts every product
+	exportErr   error           // first failed export
+	collected   int             // products scraped, kept or exported
+	images      *imageFetcher   // nil when images aren't downloaded
+	awaiting    map[string]bool // detail pages whose images are downloading
+	proxies     *ProxyPool      // nil when requests go direct
+	detailProxies *ProxyPool    // the pool of detailTransport
+	headers     *headerRotator  // shared by both collectors, so they pose as one browser
+	cookies     *CookieJar      // shared by both collectors
+}
+
+// ScraperConfig holds the tunable settings for a Scraper
+type ScraperConfig struct {
+	AllowedDomains    []string
+	MaxDepth          int
+	Parallelism       int // concurrent listing requests
+	Delay             time.Duration
+	RandomDelay       time.Duration
+	DetailParallelism int // concurrent detail page requests
+	DetailDelay       time.Duration
+	CacheDir          string
+	Profile           *extract.SiteProfile // nil selects DefaultSiteProfile
+	IgnoreRobotsTxt   bool
+	Retry             RetryPolicy
+	DrainTimeout      time.Duration // how long Stop waits for in-flight requests, 0 selects DefaultDrainTimeout
+	// CheckpointPath is where the scrape state is saved periodically, empty disables checkpoints
+	CheckpointPath     string
+	CheckpointInterval time.Duration // 0 selects DefaultCheckpointInterval
+	Resume             bool          // continue from the checkpoint at CheckpointPath
+	// Exporter receives each product as it is scraped. Products are then not
+	// kept in memory, so GetProducts and checkpoints don't include them.
+	Exporter export.Exporter
+	// Images stores the images of scraped products, nil skips downloading
+	// them. Exported products then reference the stored files.
+	Images *export.ImageStore
+	// Validator checks each product before it is kept or exported. Rejected
+	// products are left out and don't count as scraped.
+	Validator *validate.Validator
+	// Proxies sends the requests through a pool of proxies, nil sends them
+	// directly. DetailProxies gives detail pages and images a pool of their
+	// own, nil uses Proxies for them too.
+	Proxies       *ProxyPool
+	DetailProxies *ProxyPool
+	// Cookies keeps the cookies of both collectors, scoped per proxy. Nil
+	// keeps them in memory for the run only.
+	Cookies *CookieJar
+}
+
+// DefaultScraperConfig returns the configuration used by NewScraper
+func DefaultScraperConfig(allowedDomains []string) ScraperConfig {
+	return ScraperConfig{
+		AllowedDomains:    allowedDomains,
+		MaxDepth:          3,
+		Parallelism:       4,
+		Delay:             500 * time.Millisecond,
+		RandomDelay:       500 * time.Millisecond,
+		DetailParallelism: 2,
+		DetailDelay:       1 * time.Second,
+		CacheDir:          "./cache",
+		Profile:           extract.DefaultSiteProfile(),
+		Retry:             DefaultRetryPolicy(),
+	}
+}
+
+// NewScraper creates a new scraper with advanced configuration. It starts
+// from DefaultScraperConfig and applies opts, e.g.
+//
+//	NewScraper(WithAllowedDomains("example.com"), WithCacheDir(""))
+func NewScraper(opts ...Option) *Scraper {
+	cfg := DefaultScraperConfig(nil)
+	applyScraperOptions(&cfg, opts)
+	return NewScraperWithConfig(cfg)
+}
+
+// NewScraperWithConfig creates a new scraper from an explicit configuration
+func NewScraperWithConfig(cfg ScraperConfig) *Scraper {
+	stopper := newStopper(cfg.DrainTimeout)
+	s := &Scraper{
+		products:  make([]extract.ProductDetail, 0),
+		visited:   make(map[string]bool),
+		profile:   cfg.Profile,
+		listings:  make(map[string]bool),
+		completed: make(map[string]bool),
+		awaiting:  make(map[string]bool),
+		retries:   newRetrier(cfg.Retry, stopper.done()),
+		stopper:   stopper,
+		transport: newAbortTransport(stopper.aborted),
+		exporter:  cfg.Exporter,
+		validator: cfg.Validator,
+		checkpoint: checkpointSettings{
+			path:     cfg.CheckpointPath,
+			interval: cfg.CheckpointInterval,
+			resume:   cfg.Resume,
+		},
+	}
+	if s.profile == nil {
+		s.profile = extract.DefaultSiteProfile()
+	}
+	s.headers = newHeaderRotator(s.profile.Headers)
+	s.detailTransport = s.transport
+	if cfg.Proxies != nil {
+		s.setProxyPool(cfg.Proxies)
+	}
+	if cfg.DetailProxies != nil {
+		s.detailTransport = newAbortTransport(stopper.aborted)
+		s.detailProxies = cfg.DetailProxies
+		s.detailTransport.setProxyPool(cfg.DetailProxies)
+	}
+	s.cookies = cfg.Cookies
+	if s.cookies == nil {
+		s.cookies = NewCookieJar()
+	}
+	s.transport.setCookieJar(s.cookies)
+	s.detailTransport.setCookieJar(s.cookies)
+
+	// Main collector for listing pages
+	s.collector = newScraperCollector(cfg, s.transport)
+
+	// Separate collector for detail pages (for more granular control). It is
+	// not a Clone, which would share the listing collector's rate limits.
+	s.detailCollector = newScraperCollector(cfg, s.detailTransport)
+
+	// Both collectors share one robots.txt cache
+	if !cfg.IgnoreRobotsTxt {
+		s.policy = NewRobotsPolicy(robotsUserAgent)
+	}
+
+	// Configure rate limiting
+	s.listRobots = applyLimit(s.collector, s.policy, colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: cfg.Parallelism,
+		Delay:       cfg.Delay,
+		RandomDelay: cfg.RandomDelay, // Random delay to seem more human
+	})
+
+	s.detailRobots = applyLimit(s.detailCollector, s.policy, colly.LimitRule{
+		DomainGlob:  "*",
+		Parallelism: cfg.DetailParallelism,
+		Delay:       cfg.DetailDelay,
+	})
+
+	s.retries.attach(s.collector)
+	s.retries.attach(s.detailCollector)
+	s.setupCallbacks()
+
+	// Images are fetched like detail pages, sharing their rate limits
+	if cfg.Images != nil {
+		s.images = newImageFetcher(s.detailCollector, cfg.Images, s.retries, s.headers, func(r *colly.Request) bool {
+			if !s.stopper.allow(r) {
+				return false
+			}
+			return s.detailRobots == nil || s.detailRobots.allow(r)
+		}, s.recordFailure)
+	}
+
+	return s
+}
+
+// newScraperCollector creates a collector with its own HTTP backend sending
+// requests through transport, which handles the cookies
+func newScraperCollector(cfg ScraperConfig, transport http.RoundTripper) *colly.Collector {
+	c := colly.NewCollector(
+		colly.AllowedDomains(cfg.AllowedDomains...),
+		colly.MaxDepth(cfg.MaxDepth),
+		colly.Async(true), // Enable async for parallel scraping
+	)
+	if cfg.CacheDir != "" {
+		c.CacheDir = cfg.CacheDir
+	}
+	c.WithTransport(transport)
+	c.DisableCookies()
+	return c
+}
+
+// SetProxy sends all requests through a pool of proxyURLs with equal
+// weights. Use ScraperConfig.Proxies for a pool with other settings.
+func (s *Scraper) SetProxy(proxyURLs []string) error {
+	if len(proxyURLs) == 0 {
+		return nil
+	}
+
+	proxies := make([]Proxy, len(proxyURLs))
+	for i, proxyURL := range proxyURLs {
+		proxies[i] = Proxy{URL: proxyURL}
+	}
+	pool, err := NewProxyPool(ProxyPoolConfig{Proxies: proxies})
+	if err != nil {
+		return fmt.Errorf("failed to create proxy pool: %w", err)
+	}
+	s.setProxyPool(pool)
+
+	return nil
+}
+
+// setProxyPool sends requests through pool. It is set on the transports,
+// as SetProxyFunc would replace them.
+func (s *Scraper) setProxyPool(pool *ProxyPool) {
+	s.proxies, s.detailProxies = pool, pool
+	s.transport.setProxyPool(pool)
+	s.detailTransport.setProxyPool(pool)
+}
+
+// GetProxyStats returns how each proxy performed, those of the listing pool
+// first, nil without proxies
+func (s *Scraper) GetProxyStats() []ProxyStats {
+	var stats []ProxyStats
+	if s.proxies != nil {
+		stats = s.proxies.Stats()
+	}
+	if s.detailProxies != nil && s.detailProxies != s.proxies {
+		stats = append(stats, s.detailProxies.Stats()...)
+	}
+	return stats
+}
+
+// setupCallbacks configures all the collector callbacks
+func (s *Scraper) setupCallbacks() {
+	// Set headers to avoid detection
+	s.collector.OnRequest(func(r *colly.Request) {
+		// Recorded before the stop check so aborted pages stay pending
+		s.mu.Lock()
+		s.listings[r.URL.String()] = true
+		s.mu.Unlock()
+
+		if !s.stopper.allow(r) {
+			return
+		}
+		if s.listRobots != nil && !s.listRobots.allow(r) {
+			return
+		}
+		s.headers.page(r)
+		log.Printf("[LIST] Visiting: %s", r.URL)
+	})
+
+	s.detailCollector.OnRequest(func(r *colly.Request) {
+		if !s.stopper.allow(r) {
+			return
+		}
+		if s.detailRobots != nil && !s.detailRobots.allow(r) {
+			return
+		}
+		s.headers.page(r)
+		log.Printf("[DETAIL] Visiting: %s", r.URL)
+	})
+
+	// Handle errors with retry logic
+	s.collector.OnError(func(r *colly.Response, err error) {
+		log.Printf("[ERROR] %s: %v (Status: %d)", r.Request.URL, err, r.StatusCode)
+		
+		// Throttling and transient errors are retried with backoff
+		if s.retries.retry(s.collector, r, err) {
+			return
+		}
+
+		s.recordFailure()
+	})
+
+	s.detailCollector.OnError(func(r *colly.Response, err error) {
+		log.Printf("[ERROR] Detail page %s: %v", r.Request.URL, err)
+		if s.retries.retry(s.detailCollector, r, err) {
+			return
+		}
+		s.recordFailure()
+	})
+
+	// Remember finished pages so a resumed scrape skips them
+	s.collector.OnScraped(s.markCompleted)
+	s.detailCollector.OnScraped(s.markCompleted)
+
+	listing := s.profile.Listing
+	detail := s.profile.Detail
+
+	// Parse product listings
+	s.collector.OnHTML(listing.Item, func(e *colly.HTMLElement) {
+		productURL := s.profile.URLs.Resolve(e.Request, listing.URL.Extract(e))
+		
+		s.mu.Lock()
+		if !s.visited[productURL] && productURL != "" {
+			s.visited[productURL] = true
+			s.mu.Unlock()
+			
+			// Visit detail page with the detail collector
+			s.detailCollector.Visit(productURL)
+		} else {
+			s.mu.Unlock()
+		}
+	})
+
+	// Handle pagination
+	if listing.NextPage != "" {
+		s.collector.OnHTML(listing.NextPage, func(e *colly.HTMLElement) {
+			nextURL := s.profile.URLs.Resolve(e.Request, e.Attr("href"))
+			if nextURL != "" {
+				// Record the page before queueing it, as an async collector runs
+				// its OnRequest after this page is marked completed and a
+				// checkpoint taken in between would lose it
+				s.mu.Lock()
+				s.listings[nextURL] = true
+				s.mu.Unlock()
+
+				e.Request.Visit(nextURL)
+			}
+		})
+	}
+
+	// Parse product detail pages
+	s.detailCollector.OnHTML(detail.Root, func(e *colly.HTMLElement) {
+		product := s.profile.ExtractDetail(e)
+		if product.Name == "" {
+			return
+		}
+		log.Printf("[FOUND] %s - %s", product.Name, product.Price)
+		if s.images == nil {
+			s.mu.Lock()
+			s.collect(product)
+			s.mu.Unlock()
+			return
+		}
+
+		// The page completes once its images are stored
+		s.mu.Lock()
+		s.awaiting[product.URL] = true
+		s.mu.Unlock()
+		s.images.fetch(e.Request.URL.Hostname(), product.ImageURLs(), func(images []extract.Image) {
+			s.collectWithImages(product, images)
+		})
+	})
+}
+
+// collectWithImages collects product once its images are downloaded and
+// completes its page. A product whose downloads were cut short by Stop is
+// left pending, so a resumed scrape fetches it again.
+func (s *Scraper) collectWithImages(product extract.ProductDetail, images []extract.Image) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !stored(images) && s.Stopped() {
+		return
+	}
+	product.SetImages(images)
+	s.collect(product)
+	delete(s.awaiting, product.URL)
+	s.completed[product.URL] = true
+}
+
+// collect keeps product, or hands it to the exporter when one is set, unless
+// the validator rejects it. The caller must hold s.mu.
+func (s *Scraper) collect(product extract.ProductDetail) {
+	if s.validator != nil {
+		accepted, err := s.validator.Check(product)
+		if err != nil && s.exportErr == nil {
+			log.Printf("[EXPORT] %v", err)
+			s.exportErr = err
+		}
+		if !accepted {
+			return
+		}
+	}
+	s.collected++
+	if s.exporter == nil {
+		s.products = append(s.products, product)
+		return
+	}
+	if err := s.exporter.Export(product); err != nil && s.exportErr == nil {
+		log.Printf("[EXPORT] %v", err)
+		s.exportErr = err
+	}
+}
+
+// markCompleted records a page whose callbacks have all run, unless its
+// images are still downloading
+func (s *Scraper) markCompleted(r *colly.Response) {
+	s.mu.Lock()
+	if pageURL := r.Request.URL.String(); !s.awaiting[pageURL] {
+		s.completed[pageURL] = true
+	}
+	s.mu.Unlock()
+}
+
+// recordFailure counts a request that ended in an error without being retried
+func (s *Scraper) recordFailure() {
+	s.mu.Lock()
+	s.failed++
+	s.mu.Unlock()
+}
+
+// Scrape starts the scraping process from the given URL
+func (s *Scraper) Scrape(startURL string) error {
+	return s.ScrapeContext(context.Background(), startURL)
+}
+
+// ScrapeContext is Scrape bound to ctx. Once ctx is done, requests in flight
+// are aborted, no new ones are made, and it returns an *IncompleteError
+// wrapping ctx.Err(). The products scraped so far stay available.
+func (s *Scraper) ScrapeContext(ctx context.Context, startURL string) error {
+	release := s.stopper.watch(ctx)
+	defer release()
+
+	err := s.scrape(startURL)
+	if s.stopper.wasAborted() {
+		return newIncompleteError(ctx.Err(), s.snapshot(startURL), s.GetProductCount())
+	}
+	return err
+}
+
+// scrape runs the scrape from startURL until it finishes or is stopped
+func (s *Scraper) scrape(startURL string) error {
+	// Validate URL
+	_, err := url.Parse(startURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+
+	var resumed *Checkpoint
+	if s.checkpoint.resume {
+		resumed, err = loadResumeCheckpoint(s.checkpoint.path, checkpointKindScrape, startURL)
+		if err != nil {
+			return err
+		}
+		s.restore(resumed)
+	}
+
+	if err := checkStartURL(s.policy, startURL); err != nil {
+		return err
+	}
+	err = startLogin(s.profile.Login, startURL, s.stopper.aborted, s.proxies, s.cookies, s.headers, s.transport, s.detailTransport)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Starting scrape from: %s", startURL)
+
+	checkpoints := startCheckpointer(s.checkpoint.path, s.checkpoint.interval, func() *Checkpoint {
+		return s.snapshot(startURL)
+	})
+	
+	// Pages are recorded by their canonical URL
+	visitURL := startURL
+	if canonicalURL, ok := s.profile.URLs.Canonicalize(startURL); ok {
+		visitURL = canonicalURL
+	}
+	if !s.isCompleted(visitURL) {
+		err = s.collector.Visit(visitURL)
+		if err != nil {
+			checkpoints.Stop(false)
+			return fmt.Errorf("failed to visit start URL: %w", err)
+		}
+	}
+
+	// Requeue the pages that were in flight when the checkpoint was taken
+	if resumed != nil {
+		for _, pageURL := range resumed.Pending {
+			s.collector.Visit(pageURL)
+		}
+		for _, productURL := range resumed.PendingDetails {
+			s.detailCollector.Visit(productURL)
+		}
+	}
+
+	// Wait for async collectors to finish, or for the drain after Stop
+	s.stopper.wait(func() {
+		if s.images != nil {
+			s.retries.wait(s.collector, s.detailCollector, s.images.collector)
+		} else {
+			s.retries.wait(s.collector, s.detailCollector)
+		}
+	})
+
+	if err := checkpoints.Stop(s.complete()); err != nil {
+		return err
+	}
+	return s.exportError()
+}
+
+// complete reports whether the scrape ran to the end without failed requests
+// or exports
+func (s *Scraper) complete() bool {
+	return !s.Stopped() && s.GetFailedRequests() == 0 && s.exportError() == nil
+}
+
+// exportError returns the first error from the exporter
+func (s *Scraper) exportError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.exportErr != nil {
+		return fmt.Errorf("failed to export products: %w", s.exportErr)
+	}
+	return nil
+}
+
+// Stop asks a running Scrape to finish early. No new pages are requested,
+// in-flight ones get DrainTimeout to complete, and Scrape then returns with
+// the products collected so far.
+func (s *Scraper) Stop() {
+	s.stopper.stop()
+}
+
+// Stopped reports whether Stop was called
+func (s *Scraper) Stopped() bool {
+	return s.stopper.stopped()
+}
+
+// restore loads the state of an interrupted scrape
+func (s *Scraper) restore(cp *Checkpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Completed and pending product pages are marked visited so listings
+	// don't queue them again
+	for _, pageURL := range cp.Completed {
+		s.completed[pageURL] = true
+		s.visited[pageURL] = true
+	}
+	for _, productURL := range cp.PendingDetails {
+		s.visited[productURL] = true
+	}
+	for _, product := range cp.Products {
+		s.collect(product)
+	}
+}
+
+// snapshot captures the scrape state for a checkpoint. Products are only
+// included once their detail page completed, so none is scraped twice.
+func (s *Scraper) snapshot(startURL string) *Checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := &Checkpoint{
+		Kind:           checkpointKindScrape,
+		StartURL:       startURL,
+		Completed:      sortedKeys(s.completed),
+		Pending:        make([]string, 0),
+		PendingDetails: make([]string, 0),
+		Products:       make([]extract.ProductDetail, 0, len(s.products)),
+	}
+	for _, pageURL := range sortedKeys(s.listings) {
+		if !s.completed[pageURL] {
+			cp.Pending = append(cp.Pending, pageURL)
+		}
+	}
+	for _, productURL := range sortedKeys(s.visited) {
+		if !s.completed[productURL] {
+			cp.PendingDetails = append(cp.PendingDetails, productURL)
+		}
+	}
+	for _, product := range s.products {
+		if s.completed[product.URL] {
+			cp.Products = append(cp.Products, product)
+		}
+	}
+	return cp
+}
+
+// isCompleted reports whether pageURL was scraped before the last checkpoint
+func (s *Scraper) isCompleted(pageURL string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.completed[pageURL]
+}
+
+// GetProducts returns the scraped products
+func (s *Scraper) GetProducts() []extract.ProductDetail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.products
+}
+
+// GetProductCount returns the number of products scraped, including those
+// handed to the exporter
+func (s *Scraper) GetProductCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.collected
+}
+
+// GetBlockedURLs returns the URLs skipped because of robots.txt
+func (s *Scraper) GetBlockedURLs() []BlockedURL {
+	if s.policy == nil {
+		return []BlockedURL{}
+	}
+	return s.policy.Blocked()
+}
+
+// GetFailedRequests returns the number of listing, detail and image requests
+// that failed
+func (s *Scraper) GetFailedRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.failed
+}
+
+// ExportToJSON exports scraped data to a JSON file
+func (s *Scraper) ExportToJSON(filename string) error {
+	return export.WriteJSON(s.copyProducts(), filename)
+}
+
+// ExportToCSV exports scraped data to a CSV file with a row per variant
+func (s *Scraper) ExportToCSV(filename string) error {
+	return export.WriteDetailCSV(s.copyProducts(), filename)
+}
+
+// copyProducts returns a copy of the products, so requests still running
+// after an abandoned drain can't race an export
+func (s *Scraper) copyProducts() []extract.ProductDetail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	products := make([]extract.ProductDetail, len(s.products))
+	copy(products, s.products)
+	return products
+}
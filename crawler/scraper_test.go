@@ -1,8 +1,12 @@
-package main
+package crawler
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,11 +16,15 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"web-scraper/export"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+	"web-scraper/validate"
 )
 
 func TestNewScraper(t *testing.T) {
 	t.Run("initializes with single domain", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 
 		assert.NotNil(t, scraper)
 		assert.NotNil(t, scraper.collector)
@@ -29,7 +37,7 @@ func TestNewScraper(t *testing.T) {
 
 	t.Run("initializes with multiple domains", func(t *testing.T) {
 		domains := []string{"example.com", "test.com", "scraper.com"}
-		scraper := NewScraper(domains)
+		scraper := NewScraper(WithAllowedDomains(domains...))
 
 		assert.NotNil(t, scraper)
 		assert.NotNil(t, scraper.collector)
@@ -37,7 +45,7 @@ func TestNewScraper(t *testing.T) {
 	})
 
 	t.Run("initializes empty slices and maps", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 
 		assert.Empty(t, scraper.products)
 		assert.Empty(t, scraper.visited)
@@ -48,21 +56,21 @@ func TestNewScraper(t *testing.T) {
 
 func TestSetProxy(t *testing.T) {
 	t.Run("empty proxy list", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 		err := scraper.SetProxy([]string{})
 
 		assert.NoError(t, err)
 	})
 
 	t.Run("single proxy", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 		err := scraper.SetProxy([]string{"http://proxy.example.com:8080"})
 
 		assert.NoError(t, err)
 	})
 
 	t.Run("multiple proxies", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 		proxies := []string{
 			"http://proxy1.example.com:8080",
 			"http://proxy2.example.com:8080",
@@ -74,7 +82,7 @@ func TestSetProxy(t *testing.T) {
 	})
 
 	t.Run("invalid proxy URL", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 		err := scraper.SetProxy([]string{"not-a-valid-url"})
 
 		// The proxy switcher may or may not fail on invalid URLs
@@ -88,8 +96,8 @@ func TestExportToJSON(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "test.json")
 
-		scraper := NewScraper([]string{"example.com"})
-		scraper.products = []ProductDetail{
+		scraper := NewScraper(WithAllowedDomains("example.com"))
+		scraper.products = []extract.ProductDetail{
 			{
 				Name:      "Test Product",
 				Price:     "$19.99",
@@ -107,8 +115,8 @@ func TestExportToJSON(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "test.json")
 
-		scraper := NewScraper([]string{"example.com"})
-		scraper.products = []ProductDetail{
+		scraper := NewScraper(WithAllowedDomains("example.com"))
+		scraper.products = []extract.ProductDetail{
 			{
 				Name:  "Test Product",
 				Price: "$19.99",
@@ -123,7 +131,7 @@ func TestExportToJSON(t *testing.T) {
 		data, err := os.ReadFile(filename)
 		require.NoError(t, err)
 
-		var products []ProductDetail
+		var products []extract.ProductDetail
 		err = json.Unmarshal(data, &products)
 		require.NoError(t, err)
 		assert.Len(t, products, 1)
@@ -134,7 +142,7 @@ func TestExportToJSON(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "test.json")
 
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 
 		err := scraper.ExportToJSON(filename)
 		require.NoError(t, err)
@@ -142,7 +150,7 @@ func TestExportToJSON(t *testing.T) {
 		data, err := os.ReadFile(filename)
 		require.NoError(t, err)
 
-		var products []ProductDetail
+		var products []extract.ProductDetail
 		err = json.Unmarshal(data, &products)
 		require.NoError(t, err)
 		assert.Len(t, products, 0)
@@ -152,8 +160,8 @@ func TestExportToJSON(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "test.json")
 
-		scraper := NewScraper([]string{"example.com"})
-		scraper.products = []ProductDetail{
+		scraper := NewScraper(WithAllowedDomains("example.com"))
+		scraper.products = []extract.ProductDetail{
 			{
 				Name:        "Product with \"quotes\" & special <chars>",
 				Description: "Description with\nnewlines\tand\ttabs",
@@ -167,14 +175,14 @@ func TestExportToJSON(t *testing.T) {
 		data, err := os.ReadFile(filename)
 		require.NoError(t, err)
 
-		var products []ProductDetail
+		var products []extract.ProductDetail
 		err = json.Unmarshal(data, &products)
 		require.NoError(t, err)
 		assert.Equal(t, "Product with \"quotes\" & special <chars>", products[0].Name)
 	})
 
 	t.Run("file write error", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 		filename := "/invalid/path/that/does/not/exist/test.json"
 
 		err := scraper.ExportToJSON(filename)
@@ -184,8 +192,8 @@ func TestExportToJSON(t *testing.T) {
 
 func TestGetProducts(t *testing.T) {
 	t.Run("returns products", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
-		scraper.products = []ProductDetail{
+		scraper := NewScraper(WithAllowedDomains("example.com"))
+		scraper.products = []extract.ProductDetail{
 			{Name: "Product 1", Price: "$10.00"},
 			{Name: "Product 2", Price: "$20.00"},
 		}
@@ -197,7 +205,7 @@ func TestGetProducts(t *testing.T) {
 	})
 
 	t.Run("thread-safe access", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 
 		// Add products concurrently
 		var wg sync.WaitGroup
@@ -206,7 +214,7 @@ func TestGetProducts(t *testing.T) {
 			go func(id int) {
 				defer wg.Done()
 				scraper.mu.Lock()
-				scraper.products = append(scraper.products, ProductDetail{
+				scraper.products = append(scraper.products, extract.ProductDetail{
 					Name:  fmt.Sprintf("Product %d", id),
 					Price: "$10.00",
 				})
@@ -220,54 +228,6 @@ func TestGetProducts(t *testing.T) {
 	})
 }
 
-func TestProductDetailStruct(t *testing.T) {
-	t.Run("creates ProductDetail with all fields", func(t *testing.T) {
-		now := time.Now()
-		product := ProductDetail{
-			URL:         "http://example.com/product",
-			Name:        "Test Product",
-			Price:       "$19.99",
-			Description: "Test description",
-			SKU:         "TEST-123",
-			Category:    "Test Category",
-			ImageURL:    "http://example.com/image.jpg",
-			InStock:     true,
-			ScrapedAt:   now,
-		}
-
-		assert.Equal(t, "http://example.com/product", product.URL)
-		assert.Equal(t, "Test Product", product.Name)
-		assert.Equal(t, "$19.99", product.Price)
-		assert.Equal(t, "Test description", product.Description)
-		assert.Equal(t, "TEST-123", product.SKU)
-		assert.Equal(t, "Test Category", product.Category)
-		assert.Equal(t, "http://example.com/image.jpg", product.ImageURL)
-		assert.True(t, product.InStock)
-		assert.Equal(t, now, product.ScrapedAt)
-	})
-
-	t.Run("JSON marshaling", func(t *testing.T) {
-		product := ProductDetail{
-			Name:     "Test Product",
-			Price:    "$19.99",
-			InStock:  true,
-			Category: "Electronics",
-		}
-
-		data, err := json.Marshal(product)
-		require.NoError(t, err)
-
-		var decoded ProductDetail
-		err = json.Unmarshal(data, &decoded)
-		require.NoError(t, err)
-
-		assert.Equal(t, product.Name, decoded.Name)
-		assert.Equal(t, product.Price, decoded.Price)
-		assert.Equal(t, product.InStock, decoded.InStock)
-		assert.Equal(t, product.Category, decoded.Category)
-	})
-}
-
 func TestScraperWithMockServer(t *testing.T) {
 	t.Run("scrapes product listing page", func(t *testing.T) {
 		// Skip this test as it requires complex Colly domain configuration
@@ -276,7 +236,7 @@ func TestScraperWithMockServer(t *testing.T) {
 	})
 
 	t.Run("handles visited URLs", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 
 		// Mark some URLs as visited
 		scraper.mu.Lock()
@@ -292,9 +252,50 @@ func TestScraperWithMockServer(t *testing.T) {
 	})
 }
 
+func TestScraperDetailLimits(t *testing.T) {
+	// Detail pages are slow so overlapping requests would be observed
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			page := `<html><body><ul>`
+			for i := 0; i < 4; i++ {
+				page += fmt.Sprintf(`<li class="product"><a class="woocommerce-LoopProduct-link" href="%s/product/%d">P</a></li>`, server.URL, i)
+			}
+			w.Write([]byte(page + `</ul></body></html>`))
+			return
+		}
+
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(30 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+		w.Write([]byte(`<html><body><div class="product"><h1 class="product_title">` + r.URL.Path + `</h1></div></body></html>`))
+	}))
+	defer server.Close()
+
+	cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+	cfg.IgnoreRobotsTxt = true
+	cfg.Parallelism = 4
+	cfg.DetailParallelism = 1
+	scraper := NewScraperWithConfig(cfg)
+	require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+	assert.Len(t, scraper.GetProducts(), 4)
+	assert.Equal(t, 1, maxInFlight, "detail parallelism is not shadowed by the listing limit")
+}
+
 func TestScraperConcurrency(t *testing.T) {
 	t.Run("no race conditions", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 
 		var wg sync.WaitGroup
 		numGoroutines := 20
@@ -307,7 +308,7 @@ func TestScraperConcurrency(t *testing.T) {
 
 				// Add product
 				scraper.mu.Lock()
-				scraper.products = append(scraper.products, ProductDetail{
+				scraper.products = append(scraper.products, extract.ProductDetail{
 					Name: fmt.Sprintf("Product %d", id),
 				})
 				scraper.mu.Unlock()
@@ -325,7 +326,7 @@ func TestScraperConcurrency(t *testing.T) {
 	})
 
 	t.Run("visited map thread safety", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 
 		var wg sync.WaitGroup
 		numGoroutines := 20
@@ -362,7 +363,7 @@ func TestScraperErrorHandling(t *testing.T) {
 	})
 
 	t.Run("handles invalid URL", func(t *testing.T) {
-		scraper := NewScraper([]string{"example.com"})
+		scraper := NewScraper(WithAllowedDomains("example.com"))
 
 		err := scraper.Scrape("not-a-valid-url://broken")
 		// Should get a URL parse error
@@ -376,8 +377,8 @@ func TestExportToJSONIntegration(t *testing.T) {
 		filename := filepath.Join(tmpDir, "products.json")
 
 		testTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
-		scraper := NewScraper([]string{"example.com"})
-		scraper.products = []ProductDetail{
+		scraper := NewScraper(WithAllowedDomains("example.com"))
+		scraper.products = []extract.ProductDetail{
 			{
 				Name:        "Product A",
 				Price:       "$10.00",
@@ -410,7 +411,7 @@ func TestExportToJSONIntegration(t *testing.T) {
 		data, err := os.ReadFile(filename)
 		require.NoError(t, err)
 
-		var products []ProductDetail
+		var products []extract.ProductDetail
 		err = json.Unmarshal(data, &products)
 		require.NoError(t, err)
 
@@ -427,8 +428,8 @@ func TestExportToJSONIntegration(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "products.json")
 
-		scraper := NewScraper([]string{"example.com"})
-		scraper.products = []ProductDetail{
+		scraper := NewScraper(WithAllowedDomains("example.com"))
+		scraper.products = []extract.ProductDetail{
 			{Name: "Test", Price: "$10.00"},
 		}
 
@@ -445,3 +446,157 @@ func TestExportToJSONIntegration(t *testing.T) {
 		assert.True(t, strings.Contains(content, "  ")) // Has indentation
 	})
 }
+
+func TestDetailInStock(t *testing.T) {
+	server := testutil.CreateMockServerWithRoutes(map[string]string{
+		"/product/in":  testutil.MustGetFixture(t, "product.html"),
+		"/product/out": testutil.MustGetFixture(t, "product_out_of_stock.html"),
+	})
+	defer server.Close()
+
+	listing := testutil.CreateMockServerWithRoutes(map[string]string{"/": `<html><body><ul>
+		<li class="product"><a class="woocommerce-LoopProduct-link" href="` + server.URL + `/product/in">In</a></li>
+		<li class="product"><a class="woocommerce-LoopProduct-link" href="` + server.URL + `/product/out">Out</a></li>
+	</ul></body></html>`})
+	defer listing.Close()
+
+	cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+	cfg.IgnoreRobotsTxt = true
+	scraper := NewScraperWithConfig(cfg)
+	require.NoError(t, scraper.Scrape(listing.URL+"/"))
+
+	inStock := make(map[string]bool)
+	for _, product := range scraper.GetProducts() {
+		inStock[product.Name] = product.InStock
+	}
+	assert.Equal(t, map[string]bool{"Detailed Test Product": true, "Sold Out Test Product": false}, inStock)
+}
+
+func TestDetailStructuredData(t *testing.T) {
+	server := testutil.CreateMockServerWithRoutes(map[string]string{
+		"/product/structured": testutil.MustGetFixture(t, "product_jsonld.html"),
+	})
+	defer server.Close()
+
+	listing := testutil.CreateMockServerWithRoutes(map[string]string{"/": `<html><body><ul>
+		<li class="product"><a class="woocommerce-LoopProduct-link" href="` + server.URL + `/product/structured">Structured</a></li>
+	</ul></body></html>`})
+	defer listing.Close()
+
+	cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+	cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+	cfg.IgnoreRobotsTxt = true
+	scraper := NewScraperWithConfig(cfg)
+	require.NoError(t, scraper.Scrape(listing.URL+"/"))
+
+	products := scraper.GetProducts()
+	require.Len(t, products, 1)
+	assert.Equal(t, "Structured Product", products[0].Name)
+	assert.Equal(t, extract.Price{Currency: "EUR", Amount: 129900}, products[0].Pricing)
+	assert.False(t, products[0].InStock)
+	assert.Equal(t, "Acme", products[0].Brand)
+	assert.Equal(t, "CSS-SKU-001", products[0].SKU)
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestScraperExporter(t *testing.T) {
+	scraperConfig := func() ScraperConfig {
+		cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+		cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+		cfg.IgnoreRobotsTxt = true
+		return cfg
+	}
+
+	t.Run("streams products instead of keeping them", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		var buf bytes.Buffer
+		cfg := scraperConfig()
+		cfg.Exporter = export.NewJSONLinesExporter(&buf)
+		scraper := NewScraperWithConfig(cfg)
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Empty(t, scraper.GetProducts())
+		assert.Equal(t, 3, scraper.GetProductCount())
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		require.Len(t, lines, 3)
+		var product extract.ProductDetail
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &product))
+		assert.Equal(t, "TEST-SKU-001", product.SKU)
+	})
+
+	t.Run("returns the first export error", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		cfg := scraperConfig()
+		cfg.Exporter = export.NewJSONLinesExporter(failingWriter{})
+		err := NewScraperWithConfig(cfg).Scrape(server.URL + "/")
+		assert.ErrorContains(t, err, "disk full")
+	})
+
+	t.Run("listing scraper streams products", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		var buf bytes.Buffer
+		cfg := listingConfig()
+		cfg.Exporter = export.NewJSONLinesExporter(&buf)
+		ls := NewListingScraperWithConfig(cfg)
+		require.NoError(t, ls.Scrape(server.URL+"/"))
+
+		assert.Empty(t, ls.GetProducts())
+		assert.Equal(t, 3, ls.GetProductCount())
+		assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+	})
+}
+
+func TestScraperValidator(t *testing.T) {
+	rules := &validate.Config{Rules: []validate.Rule{{Field: "sku", Required: true, Pattern: "-002$"}}}
+	require.NoError(t, rules.Validate())
+
+	t.Run("leaves out rejected products", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		var rejects bytes.Buffer
+		cfg := DefaultScraperConfig([]string{"127.0.0.1"})
+		cfg.Delay, cfg.RandomDelay, cfg.DetailDelay, cfg.CacheDir = 0, 0, 0, ""
+		cfg.IgnoreRobotsTxt = true
+		cfg.Validator = validate.New(rules, export.NewJSONLinesExporter(&rejects))
+		scraper := NewScraperWithConfig(cfg)
+		require.NoError(t, scraper.Scrape(server.URL+"/"))
+
+		assert.Empty(t, scraper.GetProducts())
+		assert.Equal(t, 0, scraper.GetProductCount())
+		assert.Equal(t, 3, strings.Count(rejects.String(), "\n"))
+		assert.Equal(t, 3, cfg.Validator.Report().Rejected)
+	})
+
+	t.Run("listing scraper keeps valid products", func(t *testing.T) {
+		server := testutil.CreateShopServer(t)
+		defer server.Close()
+
+		// Listing products have no SKU, so only the name rule applies
+		names := &validate.Config{Rules: []validate.Rule{rules.Rules[0], {Field: "name", Pattern: "1$"}}}
+		require.NoError(t, names.Validate())
+
+		cfg := listingConfig()
+		cfg.MaxDepth = 1
+		cfg.Validator = validate.New(names, nil)
+		ls := NewListingScraperWithConfig(cfg)
+		require.NoError(t, ls.Scrape(server.URL+"/"))
+
+		products := ls.GetProducts()
+		require.Len(t, products, 1)
+		assert.Equal(t, "Test Product 1", products[0].Name)
+		assert.Equal(t, 1, ls.GetProductCount())
+	})
+}
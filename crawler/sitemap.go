@@ -0,0 +1,215 @@
+package crawler
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	maxSitemapSize  = 50 << 20 // sitemaps.org limit for an uncompressed sitemap
+	maxSitemapDepth = 3        // how deep sitemap indexes may nest
+)
+
+// sitemapTimeFormats are the W3C datetime forms allowed in <lastmod>
+var sitemapTimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+}
+
+// SitemapURL is a page listed in a sitemap
+type SitemapURL struct {
+	Loc     string     `json:"loc"`
+	LastMod *time.Time `json:"lastmod,omitempty"` // nil when the sitemap gives no valid date
+}
+
+// sitemapDocument covers both <urlset> and <sitemapindex> documents
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+// sitemapEntry is a <url> or <sitemap> element
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// SitemapReader downloads sitemaps, following sitemap indexes and
+// decompressing gzipped files
+type SitemapReader struct {
+	client         *http.Client
+	maxURLs        int             // 0 means unlimited
+	allowedDomains map[string]bool // empty allows every host
+	stop           <-chan struct{} // closed to abandon reading, nil never is
+}
+
+// NewSitemapReader creates a reader that stops after maxURLs page URLs.
+// Sitemaps and pages on hosts outside allowedDomains are skipped unless
+// allowedDomains is empty.
+func NewSitemapReader(maxURLs int, allowedDomains []string) *SitemapReader {
+	r := &SitemapReader{
+		client:         &http.Client{Timeout: 30 * time.Second},
+		maxURLs:        maxURLs,
+		allowedDomains: make(map[string]bool),
+	}
+	for _, domain := range allowedDomains {
+		r.allowedDomains[domain] = true
+	}
+	return r
+}
+
+// allowed reports whether rawURL is on one of the allowed domains
+func (r *SitemapReader) allowed(rawURL string) bool {
+	if len(r.allowedDomains) == 0 {
+		return true
+	}
+	u, err := url.Parse(rawURL)
+	return err == nil && r.allowedDomains[u.Hostname()]
+}
+
+// stopped reports whether reading was abandoned
+func (r *SitemapReader) stopped() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// DiscoverSitemaps returns the sitemap locations for the site of base: those
+// advertised by robots.txt followed by the conventional /sitemap.xml
+func DiscoverSitemaps(base *url.URL, policy *RobotsPolicy) []string {
+	var locations []string
+	if policy != nil {
+		locations = append(locations, policy.Sitemaps(base)...)
+	}
+	return append(locations, base.Scheme+"://"+base.Host+"/sitemap.xml")
+}
+
+// Read fetches every sitemap in locations and returns the page URLs they
+// list, without duplicates. Sitemaps that fail to load are logged and skipped.
+// Reading ends early, with the URLs found so far, once the reader is stopped.
+func (r *SitemapReader) Read(locations []string) []SitemapURL {
+	seenSitemaps := make(map[string]bool)
+	seenURLs := make(map[string]bool)
+	var urls []SitemapURL
+
+	var read func(loc string, depth int)
+	read = func(loc string, depth int) {
+		if seenSitemaps[loc] || depth > maxSitemapDepth || r.full(urls) || r.stopped() {
+			return
+		}
+		seenSitemaps[loc] = true
+
+		if !r.allowed(loc) {
+			log.Printf("[SITEMAP] Skipping %s: host not allowed", loc)
+			return
+		}
+
+		doc, err := r.fetch(loc)
+		if err != nil {
+			log.Printf("[SITEMAP] Skipping %s: %v", loc, err)
+			return
+		}
+
+		for _, entry := range doc.URLs {
+			page := strings.TrimSpace(entry.Loc)
+			if page == "" || seenURLs[page] || r.full(urls) || !r.allowed(page) {
+				continue
+			}
+			seenURLs[page] = true
+			urls = append(urls, SitemapURL{Loc: page, LastMod: parseLastMod(entry.LastMod)})
+		}
+
+		for _, entry := range doc.Sitemaps {
+			if child := strings.TrimSpace(entry.Loc); child != "" {
+				read(child, depth+1)
+			}
+		}
+	}
+
+	for _, loc := range locations {
+		read(loc, 0)
+	}
+	return urls
+}
+
+// full reports whether the URL limit has been reached
+func (r *SitemapReader) full(urls []SitemapURL) bool {
+	return r.maxURLs > 0 && len(urls) >= r.maxURLs
+}
+
+// fetch downloads and decodes a single sitemap or sitemap index
+func (r *SitemapReader) fetch(loc string) (*sitemapDocument, error) {
+	// Cancel the download when the reader is stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-r.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, loc, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	// Gzipped sitemaps are detected by their magic number since servers
+	// rarely label them consistently
+	buffered := bufio.NewReader(io.LimitReader(resp.Body, maxSitemapSize))
+	var body io.Reader = buffered
+	if magic, _ := buffered.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		body = io.LimitReader(gz, maxSitemapSize)
+	}
+
+	doc := &sitemapDocument{}
+	if err := xml.NewDecoder(body).Decode(doc); err != nil {
+		return nil, fmt.Errorf("failed to parse sitemap: %w", err)
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, fmt.Errorf("unexpected root element <%s>", doc.XMLName.Local)
+	}
+
+	return doc, nil
+}
+
+// parseLastMod parses a <lastmod> value, returning nil if it is missing or
+// malformed
+func parseLastMod(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range sitemapTimeFormats {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
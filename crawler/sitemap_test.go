@@ -0,0 +1,256 @@
+package crawler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sitemapServer serves the given paths verbatim and 404s everything else
+func sitemapServer(t *testing.T, files map[string]func(base string) []byte) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		file, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(file(server.URL))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func static(body string) func(string) []byte {
+	return func(string) []byte { return []byte(body) }
+}
+
+func gzipped(t *testing.T, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(body))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestSitemapReader(t *testing.T) {
+	t.Run("reads urlset with lastmod dates", func(t *testing.T) {
+		server := sitemapServer(t, map[string]func(string) []byte{
+			"/sitemap.xml": static(`<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>http://example.com/a</loc><lastmod>2024-03-01</lastmod></url>
+  <url><loc> http://example.com/b </loc><lastmod>2024-03-02T10:30:00+02:00</lastmod></url>
+  <url><loc>http://example.com/c</loc><lastmod>2024-03-03T10:30+00:00</lastmod></url>
+  <url><loc>http://example.com/d</loc><lastmod>yesterday</lastmod></url>
+</urlset>`),
+		})
+
+		urls := NewSitemapReader(0, nil).Read([]string{server.URL + "/sitemap.xml"})
+		require.Len(t, urls, 4)
+
+		assert.Equal(t, "http://example.com/a", urls[0].Loc)
+		require.NotNil(t, urls[0].LastMod)
+		assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), *urls[0].LastMod)
+		assert.Equal(t, "http://example.com/b", urls[1].Loc)
+		assert.True(t, urls[1].LastMod.Equal(time.Date(2024, 3, 2, 8, 30, 0, 0, time.UTC)))
+		assert.True(t, urls[2].LastMod.Equal(time.Date(2024, 3, 3, 10, 30, 0, 0, time.UTC)))
+		assert.Nil(t, urls[3].LastMod)
+	})
+
+	t.Run("follows sitemap indexes and gzipped sitemaps", func(t *testing.T) {
+		products := gzipped(t, `<urlset><url><loc>http://example.com/product/1</loc></url></urlset>`)
+		server := sitemapServer(t, map[string]func(string) []byte{
+			"/sitemap_index.xml": func(base string) []byte {
+				return []byte(`<sitemapindex>
+  <sitemap><loc>` + base + `/pages.xml</loc></sitemap>
+  <sitemap><loc>` + base + `/products.xml.gz</loc></sitemap>
+</sitemapindex>`)
+			},
+			"/pages.xml":       static(`<urlset><url><loc>http://example.com/about</loc></url></urlset>`),
+			"/products.xml.gz": func(string) []byte { return products },
+		})
+
+		urls := NewSitemapReader(0, nil).Read([]string{server.URL + "/sitemap_index.xml"})
+		require.Len(t, urls, 2)
+		assert.Equal(t, "http://example.com/about", urls[0].Loc)
+		assert.Equal(t, "http://example.com/product/1", urls[1].Loc)
+	})
+
+	t.Run("skips duplicates and index loops", func(t *testing.T) {
+		server := sitemapServer(t, map[string]func(string) []byte{
+			"/index.xml": func(base string) []byte {
+				return []byte(`<sitemapindex>
+  <sitemap><loc>` + base + `/index.xml</loc></sitemap>
+  <sitemap><loc>` + base + `/pages.xml</loc></sitemap>
+  <sitemap><loc>` + base + `/pages.xml</loc></sitemap>
+</sitemapindex>`)
+			},
+			"/pages.xml": static(`<urlset>
+  <url><loc>http://example.com/a</loc></url>
+  <url><loc>http://example.com/a</loc></url>
+</urlset>`),
+		})
+
+		urls := NewSitemapReader(0, nil).Read([]string{server.URL + "/index.xml", server.URL + "/pages.xml"})
+		require.Len(t, urls, 1)
+		assert.Equal(t, "http://example.com/a", urls[0].Loc)
+	})
+
+	t.Run("stops at the URL limit", func(t *testing.T) {
+		server := sitemapServer(t, map[string]func(string) []byte{
+			"/sitemap.xml": static(`<urlset>
+  <url><loc>http://example.com/a</loc></url>
+  <url><loc>http://example.com/b</loc></url>
+  <url><loc>http://example.com/c</loc></url>
+</urlset>`),
+		})
+
+		urls := NewSitemapReader(2, nil).Read([]string{server.URL + "/sitemap.xml"})
+		assert.Len(t, urls, 2)
+	})
+
+	t.Run("skips missing and non-sitemap documents", func(t *testing.T) {
+		server := sitemapServer(t, map[string]func(string) []byte{
+			"/page.html": static(`<html><body>Not a sitemap</body></html>`),
+			"/broken":    static(`not xml at all`),
+		})
+
+		urls := NewSitemapReader(0, nil).Read([]string{
+			server.URL + "/page.html",
+			server.URL + "/broken",
+			server.URL + "/missing.xml",
+		})
+		assert.Empty(t, urls)
+	})
+
+	t.Run("skips sitemaps and pages on other hosts", func(t *testing.T) {
+		server := sitemapServer(t, map[string]func(string) []byte{
+			"/sitemap.xml": func(base string) []byte {
+				return []byte(`<urlset>
+  <url><loc>` + base + `/a</loc></url>
+  <url><loc>http://example.com/b</loc></url>
+</urlset>`)
+			},
+		})
+
+		urls := NewSitemapReader(0, []string{"127.0.0.1"}).Read([]string{
+			"http://example.com/sitemap.xml",
+			server.URL + "/sitemap.xml",
+		})
+		require.Len(t, urls, 1)
+		assert.Equal(t, server.URL+"/a", urls[0].Loc)
+	})
+
+	t.Run("abandons reading once stopped", func(t *testing.T) {
+		release := make(chan struct{})
+		server := sitemapServer(t, map[string]func(string) []byte{
+			"/slow.xml": func(string) []byte {
+				<-release
+				return nil
+			},
+			"/pages.xml": static(`<urlset><url><loc>http://example.com/a</loc></url></urlset>`),
+		})
+		defer close(release)
+
+		stop := make(chan struct{})
+		reader := NewSitemapReader(0, nil)
+		reader.stop = stop
+		time.AfterFunc(50*time.Millisecond, func() { close(stop) })
+
+		start := time.Now()
+		urls := reader.Read([]string{server.URL + "/slow.xml", server.URL + "/pages.xml"})
+		assert.Empty(t, urls)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+
+	t.Run("omits missing lastmod from JSON", func(t *testing.T) {
+		data, err := json.Marshal(SitemapURL{Loc: "http://example.com/a"})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"loc": "http://example.com/a"}`, string(data))
+	})
+}
+
+func TestDiscoverSitemaps(t *testing.T) {
+	t.Run("lists robots.txt sitemaps before the default location", func(t *testing.T) {
+		server := sitemapServer(t, map[string]func(string) []byte{
+			"/robots.txt": static("User-agent: *\nDisallow:\nSitemap: http://example.com/products.xml\n"),
+		})
+
+		base := mustParseURL(t, server.URL+"/shop")
+		locations := DiscoverSitemaps(base, NewRobotsPolicy(robotsUserAgent))
+		assert.Equal(t, []string{"http://example.com/products.xml", server.URL + "/sitemap.xml"}, locations)
+	})
+
+	t.Run("falls back to sitemap.xml without a policy", func(t *testing.T) {
+		base := mustParseURL(t, "http://example.com/shop?page=2")
+		assert.Equal(t, []string{"http://example.com/sitemap.xml"}, DiscoverSitemaps(base, nil))
+	})
+}
+
+func TestCrawlerSitemaps(t *testing.T) {
+	newSite := func(t *testing.T) (*httptest.Server, map[string]int) {
+		hits := make(map[string]int)
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[r.URL.Path]++
+			switch r.URL.Path {
+			case "/robots.txt":
+				w.Write([]byte("User-agent: *\nDisallow:\nSitemap: " + server.URL + "/custom-sitemap.xml\n"))
+			case "/custom-sitemap.xml":
+				w.Write([]byte(`<urlset>
+  <url><loc>` + server.URL + `/</loc></url>
+  <url><loc>` + server.URL + `/orphan</loc><lastmod>2024-05-01</lastmod></url>
+</urlset>`))
+			case "/":
+				w.Write([]byte(`<html><body><a href="/linked">Linked</a></body></html>`))
+			case "/sitemap.xml":
+				http.NotFound(w, r)
+			default:
+				w.Write([]byte("<html><body>Page</body></html>"))
+			}
+		}))
+		t.Cleanup(server.Close)
+		return server, hits
+	}
+
+	t.Run("seeds unlinked pages from sitemaps", func(t *testing.T) {
+		server, hits := newSite(t)
+
+		crawler := NewWebCrawler(WithAllowedDomains("127.0.0.1"), WithMaxPages(10))
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		assert.Equal(t, 1, hits["/"])
+		assert.Equal(t, 1, hits["/linked"])
+		assert.Equal(t, 1, hits["/orphan"])
+		assert.Equal(t, 3, crawler.GetPagesVisited())
+
+		sitemapURLs := crawler.GetSitemapURLs()
+		require.Len(t, sitemapURLs, 2)
+		assert.Equal(t, server.URL+"/orphan", sitemapURLs[1].Loc)
+		require.NotNil(t, sitemapURLs[1].LastMod)
+		assert.Equal(t, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), *sitemapURLs[1].LastMod)
+	})
+
+	t.Run("can ignore sitemaps", func(t *testing.T) {
+		server, hits := newSite(t)
+
+		cfg := DefaultCrawlerConfig([]string{"127.0.0.1"}, 10)
+		cfg.IgnoreSitemaps = true
+		crawler := NewWebCrawlerWithConfig(cfg)
+		require.NoError(t, crawler.Crawl(server.URL+"/"))
+
+		assert.Equal(t, 0, hits["/custom-sitemap.xml"])
+		assert.Equal(t, 0, hits["/orphan"])
+		assert.Empty(t, crawler.GetSitemapURLs())
+	})
+}
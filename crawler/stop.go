@@ -0,0 +1,120 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// DefaultDrainTimeout is how long a stopped run waits for in-flight requests
+const DefaultDrainTimeout = 10 * time.Second
+
+// stopper coordinates a graceful shutdown: once stopped, new requests are
+// aborted and the run waits a bounded time for the in-flight ones
+type stopper struct {
+	once         sync.Once
+	ch           chan struct{}
+	abortOnce    sync.Once
+	aborted      chan struct{} // closed when in-flight requests are cancelled too
+	drainTimeout time.Duration
+}
+
+// newStopper creates a stopper that drains for at most drainTimeout
+func newStopper(drainTimeout time.Duration) *stopper {
+	if drainTimeout <= 0 {
+		drainTimeout = DefaultDrainTimeout
+	}
+	return &stopper{ch: make(chan struct{}), aborted: make(chan struct{}), drainTimeout: drainTimeout}
+}
+
+// stop starts the shutdown. It is safe to call more than once.
+func (s *stopper) stop() {
+	s.once.Do(func() { close(s.ch) })
+}
+
+// abort stops the run and cancels the requests still in flight
+func (s *stopper) abort() {
+	s.abortOnce.Do(func() { close(s.aborted) })
+	s.stop()
+}
+
+// wasAborted reports whether abort has been called
+func (s *stopper) wasAborted() bool {
+	select {
+	case <-s.aborted:
+		return true
+	default:
+		return false
+	}
+}
+
+// watch aborts the run once ctx is done. The returned function stops watching.
+func (s *stopper) watch(ctx context.Context) func() {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.abort()
+		case <-finished:
+		}
+	}()
+	return func() { close(finished) }
+}
+
+// done returns a channel that is closed once the run is stopped
+func (s *stopper) done() <-chan struct{} {
+	return s.ch
+}
+
+// stopped reports whether stop has been called
+func (s *stopper) stopped() bool {
+	select {
+	case <-s.ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// allow aborts r from an OnRequest callback once the run is stopped
+func (s *stopper) allow(r *colly.Request) bool {
+	if s.stopped() {
+		r.Abort()
+		return false
+	}
+	return true
+}
+
+// wait runs fn, which should block until the run is finished, and returns
+// when it does. After stop it waits at most drainTimeout more and reports
+// false if fn was still running.
+func (s *stopper) wait(fn func()) bool {
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		fn()
+	}()
+
+	select {
+	case <-finished:
+		return true
+	case <-s.ch:
+	}
+
+	timer := time.NewTimer(s.drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-finished:
+		return true
+	case <-timer.C:
+		log.Printf("[SHUTDOWN] Gave up on in-flight requests after %s", s.drainTimeout)
+		return false
+	}
+}
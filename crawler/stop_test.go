@@ -0,0 +1,48 @@
+package crawler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStopperWait(t *testing.T) {
+	t.Run("returns when the run finishes", func(t *testing.T) {
+		s := newStopper(time.Second)
+		assert.True(t, s.wait(func() {}))
+		assert.False(t, s.stopped())
+	})
+
+	t.Run("drains in-flight work after stop", func(t *testing.T) {
+		s := newStopper(time.Second)
+		finish := make(chan struct{})
+		go func() {
+			s.stop()
+			time.Sleep(20 * time.Millisecond)
+			close(finish)
+		}()
+
+		assert.True(t, s.wait(func() { <-finish }))
+		assert.True(t, s.stopped())
+	})
+
+	t.Run("gives up after the drain timeout", func(t *testing.T) {
+		s := newStopper(50 * time.Millisecond)
+		block := make(chan struct{})
+		defer close(block)
+		s.stop()
+
+		start := time.Now()
+		assert.False(t, s.wait(func() { <-block }))
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	t.Run("stop is idempotent", func(t *testing.T) {
+		s := newStopper(0)
+		s.stop()
+		s.stop()
+		assert.Equal(t, DefaultDrainTimeout, s.drainTimeout)
+		assert.True(t, s.stopped())
+	})
+}
@@ -0,0 +1,140 @@
+// Package export writes scraped products, crawled pages and links to files
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"web-scraper/extract"
+)
+
+// WriteCSV writes the scraped products to a CSV file
+func WriteCSV(products []extract.Product, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	// Write header row
+	header := []string{"Name", "Price", "URL", "Image", "Scraped At", "Currency", "Amount", "Original Amount", "Max Amount"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	// Write product data
+	for _, product := range products {
+		row := []string{
+			product.Name,
+			product.Price,
+			product.URL,
+			product.Image,
+			product.ScrapedAt.Format(time.RFC3339),
+		}
+		row = append(row, priceColumns(product.Pricing)...)
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write row: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// WriteDetailCSV writes product details to a CSV file, one row per variant.
+// Each option, such as size or color, gets a column; products without
+// variants get one row with the variant columns empty.
+func WriteDetailCSV(products []extract.ProductDetail, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	options := optionNames(products)
+	header := []string{"URL", "Name", "SKU", "Category", "Variant ID", "Variant SKU"}
+	header = append(header, options...)
+	header = append(header, "Price", "In Stock", "Image", "Scraped At", "Currency", "Amount", "Original Amount", "Max Amount")
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for _, product := range products {
+		variants := product.Variants
+		if len(variants) == 0 {
+			// The product itself, with no variant columns
+			variants = []extract.Variant{{
+				Price:    product.Price,
+				Pricing:  product.Pricing,
+				InStock:  product.InStock,
+				ImageURL: product.ImageURL,
+			}}
+		}
+		for _, variant := range variants {
+			image := variant.ImageURL
+			if image == "" {
+				image = product.ImageURL
+			}
+			row := []string{product.URL, product.Name, product.SKU, product.Category, variant.ID, variant.SKU}
+			for _, option := range options {
+				row = append(row, variant.Attributes[option])
+			}
+			row = append(row,
+				variant.Price,
+				strconv.FormatBool(variant.InStock),
+				image,
+				product.ScrapedAt.Format(time.RFC3339),
+			)
+			row = append(row, priceColumns(variant.Pricing)...)
+			if err := writer.Write(row); err != nil {
+				return fmt.Errorf("failed to write row: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// optionNames returns the sorted option names of all the variants
+func optionNames(products []extract.ProductDetail) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, product := range products {
+		for _, variant := range product.Variants {
+			for name := range variant.Attributes {
+				if !seen[name] {
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// priceColumns renders a parsed price as CSV cells with decimal amounts.
+// Fields that don't apply to the price are left empty.
+func priceColumns(p extract.Price) []string {
+	if p.IsZero() {
+		return []string{"", "", "", ""}
+	}
+
+	columns := []string{p.Currency, extract.FormatAmount(p.Amount, p.Currency), "", ""}
+	if p.OnSale() {
+		columns[2] = extract.FormatAmount(p.OriginalAmount, p.Currency)
+	}
+	if p.IsRange() {
+		columns[3] = extract.FormatAmount(p.MaxAmount, p.Currency)
+	}
+	return columns
+}
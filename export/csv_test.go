@@ -1,4 +1,4 @@
-package main
+package export
 
 import (
 	"os"
@@ -8,70 +8,16 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
 )
 
-func TestCleanPrice(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "removes leading and trailing whitespace",
-			input:    "  $19.99  ",
-			expected: "$19.99",
-		},
-		{
-			name:     "handles newlines",
-			input:    "$19.99\n$29.99",
-			expected: "$19.99 $29.99",
-		},
-		{
-			name:     "handles tabs",
-			input:    "$19.99\t\tUSD",
-			expected: "$19.99USD",
-		},
-		{
-			name:     "collapses multiple spaces",
-			input:    "$19.99    USD",
-			expected: "$19.99 USD",
-		},
-		{
-			name:     "handles empty string",
-			input:    "",
-			expected: "",
-		},
-		{
-			name:     "handles whitespace only",
-			input:    "   \n\t  ",
-			expected: "",
-		},
-		{
-			name:     "handles complex formatting",
-			input:    "\n\t  $19.99  -  $29.99  \n",
-			expected: "$19.99 - $29.99",
-		},
-		{
-			name:     "preserves single space",
-			input:    "$19.99 USD",
-			expected: "$19.99 USD",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := cleanPrice(tt.input)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
 func TestExportToCSV(t *testing.T) {
 	t.Run("creates valid CSV file", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "test.csv")
 
-		products := []Product{
+		products := []extract.Product{
 			{
 				Name:      "Test Product 1",
 				Price:     "$19.99",
@@ -81,7 +27,7 @@ func TestExportToCSV(t *testing.T) {
 			},
 		}
 
-		err := exportToCSV(products, filename)
+		err := WriteCSV(products, filename)
 		require.NoError(t, err)
 		assert.FileExists(t, filename)
 	})
@@ -90,15 +36,15 @@ func TestExportToCSV(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "test.csv")
 
-		products := []Product{}
-		err := exportToCSV(products, filename)
+		products := []extract.Product{}
+		err := WriteCSV(products, filename)
 		require.NoError(t, err)
 
-		rows, err := ReadCSVFile(filename)
+		rows, err := testutil.ReadCSVFile(filename)
 		require.NoError(t, err)
 		require.Len(t, rows, 1) // Only header
 
-		expectedHeaders := []string{"Name", "Price", "URL", "Image", "Scraped At"}
+		expectedHeaders := []string{"Name", "Price", "URL", "Image", "Scraped At", "Currency", "Amount", "Original Amount", "Max Amount"}
 		assert.Equal(t, expectedHeaders, rows[0])
 	})
 
@@ -107,7 +53,7 @@ func TestExportToCSV(t *testing.T) {
 		filename := filepath.Join(tmpDir, "test.csv")
 
 		testTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
-		products := []Product{
+		products := []extract.Product{
 			{
 				Name:      "Test Product 1",
 				Price:     "$19.99",
@@ -124,10 +70,10 @@ func TestExportToCSV(t *testing.T) {
 			},
 		}
 
-		err := exportToCSV(products, filename)
+		err := WriteCSV(products, filename)
 		require.NoError(t, err)
 
-		rows, err := ReadCSVFile(filename)
+		rows, err := testutil.ReadCSVFile(filename)
 		require.NoError(t, err)
 		require.Len(t, rows, 3) // Header + 2 products
 
@@ -143,20 +89,44 @@ func TestExportToCSV(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "test.csv")
 
-		products := []Product{}
-		err := exportToCSV(products, filename)
+		products := []extract.Product{}
+		err := WriteCSV(products, filename)
 		require.NoError(t, err)
 
-		rows, err := ReadCSVFile(filename)
+		rows, err := testutil.ReadCSVFile(filename)
 		require.NoError(t, err)
 		assert.Len(t, rows, 1) // Only header
 	})
 
+	t.Run("writes structured price columns", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		filename := filepath.Join(tmpDir, "test.csv")
+
+		products := []extract.Product{
+			{Name: "Plain", Price: "$19.99", Pricing: extract.Price{Currency: "USD", Amount: 1999}},
+			{Name: "Sale", Price: "$30.00 $20.00", Pricing: extract.Price{Currency: "USD", Amount: 2000, OriginalAmount: 3000}},
+			{Name: "Range", Price: "10 € – 20 €", Pricing: extract.Price{Currency: "EUR", Amount: 1000, MaxAmount: 2000}},
+			{Name: "Unparsed", Price: "Call us"},
+		}
+
+		err := WriteCSV(products, filename)
+		require.NoError(t, err)
+
+		rows, err := testutil.ReadCSVFile(filename)
+		require.NoError(t, err)
+		require.Len(t, rows, 5)
+
+		assert.Equal(t, []string{"USD", "19.99", "", ""}, rows[1][5:])
+		assert.Equal(t, []string{"USD", "20.00", "30.00", ""}, rows[2][5:])
+		assert.Equal(t, []string{"EUR", "10.00", "", "20.00"}, rows[3][5:])
+		assert.Equal(t, []string{"", "", "", ""}, rows[4][5:])
+	})
+
 	t.Run("handles special characters in fields", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "test.csv")
 
-		products := []Product{
+		products := []extract.Product{
 			{
 				Name:      "Product with \"quotes\" and, commas",
 				Price:     "$19.99",
@@ -166,10 +136,10 @@ func TestExportToCSV(t *testing.T) {
 			},
 		}
 
-		err := exportToCSV(products, filename)
+		err := WriteCSV(products, filename)
 		require.NoError(t, err)
 
-		rows, err := ReadCSVFile(filename)
+		rows, err := testutil.ReadCSVFile(filename)
 		require.NoError(t, err)
 		assert.Equal(t, "Product with \"quotes\" and, commas", rows[1][0])
 	})
@@ -178,51 +148,22 @@ func TestExportToCSV(t *testing.T) {
 		// Try to write to an invalid path
 		filename := "/invalid/path/that/does/not/exist/test.csv"
 
-		products := []Product{
+		products := []extract.Product{
 			{Name: "Test", Price: "$19.99"},
 		}
 
-		err := exportToCSV(products, filename)
+		err := WriteCSV(products, filename)
 		assert.Error(t, err)
 	})
 }
 
-func TestProductStruct(t *testing.T) {
-	t.Run("creates product with all fields", func(t *testing.T) {
-		now := time.Now()
-		product := Product{
-			URL:       "http://example.com/product",
-			Image:     "http://example.com/image.jpg",
-			Name:      "Test Product",
-			Price:     "$19.99",
-			ScrapedAt: now,
-		}
-
-		assert.Equal(t, "http://example.com/product", product.URL)
-		assert.Equal(t, "http://example.com/image.jpg", product.Image)
-		assert.Equal(t, "Test Product", product.Name)
-		assert.Equal(t, "$19.99", product.Price)
-		assert.Equal(t, now, product.ScrapedAt)
-	})
-
-	t.Run("handles empty fields", func(t *testing.T) {
-		product := Product{}
-
-		assert.Equal(t, "", product.URL)
-		assert.Equal(t, "", product.Image)
-		assert.Equal(t, "", product.Name)
-		assert.Equal(t, "", product.Price)
-		assert.True(t, product.ScrapedAt.IsZero())
-	})
-}
-
 func TestExportToCSVIntegration(t *testing.T) {
 	t.Run("creates file that can be read back", func(t *testing.T) {
 		tmpDir := t.TempDir()
 		filename := filepath.Join(tmpDir, "products.csv")
 
 		testTime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
-		originalProducts := []Product{
+		originalProducts := []extract.Product{
 			{
 				Name:      "Product A",
 				Price:     "$10.00",
@@ -240,16 +181,16 @@ func TestExportToCSVIntegration(t *testing.T) {
 		}
 
 		// Export to CSV
-		err := exportToCSV(originalProducts, filename)
+		err := WriteCSV(originalProducts, filename)
 		require.NoError(t, err)
 
 		// Read back and verify
-		rows, err := ReadCSVFile(filename)
+		rows, err := testutil.ReadCSVFile(filename)
 		require.NoError(t, err)
 		require.Len(t, rows, 3) // Header + 2 products
 
 		// Verify header
-		assert.Equal(t, []string{"Name", "Price", "URL", "Image", "Scraped At"}, rows[0])
+		assert.Equal(t, []string{"Name", "Price", "URL", "Image", "Scraped At", "Currency", "Amount", "Original Amount", "Max Amount"}, rows[0])
 
 		// Verify data
 		assert.Equal(t, "Product A", rows[1][0])
@@ -259,52 +200,16 @@ func TestExportToCSVIntegration(t *testing.T) {
 	})
 }
 
-func TestCleanPriceEdgeCases(t *testing.T) {
-	tests := []struct {
-		name     string
-		input    string
-		expected string
-	}{
-		{
-			name:     "unicode characters",
-			input:    "€19.99",
-			expected: "€19.99",
-		},
-		{
-			name:     "multiple newlines",
-			input:    "\n\n\n$19.99\n\n",
-			expected: "$19.99",
-		},
-		{
-			name:     "mixed whitespace types",
-			input:    " \t\n$19.99 \n\t ",
-			expected: "$19.99",
-		},
-		{
-			name:     "price range",
-			input:    "$19.99  -  $29.99",
-			expected: "$19.99 - $29.99",
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := cleanPrice(tt.input)
-			assert.Equal(t, tt.expected, result)
-		})
-	}
-}
-
 // Test that CSV file is properly closed
 func TestExportToCSVFileHandling(t *testing.T) {
 	tmpDir := t.TempDir()
 	filename := filepath.Join(tmpDir, "test.csv")
 
-	products := []Product{
+	products := []extract.Product{
 		{Name: "Test", Price: "$19.99", ScrapedAt: time.Now()},
 	}
 
-	err := exportToCSV(products, filename)
+	err := WriteCSV(products, filename)
 	require.NoError(t, err)
 
 	// If file wasn't closed properly, we wouldn't be able to open it again
@@ -316,3 +221,52 @@ func TestExportToCSVFileHandling(t *testing.T) {
 	err = os.Remove(filename)
 	assert.NoError(t, err)
 }
+
+func TestWriteDetailCSV(t *testing.T) {
+	scrapedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	products := []extract.ProductDetail{
+		{
+			URL: "http://example.com/hoodie", Name: "Hoodie", SKU: "HOODIE", Category: "Clothing",
+			ImageURL: "http://example.com/hoodie.jpg", ScrapedAt: scrapedAt,
+			Variants: []extract.Variant{
+				{
+					ID: "43", SKU: "HOODIE-S", Attributes: map[string]string{"Size": "Small", "Color": "Blue"},
+					Price: "$40.00", Pricing: extract.Price{Currency: "USD", Amount: 4000}, InStock: true,
+					ImageURL: "http://example.com/hoodie-blue.jpg",
+				},
+				{
+					ID: "44", SKU: "HOODIE-L", Attributes: map[string]string{"Size": "Large"},
+					Price: "$36.50", Pricing: extract.Price{Currency: "USD", Amount: 3650, OriginalAmount: 4500},
+				},
+			},
+		},
+		{
+			URL: "http://example.com/mug", Name: "Mug", SKU: "MUG", Price: "$9.99",
+			Pricing: extract.Price{Currency: "USD", Amount: 999}, InStock: true, ScrapedAt: scrapedAt,
+		},
+	}
+
+	filename := filepath.Join(t.TempDir(), "details.csv")
+	require.NoError(t, WriteDetailCSV(products, filename))
+
+	rows, err := testutil.ReadCSVFile(filename)
+	require.NoError(t, err)
+	require.Len(t, rows, 4) // Header, 2 variants and a product without variants
+
+	assert.Equal(t, []string{
+		"URL", "Name", "SKU", "Category", "Variant ID", "Variant SKU", "Color", "Size",
+		"Price", "In Stock", "Image", "Scraped At", "Currency", "Amount", "Original Amount", "Max Amount",
+	}, rows[0])
+	assert.Equal(t, []string{
+		"http://example.com/hoodie", "Hoodie", "HOODIE", "Clothing", "43", "HOODIE-S", "Blue", "Small",
+		"$40.00", "true", "http://example.com/hoodie-blue.jpg", "2024-01-01T12:00:00Z", "USD", "40.00", "", "",
+	}, rows[1])
+	assert.Equal(t, []string{
+		"http://example.com/hoodie", "Hoodie", "HOODIE", "Clothing", "44", "HOODIE-L", "", "Large",
+		"$36.50", "false", "http://example.com/hoodie.jpg", "2024-01-01T12:00:00Z", "USD", "36.50", "45.00", "",
+	}, rows[2])
+	assert.Equal(t, []string{
+		"http://example.com/mug", "Mug", "MUG", "", "", "", "", "",
+		"$9.99", "true", "", "2024-01-01T12:00:00Z", "USD", "9.99", "", "",
+	}, rows[3])
+}
@@ -0,0 +1,38 @@
+package export
+
+import "errors"
+
+// Exporter receives records, e.g. extract.Product or extract.ProductDetail
+// values, one at a time as they are scraped. The scrapers never call Export
+// concurrently, and never call Close: whoever creates an Exporter closes it
+// once the run has returned.
+type Exporter interface {
+	Export(record any) error
+	Close() error
+}
+
+// multiExporter hands every record to several exporters
+type multiExporter []Exporter
+
+// Multi returns an Exporter that exports each record to all of exporters in
+// turn, stopping at the first error. Close closes them all.
+func Multi(exporters ...Exporter) Exporter {
+	return multiExporter(exporters)
+}
+
+func (m multiExporter) Export(record any) error {
+	for _, e := range m {
+		if err := e.Export(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m multiExporter) Close() error {
+	var errs []error
+	for _, e := range m {
+		errs = append(errs, e.Close())
+	}
+	return errors.Join(errs...)
+}
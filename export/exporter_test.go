@@ -0,0 +1,42 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+)
+
+// closeFailer is an exporter whose Close fails
+type closeFailer struct{}
+
+func (closeFailer) Export(any) error { return nil }
+func (closeFailer) Close() error     { return errors.New("close failed") }
+
+func TestMulti(t *testing.T) {
+	t.Run("exports to every exporter", func(t *testing.T) {
+		var first, second bytes.Buffer
+		m := Multi(NewJSONLinesExporter(&first), NewJSONLinesExporter(&second))
+
+		require.NoError(t, m.Export(extract.Product{Name: "A"}))
+		require.NoError(t, m.Close())
+		assert.Contains(t, first.String(), `"name":"A"`)
+		assert.Equal(t, first.String(), second.String())
+	})
+
+	t.Run("stops at the first failing exporter", func(t *testing.T) {
+		var buf bytes.Buffer
+		m := Multi(NewJSONLinesExporter(failingWriter{}), NewJSONLinesExporter(&buf))
+
+		assert.ErrorContains(t, m.Export(extract.Product{Name: "A"}), "disk full")
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("closes every exporter", func(t *testing.T) {
+		m := Multi(closeFailer{}, NewJSONLinesExporter(&bytes.Buffer{}))
+		assert.ErrorContains(t, m.Close(), "close failed")
+	})
+}
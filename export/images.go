@@ -0,0 +1,146 @@
+package export
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // registered for image.DecodeConfig
+	_ "image/jpeg"
+	_ "image/png"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"web-scraper/extract"
+)
+
+// imageExtensions names the files of common image types. Others get the
+// first extension mime knows for them.
+var imageExtensions = map[string]string{
+	"image/jpeg":    ".jpg",
+	"image/png":     ".png",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/avif":    ".avif",
+	"image/svg+xml": ".svg",
+	"image/bmp":     ".bmp",
+	"image/x-icon":  ".ico",
+}
+
+// ImageStore saves images under a directory by the SHA-256 of their
+// content, so an image shared by several products, or seen again in a later
+// run, is stored once
+type ImageStore struct {
+	dir string
+}
+
+// NewImageStore creates dir if needed and returns a store writing to it
+func NewImageStore(dir string) (*ImageStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+	return &ImageStore{dir: dir}, nil
+}
+
+// Save stores data, downloaded from sourceURL with the given Content-Type
+// header, as <dir>/<first two hex digits>/<sha256><ext>. Content that is not
+// an image, such as an HTML error page, is rejected.
+func (s *ImageStore) Save(sourceURL string, data []byte, contentType string) (extract.Image, error) {
+	img := extract.Image{URL: sourceURL, Size: len(data)}
+
+	img.MIME = imageType(data, contentType)
+	if img.MIME == "" {
+		return extract.Image{URL: sourceURL}, fmt.Errorf("%s is not an image", sourceURL)
+	}
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		img.Width, img.Height = config.Width, config.Height
+	} else if width, height, ok := webpSize(data); ok {
+		img.Width, img.Height = width, height
+	}
+
+	sum := sha256.Sum256(data)
+	img.SHA256 = hex.EncodeToString(sum[:])
+	img.Path = filepath.Join(s.dir, img.SHA256[:2], img.SHA256+imageExtension(img.MIME))
+
+	if _, err := os.Stat(img.Path); err == nil {
+		return img, nil
+	}
+	if err := writeFileAtomic(img.Path, data); err != nil {
+		return extract.Image{URL: sourceURL}, fmt.Errorf("failed to store %s: %w", sourceURL, err)
+	}
+	return img, nil
+}
+
+// imageType returns the MIME type of an image, sniffed from data or else
+// taken from the Content-Type header, or "" when it isn't an image
+func imageType(data []byte, contentType string) string {
+	if sniffed := http.DetectContentType(data); strings.HasPrefix(sniffed, "image/") {
+		return sniffed
+	}
+	// SVG sniffs as XML or text
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil && strings.HasPrefix(mediaType, "image/") {
+		return mediaType
+	}
+	return ""
+}
+
+// imageExtension returns the file extension for an image type
+func imageExtension(mediaType string) string {
+	if ext, ok := imageExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// webpSize reads the dimensions from the header of a WebP image, which the
+// standard library can't decode
+func webpSize(data []byte) (width, height int, ok bool) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, false
+	}
+	switch string(data[12:16]) {
+	case "VP8 ": // lossy: 14 bit sizes after the frame tag and start code
+		width = int(binary.LittleEndian.Uint16(data[26:28]) & 0x3fff)
+		height = int(binary.LittleEndian.Uint16(data[28:30]) & 0x3fff)
+	case "VP8L": // lossless: 14 bit sizes minus one, packed after the signature byte
+		bits := binary.LittleEndian.Uint32(data[21:25])
+		width = int(bits&0x3fff) + 1
+		height = int(bits>>14&0x3fff) + 1
+	case "VP8X": // extended: 24 bit sizes minus one
+		width = int(uint32(data[24])|uint32(data[25])<<8|uint32(data[26])<<16) + 1
+		height = int(uint32(data[27])|uint32(data[28])<<8|uint32(data[29])<<16) + 1
+	default:
+		return 0, 0, false
+	}
+	return width, height, true
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and
+// renames it into place, so readers never see a partial file
+func writeFileAtomic(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
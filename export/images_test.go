@@ -0,0 +1,74 @@
+package export
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngImage encodes a blank PNG of the given size
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+	return buf.Bytes()
+}
+
+func TestImageStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "images")
+	store, err := NewImageStore(dir)
+	require.NoError(t, err)
+
+	t.Run("stores images by content hash", func(t *testing.T) {
+		data := pngImage(t, 3, 2)
+		img, err := store.Save("http://example.com/a.png", data, "application/octet-stream")
+		require.NoError(t, err)
+
+		assert.Equal(t, "http://example.com/a.png", img.URL)
+		assert.Equal(t, "image/png", img.MIME)
+		assert.Equal(t, 3, img.Width)
+		assert.Equal(t, 2, img.Height)
+		assert.Equal(t, len(data), img.Size)
+		assert.Len(t, img.SHA256, 64)
+		assert.Equal(t, filepath.Join(dir, img.SHA256[:2], img.SHA256+".png"), img.Path)
+
+		stored, err := os.ReadFile(img.Path)
+		require.NoError(t, err)
+		assert.Equal(t, data, stored)
+
+		// The same content from another URL shares the file
+		again, err := store.Save("http://cdn.example.com/b.png", data, "image/png")
+		require.NoError(t, err)
+		assert.Equal(t, img.Path, again.Path)
+		assert.Equal(t, "http://cdn.example.com/b.png", again.URL)
+	})
+
+	t.Run("reads WebP dimensions", func(t *testing.T) {
+		data := []byte("RIFF\x16\x00\x00\x00WEBPVP8X\x0a\x00\x00\x00\x00\x00\x00\x00\x1f\x00\x00\x0f\x00\x00")
+		img, err := store.Save("http://example.com/c.webp", data, "image/webp")
+		require.NoError(t, err)
+		assert.Equal(t, "image/webp", img.MIME)
+		assert.Equal(t, 32, img.Width)
+		assert.Equal(t, 16, img.Height)
+		assert.Equal(t, ".webp", filepath.Ext(img.Path))
+	})
+
+	t.Run("trusts the header for SVG", func(t *testing.T) {
+		img, err := store.Save("http://example.com/d.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`), "image/svg+xml")
+		require.NoError(t, err)
+		assert.Equal(t, "image/svg+xml", img.MIME)
+		assert.Equal(t, ".svg", filepath.Ext(img.Path))
+	})
+
+	t.Run("rejects content that is not an image", func(t *testing.T) {
+		img, err := store.Save("http://example.com/missing.jpg", []byte("<html><body>Not found</body></html>"), "text/html")
+		assert.ErrorContains(t, err, "is not an image")
+		assert.Empty(t, img.Path)
+	})
+}
@@ -0,0 +1,27 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"web-scraper/extract"
+)
+
+// WriteJSON writes the scraped product details to an indented JSON file
+func WriteJSON(products []extract.ProductDetail, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(products); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,74 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// JSONLinesExporter writes each record as one line of JSON. Every record is
+// written to the underlying writer before Export returns, so the output can
+// be tailed while a run is in progress.
+type JSONLinesExporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer // nil when the caller owns w
+	count  int
+}
+
+// NewJSONLinesExporter creates an exporter writing to w. Close does not close w.
+func NewJSONLinesExporter(w io.Writer) *JSONLinesExporter {
+	return &JSONLinesExporter{w: w}
+}
+
+// CreateJSONLines creates an exporter writing to filename. With appendTo
+// set, records are added after those already in the file instead of
+// replacing them.
+func CreateJSONLines(filename string, appendTo bool) (*JSONLinesExporter, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	return &JSONLinesExporter{w: file, closer: file}, nil
+}
+
+// Export writes record as a line of JSON
+func (e *JSONLinesExporter) Export(record any) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	line = append(line, '\n')
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// A single write per record, so the file only ever grows by whole lines
+	if _, err := e.w.Write(line); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	e.count++
+	return nil
+}
+
+// Count returns the number of records exported so far
+func (e *JSONLinesExporter) Count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.count
+}
+
+// Close closes the file opened by CreateJSONLines
+func (e *JSONLinesExporter) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
@@ -0,0 +1,104 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+)
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestJSONLinesExporter(t *testing.T) {
+	t.Run("writes one line per record", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewJSONLinesExporter(&buf)
+
+		require.NoError(t, e.Export(extract.Product{Name: "A", Price: "$1.00"}))
+		require.NoError(t, e.Export(extract.ProductDetail{Name: "B", SKU: "SKU-B"}))
+		require.NoError(t, e.Close())
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+		assert.Contains(t, lines[0], `"name":"A"`)
+		assert.Contains(t, lines[1], `"sku":"SKU-B"`)
+		assert.Equal(t, 2, e.Count())
+	})
+
+	t.Run("records reach the file before Close", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "products.jsonl")
+		e, err := CreateJSONLines(filename, false)
+		require.NoError(t, err)
+		defer e.Close()
+
+		require.NoError(t, e.Export(extract.ProductDetail{Name: "First"}))
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"name":"First"`)
+		assert.True(t, strings.HasSuffix(string(data), "\n"))
+	})
+
+	t.Run("appends or truncates", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "products.jsonl")
+		require.NoError(t, os.WriteFile(filename, []byte("{\"name\":\"old\"}\n"), 0o644))
+
+		e, err := CreateJSONLines(filename, true)
+		require.NoError(t, err)
+		require.NoError(t, e.Export(extract.ProductDetail{Name: "new"}))
+		require.NoError(t, e.Close())
+
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(data), "\n"))
+
+		e, err = CreateJSONLines(filename, false)
+		require.NoError(t, err)
+		require.NoError(t, e.Close())
+
+		data, err = os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Empty(t, data)
+	})
+
+	t.Run("keeps lines whole under concurrent use", func(t *testing.T) {
+		var buf bytes.Buffer
+		e := NewJSONLinesExporter(&buf)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, e.Export(extract.ProductDetail{Name: strings.Repeat("x", 100)}))
+			}()
+		}
+		wg.Wait()
+
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			assert.True(t, strings.HasPrefix(line, "{") && strings.HasSuffix(line, "}"), line)
+		}
+		assert.Equal(t, 20, e.Count())
+	})
+
+	t.Run("reports write and encoding errors", func(t *testing.T) {
+		e := NewJSONLinesExporter(failingWriter{})
+		assert.ErrorContains(t, e.Export(extract.Product{}), "disk full")
+		assert.Error(t, NewJSONLinesExporter(&bytes.Buffer{}).Export(make(chan int)))
+		assert.Equal(t, 0, e.Count())
+	})
+
+	t.Run("file create error", func(t *testing.T) {
+		_, err := CreateJSONLines("/invalid/path/that/does/not/exist/products.jsonl", false)
+		assert.Error(t, err)
+	})
+}
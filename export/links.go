@@ -0,0 +1,25 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// WriteLinks writes one link per line to filename
+func WriteLinks(links []string, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, link := range links {
+		if _, err := fmt.Fprintln(writer, link); err != nil {
+			return fmt.Errorf("failed to write link: %w", err)
+		}
+	}
+
+	return writer.Flush()
+}
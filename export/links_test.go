@@ -0,0 +1,27 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteLinks(t *testing.T) {
+	t.Run("writes one link per line", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "links.txt")
+		err := WriteLinks([]string{"http://example.com/a", "http://example.com/b"}, filename)
+		require.NoError(t, err)
+
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com/a\nhttp://example.com/b\n", string(data))
+	})
+
+	t.Run("file write error", func(t *testing.T) {
+		err := WriteLinks(nil, "/invalid/path/that/does/not/exist/links.txt")
+		assert.Error(t, err)
+	})
+}
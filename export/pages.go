@@ -0,0 +1,81 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"web-scraper/extract"
+)
+
+// pageHeader is the header row of a pages CSV. OpenGraph and Twitter card
+// fields get a column each.
+var pageHeader = []string{
+	"URL", "Status Code", "Content Type", "Size", "Title", "Description", "Canonical", "Language",
+	"OG Title", "OG Description", "OG Type", "OG URL", "OG Image", "OG Site Name",
+	"Twitter Card", "Twitter Title", "Twitter Description", "Twitter Image", "Twitter Site",
+	"Error", "Crawled At",
+}
+
+// WritePagesCSV writes the records of crawled pages to a CSV file
+func WritePagesCSV(pages []extract.Page, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write(pageHeader); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	for _, page := range pages {
+		og, tw := page.OpenGraph, page.Twitter
+		row := []string{
+			page.URL,
+			strconv.Itoa(page.StatusCode),
+			page.ContentType,
+			strconv.Itoa(page.Size),
+			page.Title,
+			page.Description,
+			page.Canonical,
+			page.Language,
+			og.Title, og.Description, og.Type, og.URL, og.Image, og.SiteName,
+			tw.Card, tw.Title, tw.Description, tw.Image, tw.Site,
+			page.Error,
+			page.CrawledAt.Format(time.RFC3339),
+		}
+		if err := writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write row: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// WritePagesJSON writes the records of crawled pages to an indented JSON file
+func WritePagesJSON(pages []extract.Page, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %w", err)
+	}
+	defer file.Close()
+
+	if pages == nil {
+		pages = []extract.Page{} // an empty crawl is [], not null
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(pages); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+	"web-scraper/internal/testutil"
+)
+
+var testPages = []extract.Page{
+	{
+		URL:         "http://example.com/",
+		StatusCode:  200,
+		ContentType: "text/html",
+		Size:        1024,
+		Title:       "Home",
+		Description: "The home page",
+		Canonical:   "http://example.com/",
+		Language:    "en",
+		OpenGraph:   extract.OpenGraph{Title: "Home OG", Image: "http://example.com/og.jpg"},
+		Twitter:     extract.TwitterCard{Card: "summary", Site: "@example"},
+		CrawledAt:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	},
+	{
+		URL:        "http://example.com/missing",
+		StatusCode: 404,
+		Error:      "Not Found",
+		CrawledAt:  time.Date(2024, 1, 1, 12, 0, 1, 0, time.UTC),
+	},
+}
+
+func TestWritePagesCSV(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pages.csv")
+	require.NoError(t, WritePagesCSV(testPages, filename))
+
+	rows, err := testutil.ReadCSVFile(filename)
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, pageHeader, rows[0])
+	assert.Equal(t, []string{
+		"http://example.com/", "200", "text/html", "1024", "Home", "The home page", "http://example.com/", "en",
+		"Home OG", "", "", "", "http://example.com/og.jpg", "",
+		"summary", "", "", "", "@example",
+		"", "2024-01-01T12:00:00Z",
+	}, rows[1])
+	assert.Equal(t, "404", rows[2][1])
+	assert.Equal(t, "Not Found", rows[2][19])
+}
+
+func TestWritePagesJSON(t *testing.T) {
+	t.Run("round-trips the page records", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "pages.json")
+		require.NoError(t, WritePagesJSON(testPages, filename))
+
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		var pages []extract.Page
+		require.NoError(t, json.Unmarshal(data, &pages))
+		assert.Equal(t, testPages, pages)
+	})
+
+	t.Run("writes an empty array for no pages", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "pages.json")
+		require.NoError(t, WritePagesJSON(nil, filename))
+
+		data, err := os.ReadFile(filename)
+		require.NoError(t, err)
+		assert.Equal(t, "[]\n", string(data))
+	})
+}
@@ -0,0 +1,324 @@
+package export
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"web-scraper/extract"
+)
+
+// SQLiteDriver is the database/sql driver used by OpenSQLite. The pure-Go
+// modernc.org/sqlite driver registers it in binaries built with -tags sqlite.
+const SQLiteDriver = "sqlite"
+
+// ErrNoSQLiteDriver is returned by OpenSQLite when no driver is registered
+var ErrNoSQLiteDriver = errors.New("SQLite support is not built in, rebuild with -tags sqlite")
+
+// sqliteSchema creates the tables on first use. Products are keyed by URL,
+// and by SKU when they have one, so a product keeps its row across runs.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at  TEXT NOT NULL,
+	finished_at TEXT,
+	products    INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS products (
+	id              INTEGER PRIMARY KEY AUTOINCREMENT,
+	url             TEXT NOT NULL UNIQUE,
+	sku             TEXT NOT NULL DEFAULT '',
+	name            TEXT NOT NULL,
+	price           TEXT NOT NULL DEFAULT '',
+	currency        TEXT NOT NULL DEFAULT '',
+	amount          INTEGER NOT NULL DEFAULT 0,
+	original_amount INTEGER NOT NULL DEFAULT 0,
+	max_amount      INTEGER NOT NULL DEFAULT 0,
+	description     TEXT NOT NULL DEFAULT '',
+	category        TEXT NOT NULL DEFAULT '',
+	image_url       TEXT NOT NULL DEFAULT '',
+	in_stock        INTEGER,
+	first_seen      TEXT NOT NULL,
+	last_seen       TEXT NOT NULL,
+	first_run       INTEGER NOT NULL REFERENCES runs(id),
+	last_run        INTEGER NOT NULL REFERENCES runs(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS products_sku ON products(sku) WHERE sku <> '';
+CREATE TABLE IF NOT EXISTS pages (
+	id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+	url                 TEXT NOT NULL UNIQUE,
+	status_code         INTEGER NOT NULL DEFAULT 0,
+	content_type        TEXT NOT NULL DEFAULT '',
+	size                INTEGER NOT NULL DEFAULT 0,
+	title               TEXT NOT NULL DEFAULT '',
+	description         TEXT NOT NULL DEFAULT '',
+	canonical           TEXT NOT NULL DEFAULT '',
+	language            TEXT NOT NULL DEFAULT '',
+	og_title            TEXT NOT NULL DEFAULT '',
+	og_description      TEXT NOT NULL DEFAULT '',
+	og_type             TEXT NOT NULL DEFAULT '',
+	og_url              TEXT NOT NULL DEFAULT '',
+	og_image            TEXT NOT NULL DEFAULT '',
+	og_site_name        TEXT NOT NULL DEFAULT '',
+	twitter_card        TEXT NOT NULL DEFAULT '',
+	twitter_title       TEXT NOT NULL DEFAULT '',
+	twitter_description TEXT NOT NULL DEFAULT '',
+	twitter_image       TEXT NOT NULL DEFAULT '',
+	twitter_site        TEXT NOT NULL DEFAULT '',
+	error               TEXT NOT NULL DEFAULT '',
+	first_seen          TEXT NOT NULL,
+	last_seen           TEXT NOT NULL,
+	first_run           INTEGER NOT NULL REFERENCES runs(id),
+	last_run            INTEGER NOT NULL REFERENCES runs(id)
+);
+`
+
+// SQLiteExporter upserts products, and the page records of a crawl, into an
+// SQLite database. Each exporter records one run in the runs table; products
+// and pages seen before keep their row and first_seen, and get last_seen and
+// the latest values. The run's product count leaves pages out.
+type SQLiteExporter struct {
+	mu    sync.Mutex
+	db    *sql.DB
+	runID int64
+	count int
+}
+
+// OpenSQLite opens or creates the database at filename and starts a run
+func OpenSQLite(filename string) (*SQLiteExporter, error) {
+	if !driverRegistered(SQLiteDriver) {
+		return nil, ErrNoSQLiteDriver
+	}
+
+	db, err := sql.Open(SQLiteDriver, filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// One connection serialises writes, which SQLite needs anyway
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	result, err := db.Exec(`INSERT INTO runs (started_at) VALUES (?)`, formatTime(time.Now()))
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to start run: %w", err)
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to start run: %w", err)
+	}
+
+	return &SQLiteExporter{db: db, runID: runID}, nil
+}
+
+// driverRegistered reports whether a database/sql driver called name exists
+func driverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// RunID returns the id of the run recorded by this exporter
+func (e *SQLiteExporter) RunID() int64 {
+	return e.runID
+}
+
+// Export upserts an extract.Product, extract.ProductDetail or extract.Page
+func (e *SQLiteExporter) Export(record any) error {
+	var row productRow
+	switch p := record.(type) {
+	case extract.Page:
+		return e.exportPage(p)
+	case *extract.Page:
+		return e.exportPage(*p)
+	case extract.Product:
+		row = listingRow(p)
+	case *extract.Product:
+		row = listingRow(*p)
+	case extract.ProductDetail:
+		row = detailRow(p)
+	case *extract.ProductDetail:
+		row = detailRow(*p)
+	default:
+		return fmt.Errorf("cannot export %T to SQLite", record)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.upsert(row); err != nil {
+		return fmt.Errorf("failed to save %s: %w", row.url, err)
+	}
+	e.count++
+	return nil
+}
+
+// productRow holds the column values of a product. Listing products have
+// no detail columns, which are then left as they are.
+type productRow struct {
+	url, sku, name, price string
+	pricing               extract.Price
+	image                 string
+	detail                bool
+	description, category string
+	inStock               bool
+	seen                  time.Time
+}
+
+// listingRow converts a product from a listing page
+func listingRow(p extract.Product) productRow {
+	return productRow{
+		url:     p.URL,
+		name:    p.Name,
+		price:   p.Price,
+		pricing: p.Pricing,
+		image:   p.Image,
+		seen:    p.ScrapedAt,
+	}
+}
+
+// detailRow converts a product from its detail page
+func detailRow(p extract.ProductDetail) productRow {
+	return productRow{
+		url:         p.URL,
+		sku:         p.SKU,
+		name:        p.Name,
+		price:       p.Price,
+		pricing:     p.Pricing,
+		image:       p.ImageURL,
+		detail:      true,
+		description: p.Description,
+		category:    p.Category,
+		inStock:     p.InStock,
+		seen:        p.ScrapedAt,
+	}
+}
+
+// upsert inserts row, or updates the product with the same SKU or URL
+func (e *SQLiteExporter) upsert(row productRow) error {
+	if row.url == "" {
+		return errors.New("product has no URL")
+	}
+	if row.seen.IsZero() {
+		row.seen = time.Now()
+	}
+	seen := formatTime(row.seen)
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A matching SKU wins over a matching URL, so a product that moved
+	// keeps its row
+	var id int64
+	err = tx.QueryRow(`SELECT id FROM products WHERE (sku <> '' AND sku = ?) OR url = ?
+		ORDER BY sku = ? DESC LIMIT 1`, row.sku, row.url, row.sku).Scan(&id)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		_, err = tx.Exec(`INSERT INTO products (url, sku, name, price, currency, amount, original_amount,
+			max_amount, description, category, image_url, in_stock, first_seen, last_seen, first_run, last_run)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			row.url, row.sku, row.name, row.price, row.pricing.Currency, row.pricing.Amount,
+			row.pricing.OriginalAmount, row.pricing.MaxAmount, row.description, row.category,
+			row.image, row.stock(), seen, seen, e.runID, e.runID)
+	case err != nil:
+		return err
+	case row.detail:
+		// OR REPLACE merges a row found by URL alone, e.g. from a listing, into
+		// the one found by SKU
+		_, err = tx.Exec(`UPDATE OR REPLACE products SET url = ?, sku = ?, name = ?, price = ?, currency = ?, amount = ?,
+			original_amount = ?, max_amount = ?, description = ?, category = ?, image_url = ?, in_stock = ?,
+			last_seen = ?, last_run = ? WHERE id = ?`,
+			row.url, row.sku, row.name, row.price, row.pricing.Currency, row.pricing.Amount,
+			row.pricing.OriginalAmount, row.pricing.MaxAmount, row.description, row.category,
+			row.image, row.stock(), seen, e.runID, id)
+	default:
+		_, err = tx.Exec(`UPDATE products SET name = ?, price = ?, currency = ?, amount = ?,
+			original_amount = ?, max_amount = ?, image_url = ?, last_seen = ?, last_run = ? WHERE id = ?`,
+			row.name, row.price, row.pricing.Currency, row.pricing.Amount, row.pricing.OriginalAmount,
+			row.pricing.MaxAmount, row.image, seen, e.runID, id)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// exportPage upserts the record of a crawled page by URL
+func (e *SQLiteExporter) exportPage(page extract.Page) error {
+	if page.URL == "" {
+		return errors.New("failed to save page: page has no URL")
+	}
+	seen := page.CrawledAt
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	og, tw := page.OpenGraph, page.Twitter
+	_, err := e.db.Exec(`INSERT INTO pages (url, status_code, content_type, size, title, description,
+		canonical, language, og_title, og_description, og_type, og_url, og_image, og_site_name,
+		twitter_card, twitter_title, twitter_description, twitter_image, twitter_site, error,
+		first_seen, last_seen, first_run, last_run)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(url) DO UPDATE SET status_code = excluded.status_code,
+		content_type = excluded.content_type, size = excluded.size, title = excluded.title,
+		description = excluded.description, canonical = excluded.canonical, language = excluded.language,
+		og_title = excluded.og_title, og_description = excluded.og_description, og_type = excluded.og_type,
+		og_url = excluded.og_url, og_image = excluded.og_image, og_site_name = excluded.og_site_name,
+		twitter_card = excluded.twitter_card, twitter_title = excluded.twitter_title,
+		twitter_description = excluded.twitter_description, twitter_image = excluded.twitter_image,
+		twitter_site = excluded.twitter_site, error = excluded.error,
+		last_seen = excluded.last_seen, last_run = excluded.last_run`,
+		page.URL, page.StatusCode, page.ContentType, page.Size, page.Title, page.Description,
+		page.Canonical, page.Language, og.Title, og.Description, og.Type, og.URL, og.Image, og.SiteName,
+		tw.Card, tw.Title, tw.Description, tw.Image, tw.Site, page.Error,
+		formatTime(seen), formatTime(seen), e.runID, e.runID)
+	if err != nil {
+		return fmt.Errorf("failed to save page %s: %w", page.URL, err)
+	}
+	return nil
+}
+
+// stock returns the in_stock column, NULL when the listing doesn't tell
+func (row productRow) stock() any {
+	if !row.detail {
+		return nil
+	}
+	return row.inStock
+}
+
+// Close records the end of the run and closes the database
+func (e *SQLiteExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err := e.db.Exec(`UPDATE runs SET finished_at = ?, products = ? WHERE id = ?`,
+		formatTime(time.Now()), e.count, e.runID)
+	if closeErr := e.db.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to finish run: %w", err)
+	}
+	return nil
+}
+
+// formatTime renders t as stored in the database
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
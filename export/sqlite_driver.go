@@ -0,0 +1,6 @@
+//go:build sqlite
+
+package export
+
+// Registers the pure-Go SQLite driver used by OpenSQLite
+import _ "modernc.org/sqlite"
@@ -0,0 +1,15 @@
+//go:build !sqlite
+
+package export
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenSQLiteWithoutDriver(t *testing.T) {
+	_, err := OpenSQLite(filepath.Join(t.TempDir(), "products.db"))
+	assert.ErrorIs(t, err, ErrNoSQLiteDriver)
+}
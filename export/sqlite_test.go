@@ -0,0 +1,184 @@
+//go:build sqlite
+
+package export
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+)
+
+// storedProduct is a products row as read back in the tests
+type storedProduct struct {
+	URL, SKU, Name, Price, Description string
+	Amount                             int64
+	InStock                            sql.NullBool
+	FirstSeen, LastSeen                string
+	FirstRun, LastRun                  int64
+}
+
+// readProducts returns the products rows ordered by id
+func readProducts(t *testing.T, filename string) []storedProduct {
+	t.Helper()
+	db, err := sql.Open(SQLiteDriver, filename)
+	require.NoError(t, err)
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT url, sku, name, price, description, amount, in_stock,
+		first_seen, last_seen, first_run, last_run FROM products ORDER BY id`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var products []storedProduct
+	for rows.Next() {
+		var p storedProduct
+		require.NoError(t, rows.Scan(&p.URL, &p.SKU, &p.Name, &p.Price, &p.Description, &p.Amount,
+			&p.InStock, &p.FirstSeen, &p.LastSeen, &p.FirstRun, &p.LastRun))
+		products = append(products, p)
+	}
+	require.NoError(t, rows.Err())
+	return products
+}
+
+// exportRun exports records as one run and returns its id
+func exportRun(t *testing.T, filename string, records ...any) int64 {
+	t.Helper()
+	e, err := OpenSQLite(filename)
+	require.NoError(t, err)
+	for _, record := range records {
+		require.NoError(t, e.Export(record))
+	}
+	require.NoError(t, e.Close())
+	return e.RunID()
+}
+
+func TestSQLiteExporter(t *testing.T) {
+	day1 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	t.Run("upserts by URL and keeps first_seen", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "products.db")
+		run1 := exportRun(t, filename,
+			extract.ProductDetail{URL: "http://shop/a", SKU: "A", Name: "A", Price: "$1.00",
+				Pricing: extract.Price{Currency: "USD", Amount: 100}, InStock: true, ScrapedAt: day1},
+			extract.ProductDetail{URL: "http://shop/b", Name: "B", ScrapedAt: day1},
+		)
+		run2 := exportRun(t, filename,
+			extract.ProductDetail{URL: "http://shop/a", SKU: "A", Name: "A", Price: "$0.80",
+				Pricing: extract.Price{Currency: "USD", Amount: 80}, ScrapedAt: day2},
+		)
+		assert.Greater(t, run2, run1)
+
+		products := readProducts(t, filename)
+		require.Len(t, products, 2)
+		assert.Equal(t, "$0.80", products[0].Price)
+		assert.Equal(t, int64(80), products[0].Amount)
+		assert.Equal(t, sql.NullBool{Bool: false, Valid: true}, products[0].InStock)
+		assert.Equal(t, "2024-01-01T12:00:00Z", products[0].FirstSeen)
+		assert.Equal(t, "2024-01-02T12:00:00Z", products[0].LastSeen)
+		assert.Equal(t, []int64{run1, run2}, []int64{products[0].FirstRun, products[0].LastRun})
+		assert.Equal(t, []int64{run1, run1}, []int64{products[1].FirstRun, products[1].LastRun})
+	})
+
+	t.Run("matches a moved product by SKU", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "products.db")
+		exportRun(t, filename, extract.ProductDetail{URL: "http://shop/old", SKU: "S1", Name: "Moved", ScrapedAt: day1})
+		exportRun(t, filename, extract.ProductDetail{URL: "http://shop/new", SKU: "S1", Name: "Moved", ScrapedAt: day2})
+
+		products := readProducts(t, filename)
+		require.Len(t, products, 1)
+		assert.Equal(t, "http://shop/new", products[0].URL)
+		assert.Equal(t, "2024-01-01T12:00:00Z", products[0].FirstSeen)
+	})
+
+	t.Run("merges a listing row into the detail row", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "products.db")
+		exportRun(t, filename,
+			extract.ProductDetail{URL: "http://shop/old", SKU: "S1", Name: "Detail", Description: "kept", ScrapedAt: day1},
+			extract.Product{URL: "http://shop/new", Name: "Listing", ScrapedAt: day1},
+		)
+		exportRun(t, filename, extract.ProductDetail{URL: "http://shop/new", SKU: "S1", Name: "Detail", ScrapedAt: day2})
+
+		products := readProducts(t, filename)
+		require.Len(t, products, 1)
+		assert.Equal(t, "http://shop/new", products[0].URL)
+	})
+
+	t.Run("listing products keep the detail columns", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "products.db")
+		exportRun(t, filename, &extract.ProductDetail{URL: "http://shop/a", Name: "A", Description: "long", InStock: true, ScrapedAt: day1})
+		exportRun(t, filename, &extract.Product{URL: "http://shop/a", Name: "A", Price: "$2.00", ScrapedAt: day2})
+
+		products := readProducts(t, filename)
+		require.Len(t, products, 1)
+		assert.Equal(t, "long", products[0].Description)
+		assert.Equal(t, "$2.00", products[0].Price)
+		assert.Equal(t, sql.NullBool{Bool: true, Valid: true}, products[0].InStock)
+	})
+
+	t.Run("records each run", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), "products.db")
+		exportRun(t, filename, extract.Product{URL: "http://shop/a", Name: "A"}, extract.Product{URL: "http://shop/b", Name: "B"})
+		exportRun(t, filename)
+
+		db, err := sql.Open(SQLiteDriver, filename)
+		require.NoError(t, err)
+		defer db.Close()
+
+		var counts []int
+		rows, err := db.Query(`SELECT products FROM runs WHERE finished_at IS NOT NULL ORDER BY id`)
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var n int
+			require.NoError(t, rows.Scan(&n))
+			counts = append(counts, n)
+		}
+		assert.Equal(t, []int{2, 0}, counts)
+	})
+
+	t.Run("rejects other records", func(t *testing.T) {
+		e, err := OpenSQLite(filepath.Join(t.TempDir(), "products.db"))
+		require.NoError(t, err)
+		defer e.Close()
+
+		assert.ErrorContains(t, e.Export("http://shop/a"), "cannot export string")
+		assert.ErrorContains(t, e.Export(extract.Product{Name: "No URL"}), "no URL")
+	})
+}
+
+func TestSQLitePages(t *testing.T) {
+	day1 := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	filename := filepath.Join(t.TempDir(), "crawl.db")
+	run1 := exportRun(t, filename, extract.Page{URL: "http://shop/", StatusCode: 200, Title: "Old", CrawledAt: day1})
+	run2 := exportRun(t, filename, &extract.Page{
+		URL: "http://shop/", StatusCode: 200, Title: "Home", Canonical: "http://shop/",
+		OpenGraph: extract.OpenGraph{Title: "Home OG"}, Twitter: extract.TwitterCard{Card: "summary"},
+		CrawledAt: day2,
+	})
+
+	db, err := sql.Open(SQLiteDriver, filename)
+	require.NoError(t, err)
+	defer db.Close()
+
+	var title, canonical, ogTitle, card, firstSeen, lastSeen string
+	var firstRun, lastRun int64
+	require.NoError(t, db.QueryRow(`SELECT title, canonical, og_title, twitter_card, first_seen, last_seen,
+		first_run, last_run FROM pages WHERE url = ?`, "http://shop/").
+		Scan(&title, &canonical, &ogTitle, &card, &firstSeen, &lastSeen, &firstRun, &lastRun))
+	assert.Equal(t, "Home", title)
+	assert.Equal(t, "http://shop/", canonical)
+	assert.Equal(t, "Home OG", ogTitle)
+	assert.Equal(t, "summary", card)
+	assert.Equal(t, "2024-01-01T12:00:00Z", firstSeen)
+	assert.Equal(t, "2024-01-02T12:00:00Z", lastSeen)
+	assert.Equal(t, []int64{run1, run2}, []int64{firstRun, lastRun})
+	assert.Empty(t, readProducts(t, filename))
+}
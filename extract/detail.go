@@ -0,0 +1,99 @@
+package extract
+
+import (
+	"time"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// ExtractDetail reads a product from e, the detail root of its page. The
+// schema.org Product in the page's JSON-LD, microdata or RDFa is preferred,
+// as it doesn't break when the theme changes; the selectors only fill in the
+// fields it lacks. Variants come from the platform markup, or else from a
+// schema.org ProductGroup. URLs are made absolute and canonicalized with the
+// profile's URL policy.
+func (p *SiteProfile) ExtractDetail(e *colly.HTMLElement) ProductDetail {
+	detail := p.Detail
+	product := ProductDetail{
+		URL:       p.URLs.Resolve(e.Request, e.Request.URL.String()),
+		ScrapedAt: time.Now(),
+	}
+
+	structured := &StructuredProduct{}
+	if !detail.IgnoreStructuredData {
+		if found, ok := FindStructuredProduct(e.DOM.Closest("html")); ok {
+			structured = found
+		}
+	}
+
+	product.Name = orSelect(structured.Name, detail.Name, e)
+	product.Description = orSelect(structured.Description, detail.Description, e)
+	product.SKU = orSelect(structured.SKU, detail.SKU, e)
+	product.Category = orSelect(structured.Category, detail.Category, e)
+	product.Brand = structured.Brand
+	product.GTIN = structured.GTIN
+	product.Rating = structured.Rating
+	product.ReviewCount = structured.ReviewCount
+
+	product.ImageURL = p.URLs.Resolve(e.Request, orSelect(structured.Image, detail.Image, e))
+
+	if structured.Pricing.IsZero() {
+		product.Price = detail.Price.Extract(e)
+		// Unparseable prices keep only the raw text
+		product.Pricing, _ = p.PriceFormat.Parse(product.Price)
+	} else {
+		product.Price, product.Pricing = structured.Price, structured.Pricing
+	}
+
+	inStock, ok := structured.InStock()
+	if !ok {
+		inStock = detail.InStock(e)
+	}
+	product.InStock = inStock
+
+	currency := product.Pricing.Currency
+	if currency == "" {
+		currency = p.PriceFormat.Currency
+	}
+	product.Variants = FindVariants(e.DOM.Closest("html"), currency)
+	if len(product.Variants) == 0 {
+		product.Variants = structured.Variants
+	}
+	for i := range product.Variants {
+		product.Variants[i].ImageURL = p.URLs.Resolve(e.Request, product.Variants[i].ImageURL)
+	}
+
+	product.Images = p.galleryImages(&product, structured.Images, detail.Gallery.ExtractAll(e), e)
+	return product
+}
+
+// galleryImages lists every image of product once: the main image, then the
+// structured data's, the gallery's and the variants'
+func (p *SiteProfile) galleryImages(product *ProductDetail, structured, gallery []string, e *colly.HTMLElement) []Image {
+	urls := []string{product.ImageURL}
+	urls = append(urls, structured...)
+	urls = append(urls, gallery...)
+	for _, variant := range product.Variants {
+		urls = append(urls, variant.ImageURL)
+	}
+
+	var images []Image
+	seen := make(map[string]bool)
+	for _, imageURL := range urls {
+		imageURL = p.URLs.Resolve(e.Request, imageURL)
+		if imageURL == "" || seen[imageURL] {
+			continue
+		}
+		seen[imageURL] = true
+		images = append(images, Image{URL: imageURL})
+	}
+	return images
+}
+
+// orSelect returns value, or the selector's value when it is empty
+func orSelect(value string, selector FieldSelector, e *colly.HTMLElement) string {
+	if value != "" {
+		return value
+	}
+	return selector.Extract(e)
+}
@@ -0,0 +1,156 @@
+package extract
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// BrowserProfile is the set of headers one browser sends. Requests use a
+// whole profile, so a Firefox User-Agent never comes with Chrome's client
+// hints. Accept-Encoding is left to the HTTP transport, which decompresses
+// what it asked for.
+type BrowserProfile struct {
+	Name           string
+	UserAgent      string
+	Accept         string // of pages
+	ImageAccept    string
+	AcceptLanguage string
+	// ClientHints are the Sec-CH-UA headers of Chromium browsers, empty for
+	// the others
+	ClientHints map[string]string
+}
+
+// BrowserProfiles is the catalogue of browser header profiles by name
+var BrowserProfiles = map[string]BrowserProfile{
+	"chrome-windows": {
+		Name:           "chrome-windows",
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		Accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+		ImageAccept:    "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+		AcceptLanguage: "en-US,en;q=0.9",
+		ClientHints: map[string]string{
+			"Sec-CH-UA":          `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`,
+			"Sec-CH-UA-Mobile":   "?0",
+			"Sec-CH-UA-Platform": `"Windows"`,
+		},
+	},
+	"chrome-mac": {
+		Name:           "chrome-mac",
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		Accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+		ImageAccept:    "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+		AcceptLanguage: "en-US,en;q=0.9",
+		ClientHints: map[string]string{
+			"Sec-CH-UA":          `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`,
+			"Sec-CH-UA-Mobile":   "?0",
+			"Sec-CH-UA-Platform": `"macOS"`,
+		},
+	},
+	"edge-windows": {
+		Name:           "edge-windows",
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+		Accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+		ImageAccept:    "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+		AcceptLanguage: "en-US,en;q=0.9",
+		ClientHints: map[string]string{
+			"Sec-CH-UA":          `"Not_A Brand";v="8", "Chromium";v="120", "Microsoft Edge";v="120"`,
+			"Sec-CH-UA-Mobile":   "?0",
+			"Sec-CH-UA-Platform": `"Windows"`,
+		},
+	},
+	"firefox-windows": {
+		Name:           "firefox-windows",
+		UserAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		Accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+		ImageAccept:    "image/avif,image/webp,*/*",
+		AcceptLanguage: "en-US,en;q=0.5",
+	},
+	"firefox-mac": {
+		Name:           "firefox-mac",
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:121.0) Gecko/20100101 Firefox/121.0",
+		Accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+		ImageAccept:    "image/avif,image/webp,*/*",
+		AcceptLanguage: "en-US,en;q=0.5",
+	},
+	"safari-mac": {
+		Name:           "safari-mac",
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+		Accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		ImageAccept:    "image/webp,image/avif,image/jxl,image/heic,image/heic-sequence,video/*;q=0.8,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+		AcceptLanguage: "en-US,en;q=0.9",
+	},
+}
+
+// BrowserNames returns the names of the catalogue, sorted
+func BrowserNames() []string {
+	names := make([]string, 0, len(BrowserProfiles))
+	for name := range BrowserProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rotation is when a HeaderPolicy picks another browser profile
+type Rotation string
+
+// Header rotations
+const (
+	RotatePerSession Rotation = "session" // one profile for the whole run, the default
+	RotatePerDomain  Rotation = "domain"  // one profile per domain
+)
+
+// HeaderPolicy picks the browser header profiles requests are sent with. Its
+// zero value uses one profile of the whole catalogue for the whole run.
+type HeaderPolicy struct {
+	Browsers []string `yaml:"browsers,omitempty" json:"browsers,omitempty"` // profile names, empty selects all
+	Rotate   Rotation `yaml:"rotate,omitempty" json:"rotate,omitempty"`
+	// AcceptLanguage replaces the language of every profile, e.g. to get a
+	// shop's German prices
+	AcceptLanguage string `yaml:"accept_language,omitempty" json:"accept_language,omitempty"`
+	// Extra headers are added to every request, after the profile's
+	Extra map[string]string `yaml:"extra,omitempty" json:"extra,omitempty"`
+}
+
+// Validate checks the browser names and rotation
+func (p HeaderPolicy) Validate() error {
+	for _, name := range p.Browsers {
+		if _, ok := BrowserProfiles[name]; !ok {
+			return fmt.Errorf("unknown browser %q, expected one of %s", name, strings.Join(BrowserNames(), ", "))
+		}
+	}
+	switch p.Rotate {
+	case "", RotatePerSession, RotatePerDomain:
+	default:
+		return fmt.Errorf("unknown rotate %q, expected %q or %q", p.Rotate, RotatePerSession, RotatePerDomain)
+	}
+	for name := range p.Extra {
+		if http.CanonicalHeaderKey(name) == "User-Agent" || strings.HasPrefix(http.CanonicalHeaderKey(name), "Sec-Ch-Ua") {
+			return fmt.Errorf("extra header %s would contradict the browser profile", name)
+		}
+	}
+	return nil
+}
+
+// Profiles returns the browser profiles the policy picks from, in name
+// order, with its Accept-Language applied
+func (p HeaderPolicy) Profiles() []BrowserProfile {
+	names := p.Browsers
+	if len(names) == 0 {
+		names = BrowserNames()
+	}
+	profiles := make([]BrowserProfile, 0, len(names))
+	for _, name := range names {
+		profile, ok := BrowserProfiles[name]
+		if !ok {
+			continue
+		}
+		if p.AcceptLanguage != "" {
+			profile.AcceptLanguage = p.AcceptLanguage
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles
+}
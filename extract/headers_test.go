@@ -0,0 +1,49 @@
+package extract
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrowserProfiles(t *testing.T) {
+	for name, profile := range BrowserProfiles {
+		assert.Equal(t, name, profile.Name)
+		assert.NotEmpty(t, profile.UserAgent, name)
+		assert.NotEmpty(t, profile.Accept, name)
+		assert.NotEmpty(t, profile.ImageAccept, name)
+		assert.NotEmpty(t, profile.AcceptLanguage, name)
+		// Only Chromium browsers send client hints
+		chromium := strings.Contains(profile.UserAgent, "Chrome/")
+		assert.Equal(t, chromium, len(profile.ClientHints) > 0, name)
+	}
+}
+
+func TestHeaderPolicyValidate(t *testing.T) {
+	assert.NoError(t, HeaderPolicy{}.Validate())
+	assert.NoError(t, HeaderPolicy{Browsers: []string{"firefox-mac"}, Rotate: RotatePerDomain}.Validate())
+	assert.NoError(t, HeaderPolicy{Extra: map[string]string{"DNT": "1"}}.Validate())
+
+	assert.ErrorContains(t, HeaderPolicy{Browsers: []string{"netscape"}}.Validate(), "unknown browser")
+	assert.ErrorContains(t, HeaderPolicy{Rotate: "request"}.Validate(), "unknown rotate")
+	assert.Error(t, HeaderPolicy{Extra: map[string]string{"user-agent": "curl/8.0"}}.Validate())
+	assert.Error(t, HeaderPolicy{Extra: map[string]string{"Sec-CH-UA-Platform": `"Linux"`}}.Validate())
+}
+
+func TestHeaderPolicyProfiles(t *testing.T) {
+	t.Run("empty selects the whole catalogue", func(t *testing.T) {
+		assert.Len(t, HeaderPolicy{}.Profiles(), len(BrowserProfiles))
+	})
+
+	t.Run("applies the accept language", func(t *testing.T) {
+		profiles := HeaderPolicy{Browsers: []string{"safari-mac", "chrome-mac"}, AcceptLanguage: "de-DE,de;q=0.9"}.Profiles()
+		if assert.Len(t, profiles, 2) {
+			assert.Equal(t, "safari-mac", profiles[0].Name)
+			assert.Equal(t, "de-DE,de;q=0.9", profiles[0].AcceptLanguage)
+			assert.Equal(t, "de-DE,de;q=0.9", profiles[1].AcceptLanguage)
+		}
+		// The catalogue itself is left alone
+		assert.Equal(t, "en-US,en;q=0.9", BrowserProfiles["safari-mac"].AcceptLanguage)
+	})
+}
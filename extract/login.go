@@ -0,0 +1,187 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// Default names of the login form fields
+const (
+	DefaultUsernameField = "username"
+	DefaultPasswordField = "password"
+)
+
+// LoginConfig describes how to log in to a shop that shows prices only to
+// customers. The credentials come from environment variables, so profiles
+// can be shared without them.
+type LoginConfig struct {
+	URL string `yaml:"url" json:"url"` // login page, absolute or relative to the start URL
+	// Form selects the login form on the page, empty selects the first form
+	// with a password field. Its hidden fields, CSRF tokens included, are
+	// submitted along with the credentials.
+	Form          string            `yaml:"form,omitempty" json:"form,omitempty"`
+	UsernameField string            `yaml:"username_field,omitempty" json:"username_field,omitempty"` // empty selects DefaultUsernameField
+	PasswordField string            `yaml:"password_field,omitempty" json:"password_field,omitempty"` // empty selects DefaultPasswordField
+	UsernameEnv   string            `yaml:"username_env" json:"username_env"`
+	PasswordEnv   string            `yaml:"password_env" json:"password_env"`
+	Fields        map[string]string `yaml:"fields,omitempty" json:"fields,omitempty"` // more values to submit, e.g. remember: "1"
+	// CSRF reads a token from outside the form, such as a meta tag, and sends
+	// it in CSRFField, the CSRFHeader request header or both
+	CSRF       FieldSelector `yaml:"csrf,omitempty" json:"csrf,omitempty"`
+	CSRFField  string        `yaml:"csrf_field,omitempty" json:"csrf_field,omitempty"`
+	CSRFHeader string        `yaml:"csrf_header,omitempty" json:"csrf_header,omitempty"`
+	// Success matches an element only logged-in customers see, such as a
+	// logout link, on the page the login ends on
+	Success string `yaml:"success" json:"success"`
+	// Expired matches an element of the page a logged-out visitor gets, such
+	// as the login form, on any page of the run. Redirects to the login page
+	// are recognised without it.
+	Expired string `yaml:"expired,omitempty" json:"expired,omitempty"`
+}
+
+// LoginForm is the request that submits a login form
+type LoginForm struct {
+	Method string
+	Action *url.URL
+	Values url.Values
+	Header http.Header // the CSRF header, if any
+}
+
+// Validate checks that the login page, credentials and success check are set
+func (c *LoginConfig) Validate() error {
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"login.url", c.URL},
+		{"login.username_env", c.UsernameEnv},
+		{"login.password_env", c.PasswordEnv},
+		{"login.success", c.Success},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.New("missing login settings: " + strings.Join(missing, ", "))
+	}
+	if c.CSRF.Selector != "" && c.CSRFField == "" && c.CSRFHeader == "" {
+		return errors.New("login.csrf needs login.csrf_field or login.csrf_header")
+	}
+	return nil
+}
+
+// Credentials reads the username and password from the environment
+func (c *LoginConfig) Credentials() (string, string, error) {
+	username, password := os.Getenv(c.UsernameEnv), os.Getenv(c.PasswordEnv)
+	for _, env := range []struct{ name, value string }{
+		{c.UsernameEnv, username},
+		{c.PasswordEnv, password},
+	} {
+		if env.value == "" {
+			return "", "", fmt.Errorf("login credentials: $%s is not set", env.name)
+		}
+	}
+	return username, password, nil
+}
+
+// BuildForm builds the request submitting the login form of doc, the login page
+// at page, filled in like a browser would with username and password
+func (c *LoginConfig) BuildForm(doc *goquery.Document, page *url.URL, username, password string) (*LoginForm, error) {
+	var form *goquery.Selection
+	if c.Form != "" {
+		form = doc.Find(c.Form).First()
+	} else {
+		form = doc.Find(`form:has(input[type="password"])`).First()
+	}
+	if form.Length() == 0 {
+		return nil, fmt.Errorf("no login form on %s", page)
+	}
+
+	action, err := page.Parse(strings.TrimSpace(form.AttrOr("action", "")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid login form action: %w", err)
+	}
+	action.Fragment = ""
+	login := &LoginForm{
+		Method: strings.ToUpper(form.AttrOr("method", http.MethodPost)),
+		Action: action,
+		Values: formValues(form),
+		Header: make(http.Header),
+	}
+	if login.Method != http.MethodGet {
+		login.Method = http.MethodPost
+	}
+
+	login.Values.Set(orDefault(c.UsernameField, DefaultUsernameField), username)
+	login.Values.Set(orDefault(c.PasswordField, DefaultPasswordField), password)
+	for name, value := range c.Fields {
+		login.Values.Set(name, value)
+	}
+	if c.CSRF.Selector != "" {
+		token := c.CSRF.Extract(&colly.HTMLElement{DOM: doc.Selection})
+		if token == "" {
+			return nil, fmt.Errorf("no CSRF token matching %q on %s", c.CSRF.Selector, page)
+		}
+		if c.CSRFField != "" {
+			login.Values.Set(c.CSRFField, token)
+		}
+		if c.CSRFHeader != "" {
+			login.Header.Set(c.CSRFHeader, token)
+		}
+	}
+	return login, nil
+}
+
+// formValues returns the values a browser submits for form before the user
+// types anything: its hidden and prefilled fields, checked boxes and
+// selected options
+func formValues(form *goquery.Selection) url.Values {
+	values := make(url.Values)
+	form.Find("input, select, textarea").Each(func(_ int, field *goquery.Selection) {
+		name, ok := field.Attr("name")
+		if !ok || name == "" {
+			return
+		}
+		if _, disabled := field.Attr("disabled"); disabled {
+			return
+		}
+
+		switch goquery.NodeName(field) {
+		case "select":
+			option := field.Find("option[selected]").First()
+			if option.Length() == 0 {
+				option = field.Find("option").First()
+			}
+			if option.Length() > 0 {
+				values.Add(name, option.AttrOr("value", strings.TrimSpace(option.Text())))
+			}
+		case "textarea":
+			values.Add(name, field.Text())
+		default:
+			switch strings.ToLower(field.AttrOr("type", "text")) {
+			case "submit", "button", "image", "reset", "file":
+			case "checkbox", "radio":
+				if _, checked := field.Attr("checked"); checked {
+					values.Add(name, field.AttrOr("value", "on"))
+				}
+			default:
+				values.Add(name, field.AttrOr("value", ""))
+			}
+		}
+	})
+	return values
+}
+
+// orDefault returns value, or fallback when it is empty
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
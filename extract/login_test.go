@@ -0,0 +1,131 @@
+package extract
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const loginPage = `<html><head><meta name="csrf-token" content="meta-token"></head><body>
+<form action="/search"><input name="q" value="shoes"></form>
+<form id="login" action="/account/login?return=%2F#top" method="post">
+	<input type="hidden" name="authenticity_token" value="form-token">
+	<input type="email" name="username" value="prefilled">
+	<input type="password" name="password">
+	<input type="checkbox" name="remember" value="1" checked>
+	<input type="checkbox" name="newsletter" value="1">
+	<input type="text" name="legacy" value="x" disabled>
+	<select name="region"><option value="eu">EU</option><option value="us" selected>US</option></select>
+	<textarea name="note">hello</textarea>
+	<input type="submit" name="commit" value="Log in">
+</form></body></html>`
+
+func TestLoginConfigValidate(t *testing.T) {
+	valid := LoginConfig{URL: "/login", UsernameEnv: "USER", PasswordEnv: "PASS", Success: "a.logout"}
+	assert.NoError(t, valid.Validate())
+
+	missing := LoginConfig{URL: "/login"}
+	assert.ErrorContains(t, missing.Validate(), "login.username_env, login.password_env, login.success")
+
+	csrf := valid
+	csrf.CSRF = FieldSelector{Selector: "meta[name=csrf-token]", Attr: "content"}
+	assert.ErrorContains(t, csrf.Validate(), "csrf_field or login.csrf_header")
+	csrf.CSRFHeader = "X-CSRF-Token"
+	assert.NoError(t, csrf.Validate())
+
+	profile := DefaultSiteProfile()
+	profile.Login = &missing
+	assert.Error(t, profile.Validate())
+}
+
+func TestLoginConfigCredentials(t *testing.T) {
+	cfg := LoginConfig{UsernameEnv: "TEST_LOGIN_USER", PasswordEnv: "TEST_LOGIN_PASS"}
+	t.Setenv("TEST_LOGIN_USER", "buyer")
+	t.Setenv("TEST_LOGIN_PASS", "")
+	_, _, err := cfg.Credentials()
+	assert.ErrorContains(t, err, "$TEST_LOGIN_PASS is not set")
+
+	t.Setenv("TEST_LOGIN_PASS", "s3cret")
+	username, password, err := cfg.Credentials()
+	require.NoError(t, err)
+	assert.Equal(t, "buyer", username)
+	assert.Equal(t, "s3cret", password)
+}
+
+func TestLoginConfigBuildForm(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(loginPage))
+	require.NoError(t, err)
+	page, err := url.Parse("https://b2b.example.com/login")
+	require.NoError(t, err)
+
+	t.Run("fills in the form with a password field", func(t *testing.T) {
+		cfg := LoginConfig{Fields: map[string]string{"region": "eu"}}
+		form, err := cfg.BuildForm(doc, page, "buyer", "s3cret")
+		require.NoError(t, err)
+
+		assert.Equal(t, http.MethodPost, form.Method)
+		assert.Equal(t, "https://b2b.example.com/account/login?return=%2F", form.Action.String())
+		assert.Equal(t, url.Values{
+			"authenticity_token": {"form-token"},
+			"username":           {"buyer"},
+			"password":           {"s3cret"},
+			"remember":           {"1"},
+			"region":             {"eu"},
+			"note":               {"hello"},
+		}, form.Values)
+		assert.Empty(t, form.Header)
+	})
+
+	t.Run("sends a CSRF token from outside the form", func(t *testing.T) {
+		cfg := LoginConfig{
+			Form:          "form#login",
+			UsernameField: "email",
+			CSRF:          FieldSelector{Selector: `meta[name="csrf-token"]`, Attr: "content"},
+			CSRFField:     "authenticity_token",
+			CSRFHeader:    "X-CSRF-Token",
+		}
+		form, err := cfg.BuildForm(doc, page, "buyer", "s3cret")
+		require.NoError(t, err)
+		assert.Equal(t, "meta-token", form.Values.Get("authenticity_token"))
+		assert.Equal(t, "meta-token", form.Header.Get("X-CSRF-Token"))
+		assert.Equal(t, "buyer", form.Values.Get("email"))
+		assert.Equal(t, "us", form.Values.Get("region"))
+	})
+
+	t.Run("fails without a login form or token", func(t *testing.T) {
+		_, err := (&LoginConfig{Form: "form#signin"}).BuildForm(doc, page, "buyer", "s3cret")
+		assert.ErrorContains(t, err, "no login form")
+
+		cfg := LoginConfig{CSRF: FieldSelector{Selector: "input[name=_csrf]", Attr: "value"}, CSRFField: "_csrf"}
+		_, err = cfg.BuildForm(doc, page, "buyer", "s3cret")
+		assert.ErrorContains(t, err, "no CSRF token")
+	})
+}
+
+func TestLoadSiteProfileWithLogin(t *testing.T) {
+	profile, err := LoadSiteProfile(writeProfile(t, "b2b.yaml", shopProfileYAML+`
+login:
+  url: /account/login
+  username_env: B2B_USER
+  password_env: B2B_PASSWORD
+  csrf:
+    selector: meta[name="csrf-token"]
+    attr: content
+  csrf_header: X-CSRF-Token
+  success: a.logout
+  expired: form#login
+`))
+	require.NoError(t, err)
+	require.NotNil(t, profile.Login)
+	assert.Equal(t, "/account/login", profile.Login.URL)
+	assert.Equal(t, "content", profile.Login.CSRF.Attr)
+	assert.Equal(t, "form#login", profile.Login.Expired)
+
+	_, err = LoadSiteProfile(writeProfile(t, "broken.yaml", shopProfileYAML+"login:\n  url: /login\n"))
+	assert.ErrorContains(t, err, "missing login settings")
+}
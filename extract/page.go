@@ -0,0 +1,134 @@
+package extract
+
+import (
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+)
+
+// Page is the metadata of a crawled page
+type Page struct {
+	URL         string      `json:"url"`
+	StatusCode  int         `json:"status_code"`
+	ContentType string      `json:"content_type"`
+	Size        int         `json:"size"` // response body in bytes
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Canonical   string      `json:"canonical,omitempty"`
+	Language    string      `json:"language,omitempty"`
+	OpenGraph   OpenGraph   `json:"open_graph"`
+	Twitter     TwitterCard `json:"twitter"`
+	Error       string      `json:"error,omitempty"` // why the page could not be fetched
+	CrawledAt   time.Time   `json:"crawled_at"`
+}
+
+// OpenGraph holds the og: meta properties of a page
+type OpenGraph struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Image       string `json:"image,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// TwitterCard holds the twitter: meta tags of a page
+type TwitterCard struct {
+	Card        string `json:"card,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Site        string `json:"site,omitempty"`
+}
+
+// ReadPage builds the page record of resp. doc is the parsed page, nil for
+// responses that are not HTML, which only get the response fields. URLs are
+// canonicalized with urls.
+func ReadPage(resp *colly.Response, doc *goquery.Selection, urls URLPolicy) Page {
+	page := Page{
+		URL:        urls.Resolve(resp.Request, resp.Request.URL.String()),
+		StatusCode: resp.StatusCode,
+		Size:       len(resp.Body),
+		CrawledAt:  time.Now(),
+	}
+	if resp.Headers != nil {
+		page.ContentType = resp.Headers.Get("Content-Type")
+		page.Language = resp.Headers.Get("Content-Language")
+	}
+	if doc == nil {
+		return page
+	}
+
+	root := doc.Closest("html")
+	if root.Length() == 0 {
+		root = doc.Find("html").First()
+	}
+	if lang := strings.TrimSpace(root.AttrOr("lang", "")); lang != "" {
+		page.Language = lang
+	}
+
+	page.Title = collapseSpace(doc.Find("title").First().Text())
+	if canonical, ok := doc.Find(`link[rel~="canonical"]`).First().Attr("href"); ok {
+		page.Canonical = urls.Resolve(resp.Request, canonical)
+	}
+
+	doc.Find("meta").Each(func(_ int, meta *goquery.Selection) {
+		content := strings.TrimSpace(meta.AttrOr("content", ""))
+		if content == "" {
+			return
+		}
+		if httpEquiv := meta.AttrOr("http-equiv", ""); strings.EqualFold(httpEquiv, "content-language") && page.Language == "" {
+			page.Language = content
+			return
+		}
+		// OpenGraph uses property and Twitter name, but sites mix them up
+		key := strings.ToLower(meta.AttrOr("property", meta.AttrOr("name", "")))
+		page.setMeta(key, content, func(ref string) string { return urls.Resolve(resp.Request, ref) })
+	})
+
+	return page
+}
+
+// setMeta records the meta tag key if it is one a Page keeps, with resolve
+// turning URLs absolute. The first occurrence of a tag wins.
+func (p *Page) setMeta(key, content string, resolve func(ref string) string) {
+	var field *string
+	switch key {
+	case "description":
+		field = &p.Description
+	case "og:title":
+		field = &p.OpenGraph.Title
+	case "og:description":
+		field = &p.OpenGraph.Description
+	case "og:type":
+		field = &p.OpenGraph.Type
+	case "og:url":
+		field, content = &p.OpenGraph.URL, resolve(content)
+	case "og:image", "og:image:url":
+		field, content = &p.OpenGraph.Image, resolve(content)
+	case "og:site_name":
+		field = &p.OpenGraph.SiteName
+	case "twitter:card":
+		field = &p.Twitter.Card
+	case "twitter:title":
+		field = &p.Twitter.Title
+	case "twitter:description":
+		field = &p.Twitter.Description
+	case "twitter:image", "twitter:image:src":
+		field, content = &p.Twitter.Image, resolve(content)
+	case "twitter:site":
+		field = &p.Twitter.Site
+	default:
+		return
+	}
+	if *field == "" {
+		*field = content
+	}
+}
+
+// collapseSpace trims s and collapses its runs of whitespace into one space
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
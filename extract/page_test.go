@@ -0,0 +1,80 @@
+package extract
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+)
+
+// pageResponse returns a response for pageURL with body and headers
+func pageResponse(t *testing.T, pageURL, body string, headers http.Header) *colly.Response {
+	t.Helper()
+	parsed, err := url.Parse(pageURL)
+	require.NoError(t, err)
+	return &colly.Response{
+		StatusCode: http.StatusOK,
+		Body:       []byte(body),
+		Request:    &colly.Request{URL: parsed},
+		Headers:    &headers,
+	}
+}
+
+func TestReadPage(t *testing.T) {
+	t.Run("reads the metadata of an HTML page", func(t *testing.T) {
+		body := testutil.MustGetFixture(t, "page_meta.html")
+		resp := pageResponse(t, "http://shop.example.com/sale?utm=x", body,
+			http.Header{"Content-Type": {"text/html; charset=utf-8"}})
+
+		page := ReadPage(resp, parseDocument(t, body).Selection, URLPolicy{})
+		assert.Equal(t, "http://shop.example.com/sale?utm=x", page.URL)
+		assert.Equal(t, http.StatusOK, page.StatusCode)
+		assert.Equal(t, "text/html; charset=utf-8", page.ContentType)
+		assert.Equal(t, len(body), page.Size)
+		assert.Equal(t, "Summer Sale", page.Title)
+		assert.Equal(t, "Everything half price this week.", page.Description)
+		assert.Equal(t, "http://shop.example.com/sale", page.Canonical)
+		assert.Equal(t, "en-GB", page.Language)
+		assert.Equal(t, OpenGraph{
+			Title:       "Summer Sale at Acme",
+			Description: "Half price this week",
+			Type:        "website",
+			URL:         "http://shop.example.com/sale",
+			Image:       "http://shop.example.com/images/sale.jpg",
+			SiteName:    "Acme",
+		}, page.OpenGraph)
+		assert.Equal(t, TwitterCard{
+			Card:        "summary_large_image",
+			Title:       "Summer Sale",
+			Description: "Half price",
+			Image:       "https://cdn.example.com/sale.jpg",
+			Site:        "@acme",
+		}, page.Twitter)
+		assert.False(t, page.CrawledAt.IsZero())
+	})
+
+	t.Run("falls back to the Content-Language header", func(t *testing.T) {
+		body := `<html><head><title>Bonjour</title></head></html>`
+		resp := pageResponse(t, "http://shop.example.com/", body,
+			http.Header{"Content-Type": {"text/html"}, "Content-Language": {"fr"}})
+
+		page := ReadPage(resp, parseDocument(t, body).Selection, URLPolicy{})
+		assert.Equal(t, "fr", page.Language)
+		assert.Empty(t, page.Canonical)
+		assert.Empty(t, page.OpenGraph)
+	})
+
+	t.Run("keeps only the response fields without a document", func(t *testing.T) {
+		resp := pageResponse(t, "http://shop.example.com/feed.xml", "<rss/>",
+			http.Header{"Content-Type": {"application/rss+xml"}})
+
+		page := ReadPage(resp, nil, URLPolicy{})
+		assert.Equal(t, "application/rss+xml", page.ContentType)
+		assert.Equal(t, 6, page.Size)
+		assert.Empty(t, page.Title)
+	})
+}
@@ -0,0 +1,395 @@
+package extract
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNoPrice is returned when a price string contains no amount
+var ErrNoPrice = errors.New("no price found")
+
+// Price is a parsed price. All amounts are integers in the minor unit of the
+// currency (cents for USD, yen for JPY).
+type Price struct {
+	Currency       string `json:"currency"`                  // ISO 4217 code, empty if unknown
+	Amount         int64  `json:"amount"`                    // current price, or range minimum
+	OriginalAmount int64  `json:"original_amount,omitempty"` // regular price when on sale
+	MaxAmount      int64  `json:"max_amount,omitempty"`      // range maximum
+}
+
+// OnSale reports whether the price was reduced from an original price
+func (p Price) OnSale() bool {
+	return p.OriginalAmount > p.Amount
+}
+
+// IsRange reports whether the price covers a range such as "$10 - $20"
+func (p Price) IsRange() bool {
+	return p.MaxAmount > p.Amount
+}
+
+// IsZero reports whether no price was parsed
+func (p Price) IsZero() bool {
+	return p == Price{}
+}
+
+// PriceFormat holds per-site hints for parsing prices
+type PriceFormat struct {
+	// DecimalSeparator forces "." or "," as the decimal separator.
+	// When empty the separator is inferred from the text.
+	DecimalSeparator string `yaml:"decimal_separator,omitempty" json:"decimal_separator,omitempty"`
+	// Currency is the ISO 4217 code used when the text has no currency marker
+	// or only an ambiguous symbol such as "$". Explicit ISO codes still win.
+	Currency string `yaml:"currency,omitempty" json:"currency,omitempty"`
+}
+
+// currencySymbols maps currency markers found in price text to ISO 4217 codes
+var currencySymbols = map[string]string{
+	"US$": "USD",
+	"A$":  "AUD",
+	"C$":  "CAD",
+	"NZ$": "NZD",
+	"R$":  "BRL",
+	"$":   "USD",
+	"€":   "EUR",
+	"£":   "GBP",
+	"¥":   "JPY",
+	"₹":   "INR",
+	"₽":   "RUB",
+	"₩":   "KRW",
+	"₺":   "TRY",
+	"zł":  "PLN",
+	"Kč":  "CZK",
+	"kr":  "SEK",
+	"Fr.": "CHF",
+}
+
+// currencyCodes lists the ISO 4217 codes recognised verbatim in price text
+var currencyCodes = map[string]bool{
+	"USD": true, "EUR": true, "GBP": true, "JPY": true, "CHF": true, "CAD": true,
+	"AUD": true, "NZD": true, "SEK": true, "NOK": true, "DKK": true, "PLN": true,
+	"CZK": true, "HUF": true, "INR": true, "CNY": true, "KRW": true, "BRL": true,
+	"MXN": true, "RUB": true, "TRY": true, "ZAR": true, "SGD": true, "HKD": true,
+	"BHD": true, "KWD": true, "OMR": true, "JOD": true, "TND": true, "ISK": true,
+	"CLP": true, "VND": true,
+}
+
+// currencyExponents lists currencies whose minor unit is not 1/100
+var currencyExponents = map[string]int{
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3,
+}
+
+// currencyMarkers holds the symbols sorted longest first so "US$" wins over "$"
+var currencyMarkers = func() []string {
+	markers := make([]string, 0, len(currencySymbols))
+	for marker := range currencySymbols {
+		markers = append(markers, marker)
+	}
+	sort.Slice(markers, func(i, j int) bool {
+		if len(markers[i]) != len(markers[j]) {
+			return len(markers[i]) > len(markers[j])
+		}
+		return markers[i] < markers[j]
+	})
+	return markers
+}()
+
+// amountPattern matches a number with optional thousand and decimal separators.
+// A plain space only separates thousands when exactly three digits follow, so
+// "1 234,50 €" is one amount while "$30 $20" stays two.
+var amountPattern = regexp.MustCompile(`\d(?:[\d.,'\x{00A0}\x{202F}]*\d)?(?: \d{3}\b(?:[\d.,'\x{00A0}\x{202F}]*\d)?)*`)
+
+// priceToken is a single amount found in price text
+type priceToken struct {
+	number string
+	marker string // currency symbol or ISO code next to the amount
+	start  int
+	end    int
+}
+
+// ParsePrice parses price text such as "$19.99", "19,99 €", "$30 $20" or
+// "£10 – £20", inferring the decimal separator from the text
+func ParsePrice(text string) (Price, error) {
+	return PriceFormat{}.Parse(text)
+}
+
+// Parse parses price text using the hints in f.
+//
+// Two amounts separated by a dash or "to" are a range. Two amounts without a
+// separator are treated as an original price followed by a sale price, which
+// is how WooCommerce renders <del>/<ins> pairs.
+func (f PriceFormat) Parse(text string) (Price, error) {
+	text = CleanPrice(text)
+	tokens := findPriceTokens(text)
+	if len(tokens) == 0 {
+		return Price{}, ErrNoPrice
+	}
+
+	currency := f.Currency
+	for _, token := range tokens {
+		if token.marker == "" {
+			continue
+		}
+		if f.Currency == "" || currencyCodes[token.marker] {
+			currency = markerCurrency(token.marker)
+		}
+		break
+	}
+
+	amounts := make([]int64, 0, len(tokens))
+	for _, token := range tokens {
+		amount, err := parseAmount(token.number, f.DecimalSeparator, currencyExponent(currency))
+		if err != nil {
+			return Price{}, err
+		}
+		amounts = append(amounts, amount)
+	}
+
+	price := Price{Currency: currency, Amount: amounts[0]}
+	switch {
+	case len(amounts) == 1:
+	case len(amounts) == 2 && !isRangeSeparator(text[tokens[0].end:tokens[1].start]) && amounts[1] < amounts[0]:
+		price.OriginalAmount = amounts[0]
+		price.Amount = amounts[1]
+	default:
+		for _, amount := range amounts {
+			if amount < price.Amount {
+				price.Amount = amount
+			}
+			if amount > price.MaxAmount {
+				price.MaxAmount = amount
+			}
+		}
+	}
+
+	return price, nil
+}
+
+// findPriceTokens locates the amounts in text along with their currency markers.
+// Percentages are skipped, and when any amount carries a currency marker the
+// amounts without one are dropped as unrelated numbers.
+func findPriceTokens(text string) []priceToken {
+	matches := amountPattern.FindAllStringIndex(text, -1)
+
+	var tokens []priceToken
+	hasCurrency := false
+	prevEnd := 0
+	for i, m := range matches {
+		nextStart := len(text)
+		if i+1 < len(matches) {
+			nextStart = matches[i+1][0]
+		}
+
+		before := strings.TrimRight(text[prevEnd:m[0]], " \u00a0")
+		after := strings.TrimLeft(text[m[1]:nextStart], " \u00a0")
+		prevEnd = m[1]
+
+		if strings.HasPrefix(after, "%") {
+			continue
+		}
+
+		// Prefer a marker before the amount so "$30 $20" doesn't give the
+		// second dollar sign to the first amount
+		marker := markerSuffix(before)
+		if marker == "" {
+			marker = markerPrefix(after)
+		}
+		if marker != "" {
+			hasCurrency = true
+		}
+
+		tokens = append(tokens, priceToken{number: text[m[0]:m[1]], marker: marker, start: m[0], end: m[1]})
+	}
+
+	if !hasCurrency {
+		return tokens
+	}
+
+	filtered := tokens[:0]
+	for _, token := range tokens {
+		if token.marker != "" {
+			filtered = append(filtered, token)
+		}
+	}
+	return filtered
+}
+
+// markerCurrency maps a currency marker to its ISO 4217 code
+func markerCurrency(marker string) string {
+	if currencyCodes[marker] {
+		return marker
+	}
+	return currencySymbols[marker]
+}
+
+// markerSuffix returns the currency marker that ends s
+func markerSuffix(s string) string {
+	if len(s) >= 3 {
+		code := s[len(s)-3:]
+		if currencyCodes[code] && (len(s) == 3 || !isLetter(s[len(s)-4])) {
+			return code
+		}
+	}
+	for _, marker := range currencyMarkers {
+		if strings.HasSuffix(s, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// markerPrefix returns the currency marker that starts s
+func markerPrefix(s string) string {
+	if len(s) >= 3 {
+		code := s[:3]
+		if currencyCodes[code] && (len(s) == 3 || !isLetter(s[3])) {
+			return code
+		}
+	}
+	for _, marker := range currencyMarkers {
+		if strings.HasPrefix(s, marker) {
+			return marker
+		}
+	}
+	return ""
+}
+
+// isLetter reports whether b is an ASCII letter
+func isLetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// isRangeSeparator reports whether the text between two amounts marks a range
+func isRangeSeparator(between string) bool {
+	between = strings.ToLower(between)
+	return strings.ContainsAny(between, "-–—") || strings.Contains(between, " to ")
+}
+
+// currencyExponent returns the number of minor unit digits for currency
+func currencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+// parseAmount converts a number such as "1.234,56" into minor units.
+//
+// Without a decimalSep hint the last of "." and "," is the decimal separator
+// when both appear. A single separator is a thousands separator when it is
+// repeated or followed by exactly three digits, unless the currency has three
+// minor unit digits, and a decimal one otherwise.
+func parseAmount(number, decimalSep string, exponent int) (int64, error) {
+	number = strings.NewReplacer("'", "", " ", "", "\u00a0", "", "\u202f", "").Replace(number)
+
+	if decimalSep == "" {
+		decimalSep = inferDecimalSeparator(number, exponent)
+	}
+
+	intPart, fracPart := number, ""
+	if decimalSep != "" {
+		if i := strings.LastIndex(number, decimalSep); i >= 0 {
+			intPart, fracPart = number[:i], number[i+1:]
+		}
+	}
+	intPart = strings.NewReplacer(".", "", ",", "").Replace(intPart)
+
+	if intPart == "" {
+		intPart = "0"
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, fmt.Errorf("invalid price amount %q", number)
+	}
+
+	major, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid price amount %q: %w", number, err)
+	}
+
+	// Pad or round the fraction to the currency's minor unit
+	roundUp := false
+	if len(fracPart) > exponent {
+		roundUp = fracPart[exponent] >= '5'
+		fracPart = fracPart[:exponent]
+	}
+	fracPart += strings.Repeat("0", exponent-len(fracPart))
+
+	minor := int64(0)
+	if fracPart != "" {
+		minor, _ = strconv.ParseInt(fracPart, 10, 64)
+	}
+
+	amount := major*pow10(exponent) + minor
+	if roundUp {
+		amount++
+	}
+	return amount, nil
+}
+
+// inferDecimalSeparator guesses the decimal separator used in number for a
+// currency with exponent minor unit digits
+func inferDecimalSeparator(number string, exponent int) string {
+	lastDot := strings.LastIndex(number, ".")
+	lastComma := strings.LastIndex(number, ",")
+
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastDot > lastComma {
+			return "."
+		}
+		return ","
+	case lastDot >= 0:
+		return singleSeparator(number, ".", lastDot, exponent)
+	case lastComma >= 0:
+		return singleSeparator(number, ",", lastComma, exponent)
+	default:
+		return ""
+	}
+}
+
+// singleSeparator decides whether sep, the only separator kind in number, is decimal
+func singleSeparator(number, sep string, last, exponent int) string {
+	if strings.Count(number, sep) > 1 || (len(number)-last-1 == 3 && exponent != 3) {
+		return ""
+	}
+	return sep
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// pow10 returns 10 to the power of n
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
+
+// FormatAmount renders minor units as a decimal string, e.g. 1999 USD as "19.99"
+func FormatAmount(amount int64, currency string) string {
+	exponent := currencyExponent(currency)
+	if exponent == 0 {
+		return strconv.FormatInt(amount, 10)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	scale := pow10(exponent)
+	return fmt.Sprintf("%s%d.%0*d", sign, amount/scale, exponent, amount%scale)
+}
@@ -0,0 +1,199 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Price
+	}{
+		{
+			name:     "dollar amount",
+			input:    "$19.99",
+			expected: Price{Currency: "USD", Amount: 1999},
+		},
+		{
+			name:     "euro with comma decimal and trailing symbol",
+			input:    "19,99 €",
+			expected: Price{Currency: "EUR", Amount: 1999},
+		},
+		{
+			name:     "thousands separators in US format",
+			input:    "$1,234.56",
+			expected: Price{Currency: "USD", Amount: 123456},
+		},
+		{
+			name:     "thousands separators in German format",
+			input:    "1.234,56 €",
+			expected: Price{Currency: "EUR", Amount: 123456},
+		},
+		{
+			name:     "single separator followed by three digits is thousands",
+			input:    "€1.234",
+			expected: Price{Currency: "EUR", Amount: 123400},
+		},
+		{
+			name:     "Swiss apostrophe separators",
+			input:    "CHF 1'234.50",
+			expected: Price{Currency: "CHF", Amount: 123450},
+		},
+		{
+			name:     "non-breaking space thousands separator",
+			input:    "1 234,50 €",
+			expected: Price{Currency: "EUR", Amount: 123450},
+		},
+		{
+			name:     "space thousands separator",
+			input:    "1 234,50 €",
+			expected: Price{Currency: "EUR", Amount: 123450},
+		},
+		{
+			name:     "space thousands separators in Swedish format",
+			input:    "12 345 678,90 kr",
+			expected: Price{Currency: "SEK", Amount: 1234567890},
+		},
+		{
+			name:     "sale price with space thousands separators",
+			input:    "1 500 € 1 200 €",
+			expected: Price{Currency: "EUR", Amount: 120000, OriginalAmount: 150000},
+		},
+		{
+			name:     "three decimal currency",
+			input:    "BHD 1.500",
+			expected: Price{Currency: "BHD", Amount: 1500},
+		},
+		{
+			name:     "three decimal currency with thousands",
+			input:    "KWD 12,345.250",
+			expected: Price{Currency: "KWD", Amount: 12345250},
+		},
+		{
+			name:     "ISO code suffix",
+			input:    "49.00 GBP",
+			expected: Price{Currency: "GBP", Amount: 4900},
+		},
+		{
+			name:     "whole amount",
+			input:    "$20",
+			expected: Price{Currency: "USD", Amount: 2000},
+		},
+		{
+			name:     "zero decimal currency",
+			input:    "¥1,500",
+			expected: Price{Currency: "JPY", Amount: 1500},
+		},
+		{
+			name:     "multi-character symbol",
+			input:    "US$ 5.00",
+			expected: Price{Currency: "USD", Amount: 500},
+		},
+		{
+			name:     "sale price",
+			input:    "$30 $20",
+			expected: Price{Currency: "USD", Amount: 2000, OriginalAmount: 3000},
+		},
+		{
+			name:     "WooCommerce sale without whitespace",
+			input:    "$30.00$20.00",
+			expected: Price{Currency: "USD", Amount: 2000, OriginalAmount: 3000},
+		},
+		{
+			name:     "sale price with discount badge",
+			input:    "$30.00 $20.00 Save 33%",
+			expected: Price{Currency: "USD", Amount: 2000, OriginalAmount: 3000},
+		},
+		{
+			name:     "range with hyphen",
+			input:    "$19.99  -  $29.99",
+			expected: Price{Currency: "USD", Amount: 1999, MaxAmount: 2999},
+		},
+		{
+			name:     "range with en dash and trailing symbols",
+			input:    "10,00 € – 20,00 €",
+			expected: Price{Currency: "EUR", Amount: 1000, MaxAmount: 2000},
+		},
+		{
+			name:     "range with to",
+			input:    "£5 to £8.50",
+			expected: Price{Currency: "GBP", Amount: 500, MaxAmount: 850},
+		},
+		{
+			name:     "no currency marker",
+			input:    "12.50",
+			expected: Price{Amount: 1250},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			price, err := ParsePrice(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, price)
+		})
+	}
+}
+
+func TestParsePriceErrors(t *testing.T) {
+	for _, input := range []string{"", "Call for price", "   "} {
+		_, err := ParsePrice(input)
+		assert.ErrorIs(t, err, ErrNoPrice, "input %q", input)
+	}
+}
+
+func TestPriceFormat(t *testing.T) {
+	t.Run("decimal separator hint", func(t *testing.T) {
+		price, err := PriceFormat{DecimalSeparator: ","}.Parse("€1,234")
+		require.NoError(t, err)
+		assert.Equal(t, int64(123), price.Amount)
+	})
+
+	t.Run("rounds extra decimals", func(t *testing.T) {
+		price, err := PriceFormat{DecimalSeparator: "."}.Parse("$0.125")
+		require.NoError(t, err)
+		assert.Equal(t, int64(13), price.Amount)
+	})
+
+	t.Run("default currency for bare amounts", func(t *testing.T) {
+		price, err := PriceFormat{Currency: "EUR"}.Parse("12,50")
+		require.NoError(t, err)
+		assert.Equal(t, Price{Currency: "EUR", Amount: 1250}, price)
+	})
+
+	t.Run("currency overrides ambiguous symbols", func(t *testing.T) {
+		price, err := PriceFormat{Currency: "CAD"}.Parse("$12.50")
+		require.NoError(t, err)
+		assert.Equal(t, "CAD", price.Currency)
+	})
+
+	t.Run("explicit ISO code wins over configured currency", func(t *testing.T) {
+		price, err := PriceFormat{Currency: "CAD"}.Parse("USD 12.50")
+		require.NoError(t, err)
+		assert.Equal(t, "USD", price.Currency)
+	})
+}
+
+func TestPriceHelpers(t *testing.T) {
+	sale := Price{Currency: "USD", Amount: 2000, OriginalAmount: 3000}
+	assert.True(t, sale.OnSale())
+	assert.False(t, sale.IsRange())
+
+	rng := Price{Currency: "USD", Amount: 1000, MaxAmount: 2000}
+	assert.True(t, rng.IsRange())
+	assert.False(t, rng.OnSale())
+
+	assert.True(t, Price{}.IsZero())
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "19.99", FormatAmount(1999, "USD"))
+	assert.Equal(t, "0.05", FormatAmount(5, "EUR"))
+	assert.Equal(t, "1500", FormatAmount(1500, "JPY"))
+	assert.Equal(t, "1.250", FormatAmount(1250, "KWD"))
+	assert.Equal(t, "-3.50", FormatAmount(-350, ""))
+}
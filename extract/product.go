@@ -0,0 +1,103 @@
+// Package extract holds the scraped product types and turns pages into them
+// using site profiles and price parsing.
+package extract
+
+import (
+	"strings"
+	"time"
+)
+
+// Product represents a scraped product item
+type Product struct {
+	URL       string    `json:"url"`
+	Image     string    `json:"image_url"`
+	Name      string    `json:"name"`
+	Price     string    `json:"price"`
+	Pricing   Price     `json:"pricing"`          // structured form of Price
+	Images    []Image   `json:"images,omitempty"` // the downloaded Image, when images are downloaded
+	ScrapedAt time.Time `json:"scraped_at"`
+}
+
+// ProductDetail represents detailed product information
+type ProductDetail struct {
+	URL         string    `json:"url"`
+	Name        string    `json:"name"`
+	Price       string    `json:"price"`
+	Pricing     Price     `json:"pricing"`
+	Description string    `json:"description"`
+	SKU         string    `json:"sku"`
+	Category    string    `json:"category"`
+	ImageURL    string    `json:"image_url"`
+	InStock     bool      `json:"in_stock"`
+	Brand       string    `json:"brand,omitempty"`
+	GTIN        string    `json:"gtin,omitempty"`
+	Rating      float64   `json:"rating,omitempty"`       // average review rating
+	ReviewCount int       `json:"review_count,omitempty"` // number of reviews or ratings
+	Variants    []Variant `json:"variants,omitempty"`     // size, color and other options of a variable product
+	Images      []Image   `json:"images,omitempty"`       // every image of the product, the main one first
+	ScrapedAt   time.Time `json:"scraped_at"`
+}
+
+// Image is a product image and, once downloaded, the file it is stored in
+type Image struct {
+	URL    string `json:"url"`
+	Path   string `json:"path,omitempty"` // local file named by the SHA-256 of the content
+	SHA256 string `json:"sha256,omitempty"`
+	MIME   string `json:"mime,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Size   int    `json:"size,omitempty"` // bytes
+}
+
+// ImageURLs returns the URLs of the product's images
+func (p *ProductDetail) ImageURLs() []string {
+	urls := make([]string, len(p.Images))
+	for i, image := range p.Images {
+		urls[i] = image.URL
+	}
+	return urls
+}
+
+// SetImages records the downloaded images, in the order of ImageURLs, and
+// points ImageURL and the variants' images at the local files
+func (p *ProductDetail) SetImages(images []Image) {
+	p.Images = images
+
+	paths := make(map[string]string, len(images))
+	for _, image := range images {
+		if image.Path != "" {
+			paths[image.URL] = image.Path
+		}
+	}
+	if path, ok := paths[p.ImageURL]; ok {
+		p.ImageURL = path
+	}
+	for i := range p.Variants {
+		if path, ok := paths[p.Variants[i].ImageURL]; ok {
+			p.Variants[i].ImageURL = path
+		}
+	}
+}
+
+// SetImages records the downloaded Image and points Image at its local file
+func (p *Product) SetImages(images []Image) {
+	p.Images = images
+	if len(images) > 0 && images[0].Path != "" {
+		p.Image = images[0].Path
+	}
+}
+
+// CleanPrice removes extra whitespace and normalizes price strings
+func CleanPrice(price string) string {
+	// Remove extra whitespace and newlines
+	price = strings.TrimSpace(price)
+	price = strings.ReplaceAll(price, "\n", " ")
+	price = strings.ReplaceAll(price, "\t", "")
+
+	// Collapse multiple spaces into one
+	for strings.Contains(price, "  ") {
+		price = strings.ReplaceAll(price, "  ", " ")
+	}
+
+	return price
+}
@@ -0,0 +1,179 @@
+package extract
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanPrice(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "removes leading and trailing whitespace",
+			input:    "  $19.99  ",
+			expected: "$19.99",
+		},
+		{
+			name:     "handles newlines",
+			input:    "$19.99\n$29.99",
+			expected: "$19.99 $29.99",
+		},
+		{
+			name:     "handles tabs",
+			input:    "$19.99\t\tUSD",
+			expected: "$19.99USD",
+		},
+		{
+			name:     "collapses multiple spaces",
+			input:    "$19.99    USD",
+			expected: "$19.99 USD",
+		},
+		{
+			name:     "handles empty string",
+			input:    "",
+			expected: "",
+		},
+		{
+			name:     "handles whitespace only",
+			input:    "   \n\t  ",
+			expected: "",
+		},
+		{
+			name:     "handles complex formatting",
+			input:    "\n\t  $19.99  -  $29.99  \n",
+			expected: "$19.99 - $29.99",
+		},
+		{
+			name:     "preserves single space",
+			input:    "$19.99 USD",
+			expected: "$19.99 USD",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CleanPrice(tt.input)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestCleanPriceEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "unicode characters",
+			input:    "€19.99",
+			expected: "€19.99",
+		},
+		{
+			name:     "multiple newlines",
+			input:    "\n\n\n$19.99\n\n",
+			expected: "$19.99",
+		},
+		{
+			name:     "mixed whitespace types",
+			input:    " \t\n$19.99 \n\t ",
+			expected: "$19.99",
+		},
+		{
+			name:     "price range",
+			input:    "$19.99  -  $29.99",
+			expected: "$19.99 - $29.99",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := CleanPrice(tt.input)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestProductStruct(t *testing.T) {
+	t.Run("creates product with all fields", func(t *testing.T) {
+		now := time.Now()
+		product := Product{
+			URL:       "http://example.com/product",
+			Image:     "http://example.com/image.jpg",
+			Name:      "Test Product",
+			Price:     "$19.99",
+			ScrapedAt: now,
+		}
+
+		assert.Equal(t, "http://example.com/product", product.URL)
+		assert.Equal(t, "http://example.com/image.jpg", product.Image)
+		assert.Equal(t, "Test Product", product.Name)
+		assert.Equal(t, "$19.99", product.Price)
+		assert.Equal(t, now, product.ScrapedAt)
+	})
+
+	t.Run("handles empty fields", func(t *testing.T) {
+		product := Product{}
+
+		assert.Equal(t, "", product.URL)
+		assert.Equal(t, "", product.Image)
+		assert.Equal(t, "", product.Name)
+		assert.Equal(t, "", product.Price)
+		assert.True(t, product.ScrapedAt.IsZero())
+	})
+}
+
+func TestProductDetailStruct(t *testing.T) {
+	t.Run("creates ProductDetail with all fields", func(t *testing.T) {
+		now := time.Now()
+		product := ProductDetail{
+			URL:         "http://example.com/product",
+			Name:        "Test Product",
+			Price:       "$19.99",
+			Description: "Test description",
+			SKU:         "TEST-123",
+			Category:    "Test Category",
+			ImageURL:    "http://example.com/image.jpg",
+			InStock:     true,
+			ScrapedAt:   now,
+		}
+
+		assert.Equal(t, "http://example.com/product", product.URL)
+		assert.Equal(t, "Test Product", product.Name)
+		assert.Equal(t, "$19.99", product.Price)
+		assert.Equal(t, "Test description", product.Description)
+		assert.Equal(t, "TEST-123", product.SKU)
+		assert.Equal(t, "Test Category", product.Category)
+		assert.Equal(t, "http://example.com/image.jpg", product.ImageURL)
+		assert.True(t, product.InStock)
+		assert.Equal(t, now, product.ScrapedAt)
+	})
+
+	t.Run("JSON marshaling", func(t *testing.T) {
+		product := ProductDetail{
+			Name:     "Test Product",
+			Price:    "$19.99",
+			InStock:  true,
+			Category: "Electronics",
+		}
+
+		data, err := json.Marshal(product)
+		require.NoError(t, err)
+
+		var decoded ProductDetail
+		err = json.Unmarshal(data, &decoded)
+		require.NoError(t, err)
+
+		assert.Equal(t, product.Name, decoded.Name)
+		assert.Equal(t, product.Price, decoded.Price)
+		assert.Equal(t, product.InStock, decoded.InStock)
+		assert.Equal(t, product.Category, decoded.Category)
+	})
+}
@@ -0,0 +1,193 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// SiteProfile describes the selectors used to scrape a particular shop
+type SiteProfile struct {
+	Name        string           `yaml:"name" json:"name"`
+	Listing     ListingSelectors `yaml:"listing" json:"listing"`
+	Detail      DetailSelectors  `yaml:"detail" json:"detail"`
+	PriceFormat PriceFormat      `yaml:"price_format,omitempty" json:"price_format,omitempty"`
+	URLs        URLPolicy        `yaml:"urls,omitempty" json:"urls,omitempty"`       // how product and image URLs are canonicalized
+	Headers     HeaderPolicy     `yaml:"headers,omitempty" json:"headers,omitempty"` // which browsers requests pose as
+	Login       *LoginConfig     `yaml:"login,omitempty" json:"login,omitempty"`     // nil scrapes without logging in
+}
+
+// ListingSelectors locate products on a category or listing page
+type ListingSelectors struct {
+	Item     string        `yaml:"item" json:"item"`
+	URL      FieldSelector `yaml:"url" json:"url"`
+	Image    FieldSelector `yaml:"image" json:"image"`
+	Name     FieldSelector `yaml:"name" json:"name"`
+	Price    FieldSelector `yaml:"price" json:"price"`
+	NextPage string        `yaml:"next_page" json:"next_page"`
+}
+
+// DetailSelectors locate product fields on a product detail page
+type DetailSelectors struct {
+	Root            string        `yaml:"root" json:"root"`
+	Name            FieldSelector `yaml:"name" json:"name"`
+	Price           FieldSelector `yaml:"price" json:"price"`
+	Description     FieldSelector `yaml:"description" json:"description"`
+	SKU             FieldSelector `yaml:"sku" json:"sku"`
+	Category        FieldSelector `yaml:"category" json:"category"`
+	Image           FieldSelector `yaml:"image" json:"image"`
+	Gallery         FieldSelector `yaml:"gallery,omitempty" json:"gallery,omitempty"`   // every product image, not just the main one
+	Stock           string        `yaml:"stock" json:"stock"`                           // element whose classes carry the stock status
+	OutOfStockClass string        `yaml:"out_of_stock_class" json:"out_of_stock_class"` // class marking the product unavailable
+	// IgnoreStructuredData skips the page's JSON-LD, microdata and RDFa and
+	// reads every field with the selectors
+	IgnoreStructuredData bool `yaml:"ignore_structured_data,omitempty" json:"ignore_structured_data,omitempty"`
+}
+
+// FieldSelector extracts a single value relative to a matched element.
+// The element text is used unless Attr names an attribute to read instead.
+type FieldSelector struct {
+	Selector string `yaml:"selector" json:"selector"`
+	Attr     string `yaml:"attr,omitempty" json:"attr,omitempty"`
+}
+
+// Extract returns the selected value from the children of e
+func (f FieldSelector) Extract(e *colly.HTMLElement) string {
+	if f.Selector == "" {
+		return ""
+	}
+	if f.Attr != "" {
+		return e.ChildAttr(f.Selector, f.Attr)
+	}
+	return e.ChildText(f.Selector)
+}
+
+// ExtractAll returns the values of every match in the children of e
+func (f FieldSelector) ExtractAll(e *colly.HTMLElement) []string {
+	if f.Selector == "" {
+		return nil
+	}
+	if f.Attr != "" {
+		return e.ChildAttrs(f.Selector, f.Attr)
+	}
+	return e.ChildTexts(f.Selector)
+}
+
+// InStock reports whether the stock element on e indicates availability. The
+// product is out of stock when OutOfStockClass is one of the element's classes.
+func (d DetailSelectors) InStock(e *colly.HTMLElement) bool {
+	if d.Stock == "" || d.OutOfStockClass == "" {
+		return true
+	}
+	for _, class := range strings.Fields(e.ChildAttr(d.Stock, "class")) {
+		if class == d.OutOfStockClass {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultSiteProfile returns the built-in WooCommerce profile
+func DefaultSiteProfile() *SiteProfile {
+	return &SiteProfile{
+		Name: "woocommerce",
+		Listing: ListingSelectors{
+			Item:     "li.product",
+			URL:      FieldSelector{Selector: "a.woocommerce-LoopProduct-link", Attr: "href"},
+			Image:    FieldSelector{Selector: "img.product-image", Attr: "src"},
+			Name:     FieldSelector{Selector: "h2.woocommerce-loop-product__title"},
+			Price:    FieldSelector{Selector: "span.price"},
+			NextPage: "a.next.page-numbers",
+		},
+		Detail: DetailSelectors{
+			Root:            "div.product",
+			Name:            FieldSelector{Selector: "h1.product_title"},
+			Price:           FieldSelector{Selector: "p.price span.woocommerce-Price-amount"},
+			Description:     FieldSelector{Selector: "div.woocommerce-product-details__short-description"},
+			SKU:             FieldSelector{Selector: "span.sku"},
+			Category:        FieldSelector{Selector: "span.posted_in a"},
+			Image:           FieldSelector{Selector: "img.wp-post-image", Attr: "src"},
+			Gallery:         FieldSelector{Selector: "div.woocommerce-product-gallery__image a", Attr: "href"},
+			Stock:           "p.stock",
+			OutOfStockClass: "out-of-stock",
+		},
+	}
+}
+
+// LoadSiteProfile reads a site profile from a YAML or JSON file.
+// Files ending in .json are decoded as JSON, everything else as YAML.
+func LoadSiteProfile(filename string) (*SiteProfile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %w", err)
+	}
+
+	profile := &SiteProfile{}
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(profile)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(profile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %w", filename, err)
+	}
+
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid profile %s: %w", filename, err)
+	}
+
+	return profile, nil
+}
+
+// ResolveSiteProfile returns the built-in profile for an empty or "woocommerce"
+// name and otherwise loads the named file
+func ResolveSiteProfile(nameOrPath string) (*SiteProfile, error) {
+	switch nameOrPath {
+	case "", "woocommerce":
+		return DefaultSiteProfile(), nil
+	default:
+		return LoadSiteProfile(nameOrPath)
+	}
+}
+
+// Validate checks that the selectors required by the scrapers are present
+func (p *SiteProfile) Validate() error {
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"listing.item", p.Listing.Item},
+		{"listing.url.selector", p.Listing.URL.Selector},
+		{"listing.name.selector", p.Listing.Name.Selector},
+		{"detail.root", p.Detail.Root},
+		{"detail.name.selector", p.Detail.Name.Selector},
+	} {
+		if strings.TrimSpace(field.value) == "" {
+			missing = append(missing, field.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.New("missing required selectors: " + strings.Join(missing, ", "))
+	}
+
+	if err := p.URLs.Validate(); err != nil {
+		return err
+	}
+	if err := p.Headers.Validate(); err != nil {
+		return err
+	}
+	if p.Login != nil {
+		return p.Login.Validate()
+	}
+	return nil
+}
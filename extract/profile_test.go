@@ -0,0 +1,109 @@
+package extract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProfile writes a profile file into a temp directory and returns its path
+func writeProfile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+	return filename
+}
+
+const shopProfileYAML = `
+name: simple-shop
+listing:
+  item: div.card
+  url:
+    selector: a.title
+    attr: href
+  name:
+    selector: a.title
+  price:
+    selector: span.cost
+detail:
+  root: article
+  name:
+    selector: h1
+`
+
+func TestDefaultSiteProfile(t *testing.T) {
+	t.Run("is valid", func(t *testing.T) {
+		assert.NoError(t, DefaultSiteProfile().Validate())
+	})
+
+	t.Run("matches the shipped YAML file", func(t *testing.T) {
+		profile, err := LoadSiteProfile(filepath.Join("..", "profiles", "woocommerce.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, DefaultSiteProfile(), profile)
+	})
+}
+
+func TestLoadSiteProfile(t *testing.T) {
+	t.Run("loads YAML", func(t *testing.T) {
+		profile, err := LoadSiteProfile(writeProfile(t, "shop.yaml", shopProfileYAML))
+		require.NoError(t, err)
+
+		assert.Equal(t, "simple-shop", profile.Name)
+		assert.Equal(t, "div.card", profile.Listing.Item)
+		assert.Equal(t, FieldSelector{Selector: "a.title", Attr: "href"}, profile.Listing.URL)
+		assert.Empty(t, profile.Listing.NextPage)
+	})
+
+	t.Run("loads JSON", func(t *testing.T) {
+		content := `{
+			"name": "json-shop",
+			"listing": {"item": "li", "url": {"selector": "a", "attr": "href"}, "name": {"selector": "a"}},
+			"detail": {"root": "main", "name": {"selector": "h1"}}
+		}`
+		profile, err := LoadSiteProfile(writeProfile(t, "shop.json", content))
+		require.NoError(t, err)
+
+		assert.Equal(t, "json-shop", profile.Name)
+		assert.Equal(t, "main", profile.Detail.Root)
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		_, err := LoadSiteProfile(writeProfile(t, "shop.yaml", shopProfileYAML+"\nlisting_typo: true\n"))
+		assert.Error(t, err)
+
+		_, err = LoadSiteProfile(writeProfile(t, "shop.json", `{"nmae": "typo"}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("reports missing required selectors", func(t *testing.T) {
+		_, err := LoadSiteProfile(writeProfile(t, "shop.yaml", "name: empty\n"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "listing.item")
+		assert.Contains(t, err.Error(), "detail.root")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadSiteProfile(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+func TestResolveSiteProfile(t *testing.T) {
+	t.Run("built-in names", func(t *testing.T) {
+		for _, name := range []string{"", "woocommerce"} {
+			profile, err := ResolveSiteProfile(name)
+			require.NoError(t, err)
+			assert.Equal(t, "woocommerce", profile.Name)
+		}
+	})
+
+	t.Run("file path", func(t *testing.T) {
+		profile, err := ResolveSiteProfile(writeProfile(t, "shop.yml", shopProfileYAML))
+		require.NoError(t, err)
+		assert.Equal(t, "simple-shop", profile.Name)
+	})
+}
@@ -0,0 +1,480 @@
+package extract
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// StructuredProduct is a schema.org Product found in the JSON-LD, microdata
+// or RDFa of a page
+type StructuredProduct struct {
+	Name         string
+	Description  string
+	SKU          string
+	GTIN         string
+	Brand        string
+	Category     string
+	Image        string
+	Images       []string // every image, Image first
+	Price        string   // offer price as text, e.g. "19.99 USD"
+	Pricing      Price
+	Availability string // schema.org availability without its URL, e.g. "InStock"
+	Rating       float64
+	ReviewCount  int
+	Variants     []Variant // the hasVariant products of a ProductGroup
+}
+
+// schemaNode is a schema.org item. Property names, without any vocabulary
+// prefix, map to strings, nested nodes or slices of them; "@type" holds the
+// item type.
+type schemaNode map[string]any
+
+// productTypes lists the schema.org types read as a product
+var productTypes = map[string]bool{
+	"Product":           true,
+	"IndividualProduct": true,
+	"ProductModel":      true,
+	"SomeProducts":      true,
+	"ProductGroup":      true,
+}
+
+// variantProperties are the schema.org properties a variant usually differs
+// by, read when a ProductGroup doesn't list them in variesBy
+var variantProperties = []string{"color", "size", "material", "pattern"}
+
+// inStockAvailability and outOfStockAvailability map the schema.org
+// availability values that clearly tell the stock. Others, such as PreOrder,
+// are left to the CSS selectors.
+var (
+	inStockAvailability = map[string]bool{
+		"InStock": true, "LimitedAvailability": true, "OnlineOnly": true, "InStoreOnly": true,
+	}
+	outOfStockAvailability = map[string]bool{
+		"OutOfStock": true, "SoldOut": true, "Discontinued": true,
+	}
+)
+
+// FindStructuredProduct returns the first product described by the JSON-LD,
+// microdata or RDFa in doc. JSON-LD wins; the other syntaxes fill in the
+// fields it lacks, in that order.
+func FindStructuredProduct(doc *goquery.Selection) (*StructuredProduct, bool) {
+	var found *StructuredProduct
+	for _, nodes := range [][]schemaNode{jsonLDNodes(doc), itemNodes(doc, microdata), itemNodes(doc, rdfa)} {
+		for _, node := range nodes {
+			product := findProductNode(node)
+			if product == nil {
+				continue
+			}
+			p := productFromNode(product)
+			if found == nil {
+				found = &p
+			} else {
+				found.fill(p)
+			}
+			break
+		}
+	}
+	return found, found != nil
+}
+
+// InStock reports whether the product is available, and false for ok when
+// its availability is missing or doesn't tell
+func (p *StructuredProduct) InStock() (inStock, ok bool) {
+	switch {
+	case inStockAvailability[p.Availability]:
+		return true, true
+	case outOfStockAvailability[p.Availability]:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+// fill copies the fields of other that p lacks
+func (p *StructuredProduct) fill(other StructuredProduct) {
+	for _, field := range []struct {
+		dst *string
+		src string
+	}{
+		{&p.Name, other.Name},
+		{&p.Description, other.Description},
+		{&p.SKU, other.SKU},
+		{&p.GTIN, other.GTIN},
+		{&p.Brand, other.Brand},
+		{&p.Category, other.Category},
+		{&p.Image, other.Image},
+		{&p.Availability, other.Availability},
+	} {
+		if *field.dst == "" {
+			*field.dst = field.src
+		}
+	}
+	if p.Pricing.IsZero() && p.Price == "" {
+		p.Price, p.Pricing = other.Price, other.Pricing
+	}
+	if len(p.Images) == 0 {
+		p.Images = other.Images
+	}
+	if p.Rating == 0 {
+		p.Rating = other.Rating
+	}
+	if p.ReviewCount == 0 {
+		p.ReviewCount = other.ReviewCount
+	}
+	if len(p.Variants) == 0 {
+		p.Variants = other.Variants
+	}
+}
+
+// findProductNode returns node or the first product nested in it, such as
+// one in an "@graph" or a page's "mainEntity"
+func findProductNode(node schemaNode) schemaNode {
+	if node.isA(productTypes) {
+		return node
+	}
+	names := make([]string, 0, len(node))
+	for name := range node {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, child := range nodeValues(node[name]) {
+			if product := findProductNode(child); product != nil {
+				return product
+			}
+		}
+	}
+	return nil
+}
+
+// productFromNode maps a schema.org Product onto a StructuredProduct
+func productFromNode(node schemaNode) StructuredProduct {
+	p := StructuredProduct{
+		Name:        node.text("name"),
+		Description: node.text("description"),
+		SKU:         node.text("sku"),
+		GTIN:        node.text("gtin13", "gtin14", "gtin12", "gtin8", "gtin"),
+		Brand:       node.text("brand", "manufacturer"),
+		Category:    node.text("category"),
+		Image:       node.text("image"),
+	}
+	for _, value := range asSlice(node["image"]) {
+		if image := valueText(value); image != "" {
+			p.Images = append(p.Images, image)
+		}
+	}
+
+	if offer := node.child("offers"); offer != nil {
+		p.Availability = schemaName(offer.text("availability"))
+		p.Price, p.Pricing = offerPrice(offer)
+	}
+
+	if rating := node.child("aggregateRating"); rating != nil {
+		p.Rating, _ = strconv.ParseFloat(rating.text("ratingValue"), 64)
+		p.ReviewCount, _ = strconv.Atoi(rating.text("reviewCount", "ratingCount"))
+	}
+
+	p.Variants = variantsFromNode(node)
+	return p
+}
+
+// variantsFromNode reads the hasVariant products of a ProductGroup. Their
+// attributes are the properties named by variesBy.
+func variantsFromNode(group schemaNode) []Variant {
+	nodes := nodeValues(group["hasVariant"])
+	if len(nodes) == 0 {
+		return nil
+	}
+
+	properties := variantProperties
+	if variesBy := asSlice(group["variesBy"]); len(variesBy) > 0 {
+		properties = nil
+		for _, value := range variesBy {
+			if name := schemaName(valueText(value)); name != "" {
+				properties = append(properties, name)
+			}
+		}
+	}
+
+	variants := make([]Variant, 0, len(nodes))
+	for _, node := range nodes {
+		v := Variant{
+			SKU:      node.text("sku"),
+			Name:     node.text("name"),
+			ImageURL: node.text("image"),
+		}
+		for _, property := range properties {
+			if value := node.text(property); value != "" {
+				v.setAttribute(property, value)
+			}
+		}
+		if offer := node.child("offers"); offer != nil {
+			v.Price, v.Pricing = offerPrice(offer)
+			v.InStock = inStockAvailability[schemaName(offer.text("availability"))]
+		}
+		variants = append(variants, v)
+	}
+	return variants
+}
+
+// plainAmount matches a machine readable amount such as "1299.00", which
+// schema.org prices always write with a dot
+var plainAmount = regexp.MustCompile(`^\d+(\.\d+)?$`)
+
+// offerPrice returns the price of an Offer, or the range of an AggregateOffer
+func offerPrice(offer schemaNode) (string, Price) {
+	spec := offer.child("priceSpecification")
+	currency := offer.text("priceCurrency")
+	if currency == "" && spec != nil {
+		currency = spec.text("priceCurrency")
+	}
+
+	amount := offer.text("price", "lowPrice")
+	if amount == "" && spec != nil {
+		amount = spec.text("price")
+	}
+	if amount == "" {
+		return "", Price{}
+	}
+
+	price, ok := parseOfferAmount(amount, currency)
+	if !ok {
+		return amount, Price{}
+	}
+	if high, ok := parseOfferAmount(offer.text("highPrice"), currency); ok && high.Amount > price.Amount {
+		price.MaxAmount = high.Amount
+	}
+
+	text := amount
+	if plainAmount.MatchString(amount) {
+		text = strings.TrimSpace(amount + " " + currency)
+	}
+	return text, price
+}
+
+// parseOfferAmount parses an offer amount in currency
+func parseOfferAmount(amount, currency string) (Price, bool) {
+	if amount == "" {
+		return Price{}, false
+	}
+	format := PriceFormat{Currency: strings.ToUpper(currency)}
+	if plainAmount.MatchString(amount) {
+		format.DecimalSeparator = "."
+	}
+	price, err := format.Parse(amount)
+	return price, err == nil
+}
+
+// isA reports whether the node has one of types
+func (n schemaNode) isA(types map[string]bool) bool {
+	for _, value := range asSlice(n["@type"]) {
+		typ, _ := value.(string)
+		for _, name := range strings.Fields(typ) {
+			if types[schemaName(name)] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// text returns the first non-empty text of the named properties. Nested
+// nodes give their name, or their url for images.
+func (n schemaNode) text(names ...string) string {
+	for _, name := range names {
+		for _, value := range asSlice(n[name]) {
+			if text := valueText(value); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
+
+// child returns the first node of the named property
+func (n schemaNode) child(name string) schemaNode {
+	if nodes := nodeValues(n[name]); len(nodes) > 0 {
+		return nodes[0]
+	}
+	return nil
+}
+
+// add appends a property value, keeping repeated properties as a slice
+func (n schemaNode) add(name string, value any) {
+	existing, ok := n[name]
+	if !ok {
+		n[name] = value
+		return
+	}
+	n[name] = append(asSlice(existing), value)
+}
+
+// valueText returns the text of a property value
+func valueText(value any) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	case schemaNode:
+		return v.text("name", "@value", "url", "contentUrl")
+	default:
+		return ""
+	}
+}
+
+// nodeValues returns the nodes among a property's values
+func nodeValues(value any) []schemaNode {
+	var nodes []schemaNode
+	for _, v := range asSlice(value) {
+		if node, ok := v.(schemaNode); ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// asSlice returns the values of a property that may or may not repeat
+func asSlice(value any) []any {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	default:
+		return []any{v}
+	}
+}
+
+// schemaName strips the vocabulary from a type or property name, so
+// "https://schema.org/InStock" and "schema:InStock" both become "InStock"
+func schemaName(name string) string {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "@") {
+		return name
+	}
+	if i := strings.LastIndexAny(name, "/#:"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// jsonLDNodes returns the top-level nodes of the JSON-LD scripts in doc.
+// Scripts that are not valid JSON are skipped.
+func jsonLDNodes(doc *goquery.Selection) []schemaNode {
+	var nodes []schemaNode
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		decoder := json.NewDecoder(strings.NewReader(s.Text()))
+		decoder.UseNumber()
+		var data any
+		if err := decoder.Decode(&data); err != nil {
+			return
+		}
+		for _, value := range asSlice(normalizeJSONLD(data)) {
+			if node, ok := value.(schemaNode); ok {
+				nodes = append(nodes, node)
+			}
+		}
+	})
+	return nodes
+}
+
+// normalizeJSONLD turns decoded JSON objects into schemaNodes with plain
+// property names
+func normalizeJSONLD(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		node := make(schemaNode, len(v))
+		for name, child := range v {
+			node[schemaName(name)] = normalizeJSONLD(child)
+		}
+		return node
+	case []any:
+		values := make([]any, len(v))
+		for i, child := range v {
+			values[i] = normalizeJSONLD(child)
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// itemSyntax names the attributes of an HTML item syntax
+type itemSyntax struct {
+	scope    string // attribute starting an item
+	itemType string // attribute holding the item type
+	property string // attribute naming a property
+}
+
+var (
+	microdata = itemSyntax{scope: "itemscope", itemType: "itemtype", property: "itemprop"}
+	rdfa      = itemSyntax{scope: "typeof", itemType: "typeof", property: "property"}
+)
+
+// itemNodes returns every item in doc written in syntax, in document order
+func itemNodes(doc *goquery.Selection, syntax itemSyntax) []schemaNode {
+	var nodes []schemaNode
+	doc.Find("[" + syntax.scope + "]").Each(func(_ int, s *goquery.Selection) {
+		nodes = append(nodes, parseItem(s, syntax))
+	})
+	return nodes
+}
+
+// parseItem reads the properties of the item started by s. Properties of
+// items nested in it belong to those.
+func parseItem(s *goquery.Selection, syntax itemSyntax) schemaNode {
+	node := schemaNode{"@type": s.AttrOr(syntax.itemType, "")}
+	scope := s.Get(0)
+
+	s.Find("[" + syntax.property + "]").Each(func(_ int, prop *goquery.Selection) {
+		owner := prop.Parent().Closest("[" + syntax.scope + "]")
+		if owner.Length() == 0 || owner.Get(0) != scope {
+			return
+		}
+
+		var value any
+		if _, nested := prop.Attr(syntax.scope); nested {
+			value = parseItem(prop, syntax)
+		} else {
+			value = propertyValue(prop)
+		}
+		for _, name := range strings.Fields(prop.AttrOr(syntax.property, "")) {
+			node.add(schemaName(name), value)
+		}
+	})
+	return node
+}
+
+// propertyValue returns the value of a property element: its content
+// attribute, the URL or value attribute of elements that have one, or its text
+func propertyValue(s *goquery.Selection) string {
+	if content, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+
+	attr := ""
+	switch goquery.NodeName(s) {
+	case "a", "link", "area":
+		attr = "href"
+	case "img", "audio", "video", "source", "iframe", "embed", "track":
+		attr = "src"
+	case "object":
+		attr = "data"
+	case "data", "meter":
+		attr = "value"
+	case "time":
+		attr = "datetime"
+	}
+	if value, ok := s.Attr(attr); ok && attr != "" {
+		return strings.TrimSpace(value)
+	}
+	return collapseSpace(s.Text())
+}
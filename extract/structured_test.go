@@ -0,0 +1,185 @@
+package extract
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+)
+
+// parseDocument parses page into a goquery document
+func parseDocument(t *testing.T, page string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	require.NoError(t, err)
+	return doc
+}
+
+// detailElement returns the element the scraper hands to ExtractDetail for
+// the first match of root in page, as fetched from pageURL
+func detailElement(t *testing.T, page, root, pageURL string) *colly.HTMLElement {
+	t.Helper()
+	parsed, err := url.Parse(pageURL)
+	require.NoError(t, err)
+
+	selection := parseDocument(t, page).Find(root).First()
+	require.Equal(t, 1, selection.Length())
+	resp := &colly.Response{Request: &colly.Request{URL: parsed}}
+	return colly.NewHTMLElementFromSelectionNode(resp, selection, selection.Get(0), 0)
+}
+
+func TestFindStructuredProduct(t *testing.T) {
+	t.Run("JSON-LD in a graph", func(t *testing.T) {
+		doc := parseDocument(t, testutil.MustGetFixture(t, "product_jsonld.html"))
+
+		product, ok := FindStructuredProduct(doc.Selection)
+		require.True(t, ok)
+		assert.Equal(t, "Structured Product", product.Name)
+		assert.Equal(t, "Described by JSON-LD.", product.Description)
+		assert.Equal(t, "/images/structured.jpg", product.Image)
+		assert.Equal(t, "4006381333931", product.GTIN)
+		assert.Equal(t, "Acme", product.Brand)
+		assert.Equal(t, "Gadgets", product.Category)
+		assert.Equal(t, "1299.00 EUR", product.Price)
+		assert.Equal(t, Price{Currency: "EUR", Amount: 129900}, product.Pricing)
+		assert.Equal(t, "OutOfStock", product.Availability)
+		assert.Equal(t, 4.5, product.Rating)
+		assert.Equal(t, 27, product.ReviewCount)
+		assert.Empty(t, product.SKU)
+	})
+
+	t.Run("microdata", func(t *testing.T) {
+		doc := parseDocument(t, testutil.MustGetFixture(t, "product_microdata.html"))
+
+		product, ok := FindStructuredProduct(doc.Selection)
+		require.True(t, ok)
+		assert.Equal(t, "Microdata Product", product.Name)
+		assert.Equal(t, "MD-001", product.SKU)
+		assert.Equal(t, "0012345678905", product.GTIN)
+		assert.Equal(t, "Globex", product.Brand)
+		assert.Equal(t, "/images/microdata.jpg", product.Image)
+		assert.Equal(t, Price{Currency: "EUR", Amount: 2450}, product.Pricing)
+		assert.Equal(t, "InStock", product.Availability)
+		assert.Equal(t, 3.8, product.Rating)
+		assert.Equal(t, 12, product.ReviewCount)
+	})
+
+	t.Run("RDFa aggregate offer", func(t *testing.T) {
+		doc := parseDocument(t, testutil.MustGetFixture(t, "product_rdfa.html"))
+
+		product, ok := FindStructuredProduct(doc.Selection)
+		require.True(t, ok)
+		assert.Equal(t, "RDFa Product", product.Name)
+		assert.Equal(t, "Described by RDFa.", product.Description)
+		assert.Equal(t, "RDFA-001", product.SKU)
+		assert.Equal(t, Price{Currency: "GBP", Amount: 1000, MaxAmount: 1500}, product.Pricing)
+
+		_, known := product.InStock()
+		assert.False(t, known, "PreOrder doesn't tell the stock")
+	})
+
+	t.Run("syntaxes fill each other's gaps", func(t *testing.T) {
+		doc := parseDocument(t, `<html><head>
+			<script type="application/ld+json">{"@type": "schema:Product", "name": "From JSON-LD",
+				"offers": [{"@type": "Offer", "price": 5, "priceCurrency": "USD"}]}</script>
+			</head><body><div itemscope itemtype="https://schema.org/Product">
+				<span itemprop="name">From microdata</span><span itemprop="sku">MD-9</span>
+			</div></body></html>`)
+
+		product, ok := FindStructuredProduct(doc.Selection)
+		require.True(t, ok)
+		assert.Equal(t, "From JSON-LD", product.Name)
+		assert.Equal(t, "MD-9", product.SKU)
+		assert.Equal(t, "5 USD", product.Price)
+		assert.Equal(t, int64(500), product.Pricing.Amount)
+	})
+
+	t.Run("pages without products", func(t *testing.T) {
+		doc := parseDocument(t, testutil.MustGetFixture(t, "product.html"))
+		_, ok := FindStructuredProduct(doc.Selection)
+		assert.False(t, ok)
+
+		doc = parseDocument(t, `<script type="application/ld+json">{"@type": "Organization", "name": "Shop"}</script>`)
+		_, ok = FindStructuredProduct(doc.Selection)
+		assert.False(t, ok)
+	})
+}
+
+func TestStructuredProductInStock(t *testing.T) {
+	tests := []struct {
+		availability string
+		inStock, ok  bool
+	}{
+		{"InStock", true, true},
+		{"LimitedAvailability", true, true},
+		{"OutOfStock", false, true},
+		{"Discontinued", false, true},
+		{"BackOrder", false, false},
+		{"", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.availability, func(t *testing.T) {
+			inStock, ok := (&StructuredProduct{Availability: tt.availability}).InStock()
+			assert.Equal(t, tt.inStock, inStock)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestExtractDetail(t *testing.T) {
+	profile := DefaultSiteProfile()
+
+	t.Run("prefers structured data and falls back to selectors", func(t *testing.T) {
+		e := detailElement(t, testutil.MustGetFixture(t, "product_jsonld.html"), profile.Detail.Root, "https://shop.example/product/structured")
+
+		product := profile.ExtractDetail(e)
+		assert.Equal(t, "https://shop.example/product/structured", product.URL)
+		assert.Equal(t, "Structured Product", product.Name)
+		assert.Equal(t, "1299.00 EUR", product.Price)
+		assert.Equal(t, int64(129900), product.Pricing.Amount)
+		assert.False(t, product.InStock)
+		assert.Equal(t, "Acme", product.Brand)
+		assert.Equal(t, "4006381333931", product.GTIN)
+		assert.Equal(t, "https://shop.example/images/structured.jpg", product.ImageURL)
+		assert.Equal(t, "CSS-SKU-001", product.SKU, "missing from the JSON-LD")
+		assert.Equal(t, "Gadgets", product.Category)
+		assert.False(t, product.ScrapedAt.IsZero())
+	})
+
+	t.Run("unknown availability falls back to the stock selector", func(t *testing.T) {
+		page := strings.Replace(testutil.MustGetFixture(t, "product_microdata.html"), "http://schema.org/InStock", "http://schema.org/PreOrder", 1)
+		product := profile.ExtractDetail(detailElement(t, page, profile.Detail.Root, "https://shop.example/p"))
+		assert.False(t, product.InStock)
+		assert.Equal(t, "MD-001", product.SKU)
+	})
+
+	t.Run("can ignore structured data", func(t *testing.T) {
+		ignoring := DefaultSiteProfile()
+		ignoring.Detail.IgnoreStructuredData = true
+		e := detailElement(t, testutil.MustGetFixture(t, "product_jsonld.html"), profile.Detail.Root, "https://shop.example/p")
+
+		product := ignoring.ExtractDetail(e)
+		assert.Equal(t, "Theme Title", product.Name)
+		assert.Equal(t, "$99.99", product.Price)
+		assert.True(t, product.InStock)
+		assert.Empty(t, product.Brand)
+	})
+
+	t.Run("pages without structured data use the selectors", func(t *testing.T) {
+		e := detailElement(t, testutil.MustGetFixture(t, "product.html"), profile.Detail.Root, "https://shop.example/p")
+
+		product := profile.ExtractDetail(e)
+		assert.Equal(t, "Detailed Test Product", product.Name)
+		assert.Equal(t, "$99.99", product.Price)
+		assert.Equal(t, Price{Currency: "USD", Amount: 9999}, product.Pricing)
+		assert.Equal(t, "TEST-SKU-001", product.SKU)
+		assert.Equal(t, "Test Category", product.Category)
+		assert.Equal(t, "https://shop.example/images/detailed-product.jpg", product.ImageURL)
+		assert.True(t, product.InStock)
+	})
+}
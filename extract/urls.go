@@ -0,0 +1,165 @@
+package extract
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gocolly/colly/v2"
+)
+
+// TrailingSlash is what a URLPolicy does with the slash at the end of a path
+type TrailingSlash string
+
+// Trailing slash policies
+const (
+	KeepTrailingSlash   TrailingSlash = ""      // leave paths as they are
+	StripTrailingSlash  TrailingSlash = "strip" // "/shop/" becomes "/shop"
+	AppendTrailingSlash TrailingSlash = "add"   // "/shop" becomes "/shop/", file names such as "/a.jpg" are left alone
+)
+
+// DefaultStripParams are the query parameters a URLPolicy removes unless
+// told otherwise: campaign tracking and session ids, which make one page look
+// like many. A trailing * matches any suffix.
+var DefaultStripParams = []string{
+	"utm_*", "gclid", "fbclid", "msclkid", "mc_cid", "mc_eid", "_ga",
+	"sessionid", "session_id", "sid", "phpsessid", "jsessionid",
+}
+
+// URLPolicy canonicalizes URLs, so the same page is recognised whatever link
+// led to it. Its zero value strips DefaultStripParams and keeps trailing
+// slashes.
+type URLPolicy struct {
+	// StripParams are removed from queries, and from path parameters such
+	// as ";jsessionid=". Names are case-insensitive and a trailing * matches
+	// any suffix. nil selects DefaultStripParams, an empty list strips none.
+	StripParams   []string      `yaml:"strip_params,omitempty" json:"strip_params,omitempty"`
+	TrailingSlash TrailingSlash `yaml:"trailing_slash,omitempty" json:"trailing_slash,omitempty"`
+}
+
+// Validate checks the trailing slash policy
+func (p URLPolicy) Validate() error {
+	switch p.TrailingSlash {
+	case KeepTrailingSlash, StripTrailingSlash, AppendTrailingSlash:
+		return nil
+	default:
+		return fmt.Errorf("unknown trailing_slash %q, expected %q or %q", p.TrailingSlash, StripTrailingSlash, AppendTrailingSlash)
+	}
+}
+
+// Canonicalize returns the canonical form of an absolute http or https URL:
+// lowercase scheme and host, no default port, "/" for an empty path, the
+// trailing slash policy applied, stripped parameters removed, the query
+// sorted by name and no fragment. It reports false for other URLs.
+func (p URLPolicy) Canonicalize(rawURL string) (string, bool) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", false
+	}
+	return p.canonical(u)
+}
+
+// Resolve returns ref resolved against the URL of r and canonicalized. Refs
+// that are not http or https URLs, such as data: URIs, are only resolved, and
+// an empty ref stays empty.
+func (p URLPolicy) Resolve(r *colly.Request, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	absolute := r.AbsoluteURL(ref)
+	if canonical, ok := p.Canonicalize(absolute); ok {
+		return canonical
+	}
+	return absolute
+}
+
+// canonical canonicalizes u in place and returns it as a string
+func (p URLPolicy) canonical(u *url.URL) (string, bool) {
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false
+	}
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]" // IPv6
+	}
+	if port != "" && !(u.Scheme == "http" && port == "80") && !(u.Scheme == "https" && port == "443") {
+		host += ":" + port
+	}
+	u.Host = host
+	u.Fragment, u.RawFragment = "", ""
+
+	strip := p.StripParams
+	if strip == nil {
+		strip = DefaultStripParams
+	}
+	canonicalPath := stripPathParams(u.Path, strip)
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	switch p.TrailingSlash {
+	case StripTrailingSlash:
+		if len(canonicalPath) > 1 {
+			canonicalPath = strings.TrimRight(canonicalPath, "/")
+		}
+	case AppendTrailingSlash:
+		if !strings.HasSuffix(canonicalPath, "/") && path.Ext(canonicalPath) == "" {
+			canonicalPath += "/"
+		}
+	}
+	if canonicalPath != u.Path {
+		u.Path, u.RawPath = canonicalPath, "" // keep the original escaping otherwise
+	}
+
+	// Queries that don't parse, e.g. with ";" separators, are left alone
+	if query, err := url.ParseQuery(u.RawQuery); err == nil && u.RawQuery != "" {
+		for name := range query {
+			if matchParam(name, strip) {
+				query.Del(name)
+			}
+		}
+		u.RawQuery = query.Encode() // sorted by name
+	}
+	u.ForceQuery = false
+	return u.String(), true
+}
+
+// stripPathParams removes the ";name=value" path parameters whose name is
+// stripped, such as the ";jsessionid=" of Java servers
+func stripPathParams(p string, strip []string) string {
+	if !strings.Contains(p, ";") {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		parts := strings.Split(segment, ";")
+		kept := parts[:1]
+		for _, param := range parts[1:] {
+			name, _, _ := strings.Cut(param, "=")
+			if !matchParam(name, strip) {
+				kept = append(kept, param)
+			}
+		}
+		segments[i] = strings.Join(kept, ";")
+	}
+	return strings.Join(segments, "/")
+}
+
+// matchParam reports whether the parameter name is one of strip
+func matchParam(name string, strip []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range strip {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
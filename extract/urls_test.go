@@ -0,0 +1,140 @@
+package extract
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   URLPolicy
+		input    string
+		expected string
+	}{
+		{
+			name:     "lowercases scheme and host",
+			input:    "HTTPS://Shop.Example.COM/Product/A",
+			expected: "https://shop.example.com/Product/A",
+		},
+		{
+			name:     "drops default ports",
+			input:    "http://shop.example:80/a",
+			expected: "http://shop.example/a",
+		},
+		{
+			name:     "keeps other ports",
+			input:    "https://shop.example:8443/a",
+			expected: "https://shop.example:8443/a",
+		},
+		{
+			name:     "adds a slash to an empty path",
+			input:    "https://shop.example",
+			expected: "https://shop.example/",
+		},
+		{
+			name:     "sorts query parameters",
+			input:    "https://shop.example/list?page=2&color=red&color=blue",
+			expected: "https://shop.example/list?color=red&color=blue&page=2",
+		},
+		{
+			name:     "strips tracking and session parameters",
+			input:    "https://shop.example/p?utm_source=mail&UTM_Medium=x&id=7&PHPSESSID=abc&gclid=1",
+			expected: "https://shop.example/p?id=7",
+		},
+		{
+			name:     "drops an emptied query",
+			input:    "https://shop.example/p?utm_campaign=spring",
+			expected: "https://shop.example/p",
+		},
+		{
+			name:     "strips session path parameters",
+			input:    "https://shop.example/cart;jsessionid=ABC123?x=1",
+			expected: "https://shop.example/cart?x=1",
+		},
+		{
+			name:     "drops the fragment",
+			input:    "https://shop.example/p#reviews",
+			expected: "https://shop.example/p",
+		},
+		{
+			name:     "keeps escaping",
+			input:    "https://shop.example/caf%C3%A9/a%2Fb",
+			expected: "https://shop.example/caf%C3%A9/a%2Fb",
+		},
+		{
+			name:     "custom strip list replaces the defaults",
+			policy:   URLPolicy{StripParams: []string{"ref", "aff_*"}},
+			input:    "https://shop.example/p?ref=home&aff_id=3&utm_source=x",
+			expected: "https://shop.example/p?utm_source=x",
+		},
+		{
+			name:     "empty strip list keeps every parameter",
+			policy:   URLPolicy{StripParams: []string{}},
+			input:    "https://shop.example/p?sid=1",
+			expected: "https://shop.example/p?sid=1",
+		},
+		{
+			name:     "strips trailing slashes",
+			policy:   URLPolicy{TrailingSlash: StripTrailingSlash},
+			input:    "https://shop.example/shop/",
+			expected: "https://shop.example/shop",
+		},
+		{
+			name:     "keeps the root slash when stripping",
+			policy:   URLPolicy{TrailingSlash: StripTrailingSlash},
+			input:    "https://shop.example/",
+			expected: "https://shop.example/",
+		},
+		{
+			name:     "appends trailing slashes",
+			policy:   URLPolicy{TrailingSlash: AppendTrailingSlash},
+			input:    "https://shop.example/shop?page=2",
+			expected: "https://shop.example/shop/?page=2",
+		},
+		{
+			name:     "does not append to file names",
+			policy:   URLPolicy{TrailingSlash: AppendTrailingSlash},
+			input:    "https://shop.example/images/a.jpg",
+			expected: "https://shop.example/images/a.jpg",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			canonical, ok := tt.policy.Canonicalize(tt.input)
+			require.True(t, ok)
+			assert.Equal(t, tt.expected, canonical)
+		})
+	}
+
+	t.Run("rejects non-http URLs", func(t *testing.T) {
+		for _, input := range []string{"mailto:shop@example.com", "javascript:void(0)", "/relative", "ftp://shop.example/a"} {
+			_, ok := URLPolicy{}.Canonicalize(input)
+			assert.False(t, ok, input)
+		}
+	})
+}
+
+func TestResolve(t *testing.T) {
+	base, err := url.Parse("https://Shop.Example/category/shoes/?page=2")
+	require.NoError(t, err)
+	r := &colly.Request{URL: base}
+	policy := URLPolicy{}
+
+	assert.Equal(t, "https://shop.example/product/test-product-1", policy.Resolve(r, "/product/test-product-1"))
+	assert.Equal(t, "https://shop.example/category/shoes/boot", policy.Resolve(r, "boot#top"))
+	assert.Equal(t, "https://cdn.example/a.jpg", policy.Resolve(r, "//cdn.example/a.jpg?utm_source=x"))
+	assert.Equal(t, "data:image/png;base64,AAAA", policy.Resolve(r, "data:image/png;base64,AAAA"))
+	assert.Equal(t, "", policy.Resolve(r, "  "))
+}
+
+func TestURLPolicyValidate(t *testing.T) {
+	assert.NoError(t, URLPolicy{}.Validate())
+	assert.NoError(t, URLPolicy{TrailingSlash: AppendTrailingSlash}.Validate())
+	assert.Error(t, URLPolicy{TrailingSlash: "remove"}.Validate())
+}
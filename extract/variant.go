@@ -0,0 +1,243 @@
+package extract
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Variant is one purchasable option of a product, such as a size and color
+// combination, with its own SKU, price and stock
+type Variant struct {
+	ID         string            `json:"id,omitempty"`
+	SKU        string            `json:"sku,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"` // option name to value, e.g. "Size": "Large"
+	Price      string            `json:"price,omitempty"`
+	Pricing    Price             `json:"pricing"`
+	InStock    bool              `json:"in_stock"`
+	ImageURL   string            `json:"image_url,omitempty"`
+}
+
+// setAttribute records the value of the option name
+func (v *Variant) setAttribute(name, value string) {
+	if v.Attributes == nil {
+		v.Attributes = make(map[string]string)
+	}
+	v.Attributes[name] = value
+}
+
+// FindVariants returns the variants described by the e-commerce platform
+// markup in doc: the data-product_variations of a WooCommerce variations
+// form, or the product JSON embedded by Shopify themes. currency is used for
+// prices that don't carry one. Variants in schema.org data are read by
+// FindStructuredProduct instead.
+func FindVariants(doc *goquery.Selection, currency string) []Variant {
+	if variants := wooCommerceVariants(doc, currency); len(variants) > 0 {
+		return variants
+	}
+	return shopifyVariants(doc, currency)
+}
+
+// wooVariation is an entry of WooCommerce's data-product_variations
+type wooVariation struct {
+	ID           json.Number       `json:"variation_id"`
+	SKU          string            `json:"sku"`
+	Attributes   map[string]string `json:"attributes"` // "attribute_pa_size" to the option value, empty for any
+	Price        json.Number       `json:"display_price"`
+	RegularPrice json.Number       `json:"display_regular_price"`
+	PriceHTML    string            `json:"price_html"`
+	InStock      bool              `json:"is_in_stock"`
+	Image        struct {
+		FullSrc string `json:"full_src"`
+		Src     string `json:"src"`
+	} `json:"image"`
+}
+
+// wooCommerceVariants reads the variations form of a WooCommerce variable
+// product. The form's select boxes give the option labels. Shops with many
+// variations load them on demand and leave the attribute "false"; those
+// yield nothing.
+func wooCommerceVariants(doc *goquery.Selection, currency string) []Variant {
+	form := doc.Find("form.variations_form[data-product_variations]").First()
+	if form.Length() == 0 {
+		return nil
+	}
+	var variations []wooVariation
+	decoder := json.NewDecoder(strings.NewReader(form.AttrOr("data-product_variations", "")))
+	decoder.UseNumber()
+	if err := decoder.Decode(&variations); err != nil {
+		return nil
+	}
+
+	labels := wooAttributeLabels(form)
+	variants := make([]Variant, 0, len(variations))
+	for _, wv := range variations {
+		v := Variant{
+			ID:       wv.ID.String(),
+			SKU:      strings.TrimSpace(wv.SKU),
+			InStock:  wv.InStock,
+			ImageURL: wv.Image.FullSrc,
+		}
+		if v.ImageURL == "" {
+			v.ImageURL = wv.Image.Src
+		}
+
+		for attribute, value := range wv.Attributes {
+			if value == "" {
+				continue // any value of the attribute matches
+			}
+			label, ok := labels[attribute]
+			if !ok {
+				label = wooAttributeLabel{name: strings.TrimPrefix(strings.TrimPrefix(attribute, "attribute_"), "pa_")}
+			}
+			if text, ok := label.options[value]; ok {
+				value = text
+			}
+			v.setAttribute(label.name, value)
+		}
+
+		if price, ok := parseOfferAmount(wv.Price.String(), currency); ok {
+			if regular, ok := parseOfferAmount(wv.RegularPrice.String(), currency); ok && regular.Amount > price.Amount {
+				price.OriginalAmount = regular.Amount
+			}
+			v.Pricing = price
+		}
+		if html, err := goquery.NewDocumentFromReader(strings.NewReader(wv.PriceHTML)); err == nil {
+			v.Price = collapseSpace(html.Text())
+		}
+		if v.Price == "" {
+			v.Price = variantPriceText(v.Pricing)
+		}
+
+		variants = append(variants, v)
+	}
+	return variants
+}
+
+// wooAttributeLabel is the label of an attribute select box and the text of
+// its options by value
+type wooAttributeLabel struct {
+	name    string
+	options map[string]string
+}
+
+// wooAttributeLabels reads the select boxes of a variations form, keyed by
+// the attribute name used in data-product_variations
+func wooAttributeLabels(form *goquery.Selection) map[string]wooAttributeLabel {
+	labels := make(map[string]wooAttributeLabel)
+	form.Find("select[data-attribute_name], select[name^='attribute_']").Each(func(_ int, sel *goquery.Selection) {
+		attribute := sel.AttrOr("data-attribute_name", sel.AttrOr("name", ""))
+		label := wooAttributeLabel{options: make(map[string]string)}
+		if id, ok := sel.Attr("id"); ok && id != "" {
+			label.name = collapseSpace(form.Find(`label[for="` + id + `"]`).First().Text())
+		}
+		if label.name == "" {
+			label.name = strings.TrimPrefix(strings.TrimPrefix(attribute, "attribute_"), "pa_")
+		}
+		sel.Find("option").Each(func(_ int, option *goquery.Selection) {
+			if value := option.AttrOr("value", ""); value != "" {
+				label.options[value] = collapseSpace(option.Text())
+			}
+		})
+		labels[attribute] = label
+	})
+	return labels
+}
+
+// shopifyProduct is the product JSON Shopify themes embed in the page
+type shopifyProduct struct {
+	Options  []json.RawMessage `json:"options"` // names, or objects with a name
+	Variants []struct {
+		ID             json.Number `json:"id"`
+		Title          string      `json:"title"`
+		SKU            string      `json:"sku"`
+		Option1        *string     `json:"option1"`
+		Option2        *string     `json:"option2"`
+		Option3        *string     `json:"option3"`
+		Price          json.Number `json:"price"` // cents, or a decimal string
+		CompareAtPrice json.Number `json:"compare_at_price"`
+		Available      bool        `json:"available"`
+		FeaturedImage  *struct {
+			Src string `json:"src"`
+		} `json:"featured_image"`
+	} `json:"variants"`
+}
+
+// shopifyVariants reads the variants of the product JSON of a Shopify theme
+func shopifyVariants(doc *goquery.Selection, currency string) []Variant {
+	var product shopifyProduct
+	found := false
+	doc.Find(`script[data-product-json], script[type="application/json"][id^="ProductJson"]`).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		decoder := json.NewDecoder(strings.NewReader(s.Text()))
+		decoder.UseNumber()
+		found = decoder.Decode(&product) == nil && len(product.Variants) > 0
+		return !found
+	})
+	if !found {
+		return nil
+	}
+
+	names := make([]string, len(product.Options))
+	for i, raw := range product.Options {
+		if json.Unmarshal(raw, &names[i]) != nil {
+			var option struct{ Name string }
+			json.Unmarshal(raw, &option)
+			names[i] = option.Name
+		}
+	}
+
+	variants := make([]Variant, 0, len(product.Variants))
+	for _, sv := range product.Variants {
+		v := Variant{
+			ID:      sv.ID.String(),
+			SKU:     strings.TrimSpace(sv.SKU),
+			Name:    strings.TrimSpace(sv.Title),
+			InStock: sv.Available,
+		}
+		if sv.FeaturedImage != nil {
+			v.ImageURL = sv.FeaturedImage.Src
+		}
+		for i, value := range []*string{sv.Option1, sv.Option2, sv.Option3} {
+			if value == nil || i >= len(names) || names[i] == "" {
+				continue
+			}
+			v.setAttribute(names[i], *value)
+		}
+
+		if price, ok := shopifyAmount(sv.Price, currency); ok {
+			if compareAt, ok := shopifyAmount(sv.CompareAtPrice, currency); ok && compareAt.Amount > price.Amount {
+				price.OriginalAmount = compareAt.Amount
+			}
+			v.Pricing = price
+			v.Price = variantPriceText(price)
+		}
+		variants = append(variants, v)
+	}
+	return variants
+}
+
+// shopifyAmount parses a Shopify price: an integer in cents whatever the
+// currency, or a decimal string in the product JSON of some themes
+func shopifyAmount(amount json.Number, currency string) (Price, bool) {
+	text := amount.String()
+	if strings.Contains(text, ".") {
+		return parseOfferAmount(text, currency)
+	}
+	cents, err := amount.Int64()
+	if err != nil {
+		return Price{}, false
+	}
+	currency = strings.ToUpper(currency)
+	minor := cents * pow10(currencyExponent(currency)) / 100
+	return Price{Currency: currency, Amount: minor}, true
+}
+
+// variantPriceText renders a parsed price as text, e.g. "19.99 USD"
+func variantPriceText(p Price) string {
+	if p.IsZero() {
+		return ""
+	}
+	return strings.TrimSpace(FormatAmount(p.Amount, p.Currency) + " " + p.Currency)
+}
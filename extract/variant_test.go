@@ -0,0 +1,138 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/internal/testutil"
+)
+
+func TestFindVariants(t *testing.T) {
+	t.Run("WooCommerce variations form", func(t *testing.T) {
+		doc := parseDocument(t, testutil.MustGetFixture(t, "product_variable.html"))
+
+		variants := FindVariants(doc.Selection, "USD")
+		require.Len(t, variants, 2)
+		assert.Equal(t, Variant{
+			ID:         "43",
+			SKU:        "HOODIE-S-BLUE",
+			Attributes: map[string]string{"Size": "Small", "Color": "Ocean Blue"},
+			Price:      "$40.00",
+			Pricing:    Price{Currency: "USD", Amount: 4000},
+			InStock:    true,
+			ImageURL:   "/images/hoodie-blue.jpg",
+		}, variants[0])
+		assert.Equal(t, Variant{
+			ID:         "44",
+			SKU:        "HOODIE-L",
+			Attributes: map[string]string{"Size": "Large"},
+			Price:      "36.50 USD",
+			Pricing:    Price{Currency: "USD", Amount: 3650, OriginalAmount: 4500},
+			ImageURL:   "/images/hoodie-large.jpg",
+		}, variants[1])
+	})
+
+	t.Run("WooCommerce variations loaded on demand", func(t *testing.T) {
+		doc := parseDocument(t, `<form class="variations_form" data-product_variations="false">
+			<select name="attribute_size"><option value="s">S</option></select></form>`)
+		assert.Empty(t, FindVariants(doc.Selection, "USD"))
+	})
+
+	t.Run("Shopify product JSON", func(t *testing.T) {
+		doc := parseDocument(t, testutil.MustGetFixture(t, "product_shopify.html"))
+
+		variants := FindVariants(doc.Selection, "EUR")
+		require.Len(t, variants, 2)
+		assert.Equal(t, Variant{
+			ID:         "9001",
+			SKU:        "SNK-42-W",
+			Name:       "42 / White",
+			Attributes: map[string]string{"Size": "42", "Color": "White"},
+			Price:      "59.00 EUR",
+			Pricing:    Price{Currency: "EUR", Amount: 5900, OriginalAmount: 7900},
+			InStock:    true,
+			ImageURL:   "//cdn.example.com/sneaker-white.jpg",
+		}, variants[0])
+		assert.False(t, variants[1].InStock)
+		assert.Empty(t, variants[1].ImageURL)
+	})
+
+	t.Run("Shopify cents in a currency without minor units", func(t *testing.T) {
+		doc := parseDocument(t, `<script type="application/json" data-product-json>
+			{"options": [{"name": "Size"}], "variants": [{"id": 1, "option1": "M", "price": 350000, "available": true}]}</script>`)
+
+		variants := FindVariants(doc.Selection, "JPY")
+		require.Len(t, variants, 1)
+		assert.Equal(t, Price{Currency: "JPY", Amount: 3500}, variants[0].Pricing)
+		assert.Equal(t, map[string]string{"Size": "M"}, variants[0].Attributes)
+	})
+
+	t.Run("pages without variants", func(t *testing.T) {
+		doc := parseDocument(t, testutil.MustGetFixture(t, "product.html"))
+		assert.Empty(t, FindVariants(doc.Selection, "USD"))
+	})
+}
+
+func TestStructuredVariants(t *testing.T) {
+	doc := parseDocument(t, `<html><head><script type="application/ld+json">{
+		"@context": "https://schema.org", "@type": "ProductGroup", "name": "Wool Scarf",
+		"variesBy": ["https://schema.org/color"],
+		"hasVariant": [
+			{"@type": "Product", "sku": "SCARF-GRY", "name": "Wool Scarf grey", "color": "Grey", "size": "One size",
+			 "offers": {"@type": "Offer", "price": "25.00", "priceCurrency": "GBP", "availability": "https://schema.org/InStock"}},
+			{"@type": "Product", "sku": "SCARF-RED", "color": "Red",
+			 "offers": {"@type": "Offer", "price": "25.00", "priceCurrency": "GBP", "availability": "https://schema.org/SoldOut"}}
+		]}</script></head><body></body></html>`)
+
+	product, ok := FindStructuredProduct(doc.Selection)
+	require.True(t, ok)
+	assert.Equal(t, "Wool Scarf", product.Name)
+	require.Len(t, product.Variants, 2)
+	assert.Equal(t, Variant{
+		SKU:        "SCARF-GRY",
+		Name:       "Wool Scarf grey",
+		Attributes: map[string]string{"color": "Grey"},
+		Price:      "25.00 GBP",
+		Pricing:    Price{Currency: "GBP", Amount: 2500},
+		InStock:    true,
+	}, product.Variants[0])
+	assert.False(t, product.Variants[1].InStock)
+}
+
+func TestExtractDetailVariants(t *testing.T) {
+	profile := DefaultSiteProfile()
+	e := detailElement(t, testutil.MustGetFixture(t, "product_variable.html"), profile.Detail.Root, "https://shop.example/product/hoodie")
+
+	product := profile.ExtractDetail(e)
+	assert.Equal(t, "Variable Hoodie", product.Name)
+	require.Len(t, product.Variants, 2)
+	assert.Equal(t, "USD", product.Variants[0].Pricing.Currency)
+	assert.Equal(t, "https://shop.example/images/hoodie-blue.jpg", product.Variants[0].ImageURL)
+}
+
+func TestExtractDetailImages(t *testing.T) {
+	profile := DefaultSiteProfile()
+	e := detailElement(t, testutil.MustGetFixture(t, "product_variable.html"), profile.Detail.Root, "https://shop.example/product/hoodie")
+
+	product := profile.ExtractDetail(e)
+	assert.Equal(t, []string{
+		"https://shop.example/images/hoodie.jpg",
+		"https://shop.example/images/hoodie-back.jpg",
+		"https://shop.example/images/hoodie-blue.jpg",
+		"https://shop.example/images/hoodie-large.jpg",
+	}, product.ImageURLs())
+
+	t.Run("points the product at stored images", func(t *testing.T) {
+		images := make([]Image, len(product.Images))
+		copy(images, product.Images)
+		images[0].Path = "images/ab/ab12.jpg"
+		images[2].Path = "images/cd/cd34.jpg"
+
+		product.SetImages(images)
+		assert.Equal(t, "images/ab/ab12.jpg", product.ImageURL)
+		assert.Equal(t, "images/cd/cd34.jpg", product.Variants[0].ImageURL)
+		assert.Equal(t, "https://shop.example/images/hoodie-large.jpg", product.Variants[1].ImageURL)
+		assert.Equal(t, images, product.Images)
+	})
+}
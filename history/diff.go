@@ -0,0 +1,115 @@
+package history
+
+import "sort"
+
+// Product is a product as seen by one of the compared runs
+type Product struct {
+	URL string `json:"url"`
+	Observation
+}
+
+// PriceChange is a product whose price differs between two runs
+type PriceChange struct {
+	URL  string      `json:"url"`
+	Name string      `json:"name"`
+	Old  Observation `json:"old"`
+	New  Observation `json:"new"`
+	// Comparable is set when both prices were parsed in the same currency,
+	// otherwise only their text differs and Change and Percent are zero
+	Comparable bool    `json:"comparable"`
+	Change     int64   `json:"change"`  // new minus old amount in minor units
+	Percent    float64 `json:"percent"` // change relative to the old amount
+}
+
+// StockChange is a product that went in or out of stock
+type StockChange struct {
+	URL     string `json:"url"`
+	Name    string `json:"name"`
+	InStock bool   `json:"in_stock"` // stock in the newer run
+}
+
+// Diff lists what changed between two runs. Every list is sorted by URL.
+type Diff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Added   []Product     `json:"added"`
+	Removed []Product     `json:"removed"`
+	Prices  []PriceChange `json:"price_changes"`
+	Stock   []StockChange `json:"stock_changes"`
+}
+
+// Empty reports whether nothing changed
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Prices) == 0 && len(d.Stock) == 0
+}
+
+// Compare returns the changes from the older run to the newer one
+func Compare(older, newer Run) Diff {
+	d := Diff{
+		From:    older.ID,
+		To:      newer.ID,
+		Added:   make([]Product, 0),
+		Removed: make([]Product, 0),
+		Prices:  make([]PriceChange, 0),
+		Stock:   make([]StockChange, 0),
+	}
+
+	for _, productURL := range sortedURLs(newer.Products) {
+		now := newer.Products[productURL]
+		was, ok := older.Products[productURL]
+		if !ok {
+			d.Added = append(d.Added, Product{URL: productURL, Observation: now})
+			continue
+		}
+
+		if change, ok := comparePrices(productURL, was, now); ok {
+			d.Prices = append(d.Prices, change)
+		}
+		// Listing-only runs don't know the stock
+		if was.InStock != nil && now.InStock != nil && *was.InStock != *now.InStock {
+			d.Stock = append(d.Stock, StockChange{URL: productURL, Name: now.Name, InStock: *now.InStock})
+		}
+	}
+
+	for _, productURL := range sortedURLs(older.Products) {
+		if _, ok := newer.Products[productURL]; !ok {
+			d.Removed = append(d.Removed, Product{URL: productURL, Observation: older.Products[productURL]})
+		}
+	}
+
+	return d
+}
+
+// comparePrices reports the price change of a product, if any. Parsed prices
+// in the same currency are compared by amount, others by their text.
+func comparePrices(productURL string, was, now Observation) (PriceChange, bool) {
+	change := PriceChange{URL: productURL, Name: now.Name, Old: was, New: now}
+
+	parsed := !was.Pricing.IsZero() && !now.Pricing.IsZero()
+	if parsed && was.Pricing.Currency == now.Pricing.Currency {
+		if was.Pricing.Amount == now.Pricing.Amount {
+			return PriceChange{}, false
+		}
+		change.Comparable = true
+		change.Change = now.Pricing.Amount - was.Pricing.Amount
+		if was.Pricing.Amount != 0 {
+			change.Percent = float64(change.Change) / float64(was.Pricing.Amount) * 100
+		}
+		return change, true
+	}
+
+	if was.Price == now.Price {
+		return PriceChange{}, false
+	}
+	return change, true
+}
+
+// sortedURLs returns the keys of products in order
+func sortedURLs(products map[string]Observation) []string {
+	urls := make([]string, 0, len(products))
+	for productURL := range products {
+		urls = append(urls, productURL)
+	}
+	sort.Strings(urls)
+	return urls
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+)
+
+// seen returns an observation of a product priced in USD
+func seen(price string, amount int64, inStock *bool) Observation {
+	return Observation{
+		Name:    "Widget",
+		Price:   price,
+		Pricing: extract.Price{Currency: "USD", Amount: amount},
+		InStock: inStock,
+	}
+}
+
+func TestCompare(t *testing.T) {
+	yes, no := true, false
+
+	t.Run("finds new and removed products", func(t *testing.T) {
+		older := Run{ID: 1, Products: map[string]Observation{
+			"https://shop.example/a": seen("$1.00", 100, nil),
+			"https://shop.example/b": seen("$2.00", 200, nil),
+		}}
+		newer := Run{ID: 2, Products: map[string]Observation{
+			"https://shop.example/b": seen("$2.00", 200, nil),
+			"https://shop.example/d": seen("$4.00", 400, nil),
+			"https://shop.example/c": seen("$3.00", 300, nil),
+		}}
+
+		d := Compare(older, newer)
+		assert.Equal(t, 1, d.From)
+		assert.Equal(t, 2, d.To)
+		require.Len(t, d.Added, 2)
+		assert.Equal(t, "https://shop.example/c", d.Added[0].URL)
+		assert.Equal(t, "https://shop.example/d", d.Added[1].URL)
+		require.Len(t, d.Removed, 1)
+		assert.Equal(t, "https://shop.example/a", d.Removed[0].URL)
+		assert.Empty(t, d.Prices)
+		assert.False(t, d.Empty())
+	})
+
+	t.Run("reports price changes in amount and percent", func(t *testing.T) {
+		older := Run{Products: map[string]Observation{"https://shop.example/a": seen("$20.00", 2000, nil)}}
+		newer := Run{Products: map[string]Observation{"https://shop.example/a": seen("$15.00", 1500, nil)}}
+
+		d := Compare(older, newer)
+		require.Len(t, d.Prices, 1)
+		change := d.Prices[0]
+		assert.True(t, change.Comparable)
+		assert.Equal(t, int64(-500), change.Change)
+		assert.InDelta(t, -25.0, change.Percent, 0.001)
+		assert.Equal(t, "$20.00", change.Old.Price)
+		assert.Equal(t, "$15.00", change.New.Price)
+	})
+
+	t.Run("compares unparsed prices by text", func(t *testing.T) {
+		older := Run{Products: map[string]Observation{"https://shop.example/a": {Price: "Call us"}}}
+		newer := Run{Products: map[string]Observation{"https://shop.example/a": seen("$15.00", 1500, nil)}}
+
+		d := Compare(older, newer)
+		require.Len(t, d.Prices, 1)
+		assert.False(t, d.Prices[0].Comparable)
+		assert.Zero(t, d.Prices[0].Change)
+	})
+
+	t.Run("reports stock transitions", func(t *testing.T) {
+		older := Run{Products: map[string]Observation{
+			"https://shop.example/a": seen("$1.00", 100, &yes),
+			"https://shop.example/b": seen("$1.00", 100, &no),
+			"https://shop.example/c": seen("$1.00", 100, nil),
+		}}
+		newer := Run{Products: map[string]Observation{
+			"https://shop.example/a": seen("$1.00", 100, &no),
+			"https://shop.example/b": seen("$1.00", 100, &yes),
+			"https://shop.example/c": seen("$1.00", 100, &no),
+		}}
+
+		d := Compare(older, newer)
+		require.Len(t, d.Stock, 2)
+		assert.Equal(t, StockChange{URL: "https://shop.example/a", Name: "Widget", InStock: false}, d.Stock[0])
+		assert.Equal(t, StockChange{URL: "https://shop.example/b", Name: "Widget", InStock: true}, d.Stock[1])
+	})
+
+	t.Run("identical runs are empty", func(t *testing.T) {
+		run := Run{Products: map[string]Observation{"https://shop.example/a": seen("$1.00", 100, &yes)}}
+		assert.True(t, Compare(run, run).Empty())
+	})
+}
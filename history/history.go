@@ -0,0 +1,272 @@
+// Package history keeps the price and stock of every product per run, so
+// runs can be compared to find new and removed products, price changes and
+// stock changes.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"web-scraper/extract"
+)
+
+// historyVersion is bumped whenever the history format changes incompatibly
+const historyVersion = 1
+
+// Observation is what a run saw of one product
+type Observation struct {
+	Name     string        `json:"name"`
+	Price    string        `json:"price"`
+	Pricing  extract.Price `json:"pricing"`
+	InStock  *bool         `json:"in_stock,omitempty"` // nil when only the listing was scraped
+	Category string        `json:"category,omitempty"`
+}
+
+// Run holds the products seen by one run, keyed by product URL
+type Run struct {
+	ID         int                    `json:"id"`
+	StartURL   string                 `json:"start_url"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at"`
+	Incomplete bool                   `json:"incomplete,omitempty"` // interrupted or failed before it finished
+	Failed     bool                   `json:"failed,omitempty"`     // stopped by an error
+	Products   map[string]Observation `json:"products"`
+}
+
+// Store is the run history saved in one JSON file
+type Store struct {
+	path string
+	runs []Run
+}
+
+// storeFile is the on-disk form of a Store
+type storeFile struct {
+	Version int   `json:"version"`
+	Runs    []Run `json:"runs"`
+}
+
+// Open reads the history at path. A missing file is an empty history.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var file storeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse history %s: %w", path, err)
+	}
+	if file.Version != historyVersion {
+		return nil, fmt.Errorf("history %s has unsupported version %d", path, file.Version)
+	}
+	s.runs = file.Runs
+	return s, nil
+}
+
+// Runs returns the recorded runs, oldest first
+func (s *Store) Runs() []Run {
+	return s.runs
+}
+
+// Run returns the run with the given id
+func (s *Store) Run(id int) (Run, bool) {
+	for _, run := range s.runs {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	return Run{}, false
+}
+
+// LatestPair returns the last two complete runs, older first
+func (s *Store) LatestPair() (Run, Run, error) {
+	var complete []Run
+	for _, run := range s.runs {
+		if !run.Incomplete {
+			complete = append(complete, run)
+		}
+	}
+	if len(complete) < 2 {
+		return Run{}, Run{}, fmt.Errorf("history %s has %d complete runs, at least 2 are needed", s.path, len(complete))
+	}
+	return complete[len(complete)-2], complete[len(complete)-1], nil
+}
+
+// Previous returns the last complete run before the run with the given id
+func (s *Store) Previous(id int) (Run, bool) {
+	for i := len(s.runs) - 1; i >= 0; i-- {
+		if run := s.runs[i]; run.ID < id && !run.Incomplete {
+			return run, true
+		}
+	}
+	return Run{}, false
+}
+
+// Add appends run with the next id and saves the history
+func (s *Store) Add(run Run) (Run, error) {
+	run.ID = 1
+	if len(s.runs) > 0 {
+		run.ID = s.runs[len(s.runs)-1].ID + 1
+	}
+	if run.Products == nil {
+		run.Products = make(map[string]Observation)
+	}
+
+	s.runs = append(s.runs, run)
+	if err := s.save(); err != nil {
+		s.runs = s.runs[:len(s.runs)-1]
+		return Run{}, err
+	}
+	return run, nil
+}
+
+// save writes the history atomically, so a crash mid-write leaves the
+// previous history intact
+func (s *Store) save() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(storeFile{Version: historyVersion, Runs: s.runs}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to replace history: %w", err)
+	}
+	return nil
+}
+
+// Recorder collects the products of a run as an export.Exporter and adds
+// the run to the store on Close
+type Recorder struct {
+	mu    sync.Mutex
+	store *Store
+	run   Run
+	added Run
+}
+
+// Record starts recording a run from startURL
+func (s *Store) Record(startURL string) *Recorder {
+	return &Recorder{
+		store: s,
+		run: Run{
+			StartURL:  startURL,
+			StartedAt: time.Now(),
+			Products:  make(map[string]Observation),
+		},
+	}
+}
+
+// Resume continues recording the last run from startURL when it was
+// interrupted, so the products it saw are not lost; otherwise it starts a new
+// run like Record. The interrupted run stays in the history.
+func (s *Store) Resume(startURL string) *Recorder {
+	r := s.Record(startURL)
+	if len(s.runs) == 0 {
+		return r
+	}
+	last := s.runs[len(s.runs)-1]
+	if !last.Incomplete || last.StartURL != startURL {
+		return r
+	}
+	r.run.StartedAt = last.StartedAt
+	for productURL, seen := range last.Products {
+		r.run.Products[productURL] = seen
+	}
+	return r
+}
+
+// Export records an extract.Product or extract.ProductDetail
+func (r *Recorder) Export(record any) error {
+	var productURL string
+	var seen Observation
+	switch p := record.(type) {
+	case extract.Product:
+		productURL, seen = p.URL, listingObservation(p)
+	case *extract.Product:
+		productURL, seen = p.URL, listingObservation(*p)
+	case extract.ProductDetail:
+		productURL, seen = p.URL, detailObservation(p)
+	case *extract.ProductDetail:
+		productURL, seen = p.URL, detailObservation(*p)
+	default:
+		return fmt.Errorf("cannot record %T in the history", record)
+	}
+	if productURL == "" {
+		return nil // can't be told apart from other products
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Products[productURL] = seen
+	return nil
+}
+
+// listingObservation converts a product from a listing page
+func listingObservation(p extract.Product) Observation {
+	return Observation{Name: p.Name, Price: p.Price, Pricing: p.Pricing}
+}
+
+// detailObservation converts a product from its detail page
+func detailObservation(p extract.ProductDetail) Observation {
+	inStock := p.InStock
+	return Observation{Name: p.Name, Price: p.Price, Pricing: p.Pricing, InStock: &inStock, Category: p.Category}
+}
+
+// Interrupted marks the run as stopped before it finished. Incomplete runs
+// are kept but not picked by LatestPair, as their missing products would
+// show up as removed.
+func (r *Recorder) Interrupted() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Incomplete = true
+}
+
+// Failed marks the run as stopped by an error. It is kept as incomplete,
+// with the products seen before the error.
+func (r *Recorder) Failed() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.run.Incomplete = true
+	r.run.Failed = true
+}
+
+// Close adds the run to the store
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.run.FinishedAt = time.Now()
+	added, err := r.store.Add(r.run)
+	if err != nil {
+		return err
+	}
+	r.added = added
+	return nil
+}
+
+// RunID returns the id of the recorded run once Close has saved it
+func (r *Recorder) RunID() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.added.ID
+}
@@ -0,0 +1,146 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/extract"
+)
+
+func TestOpen(t *testing.T) {
+	t.Run("missing file is an empty history", func(t *testing.T) {
+		store, err := Open(filepath.Join(t.TempDir(), "history.json"))
+		require.NoError(t, err)
+		assert.Empty(t, store.Runs())
+	})
+
+	t.Run("rejects unknown versions", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "history.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"version": 99, "runs": []}`), 0o644))
+
+		_, err := Open(path)
+		assert.ErrorContains(t, err, "unsupported version 99")
+	})
+
+	t.Run("rejects corrupt files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "history.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"runs": [`), 0o644))
+
+		_, err := Open(path)
+		assert.Error(t, err)
+	})
+}
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	t.Run("records listing and detail products", func(t *testing.T) {
+		store, err := Open(path)
+		require.NoError(t, err)
+
+		r := store.Record("https://shop.example/")
+		require.NoError(t, r.Export(extract.Product{URL: "https://shop.example/a", Name: "A", Price: "$1.00"}))
+		require.NoError(t, r.Export(&extract.ProductDetail{URL: "https://shop.example/b", Name: "B", InStock: true}))
+		require.NoError(t, r.Export(extract.Product{Name: "no URL"}))
+		assert.Error(t, r.Export("not a product"))
+		require.NoError(t, r.Close())
+		assert.Equal(t, 1, r.RunID())
+
+		reopened, err := Open(path)
+		require.NoError(t, err)
+		run, ok := reopened.Run(1)
+		require.True(t, ok)
+		assert.Equal(t, "https://shop.example/", run.StartURL)
+		assert.False(t, run.FinishedAt.IsZero())
+		require.Len(t, run.Products, 2)
+		assert.Nil(t, run.Products["https://shop.example/a"].InStock)
+		require.NotNil(t, run.Products["https://shop.example/b"].InStock)
+		assert.True(t, *run.Products["https://shop.example/b"].InStock)
+	})
+
+	t.Run("numbers runs in order", func(t *testing.T) {
+		store, err := Open(path)
+		require.NoError(t, err)
+
+		r := store.Record("https://shop.example/")
+		require.NoError(t, r.Close())
+		assert.Equal(t, 2, r.RunID())
+		assert.Len(t, store.Runs(), 2)
+	})
+
+	t.Run("resume continues an interrupted run", func(t *testing.T) {
+		store, err := Open(filepath.Join(t.TempDir(), "history.json"))
+		require.NoError(t, err)
+
+		first := store.Record("https://shop.example/")
+		require.NoError(t, first.Export(extract.ProductDetail{URL: "https://shop.example/a"}))
+		first.Interrupted()
+		require.NoError(t, first.Close())
+
+		second := store.Resume("https://shop.example/")
+		require.NoError(t, second.Export(extract.ProductDetail{URL: "https://shop.example/b"}))
+		require.NoError(t, second.Close())
+
+		run, ok := store.Run(second.RunID())
+		require.True(t, ok)
+		assert.False(t, run.Incomplete)
+		assert.Len(t, run.Products, 2)
+
+		// A complete run is not continued
+		third := store.Resume("https://shop.example/")
+		require.NoError(t, third.Close())
+		run, _ = store.Run(third.RunID())
+		assert.Empty(t, run.Products)
+	})
+
+	t.Run("keeps a failed run as incomplete", func(t *testing.T) {
+		store, err := Open(filepath.Join(t.TempDir(), "history.json"))
+		require.NoError(t, err)
+
+		r := store.Record("https://shop.example/")
+		require.NoError(t, r.Export(extract.Product{URL: "https://shop.example/a"}))
+		r.Failed()
+		require.NoError(t, r.Close())
+
+		run, ok := store.Run(r.RunID())
+		require.True(t, ok)
+		assert.True(t, run.Failed)
+		assert.True(t, run.Incomplete, "not compared with other runs")
+		assert.Len(t, run.Products, 1)
+		assert.False(t, run.FinishedAt.IsZero())
+	})
+}
+
+func TestLatestPair(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "history.json"))
+	require.NoError(t, err)
+
+	add := func(incomplete bool) {
+		_, err := store.Add(Run{Incomplete: incomplete})
+		require.NoError(t, err)
+	}
+
+	add(false)
+	_, _, err = store.LatestPair()
+	assert.ErrorContains(t, err, "1 complete runs")
+
+	add(false)
+	add(true)
+	older, newer, err := store.LatestPair()
+	require.NoError(t, err)
+	assert.Equal(t, 1, older.ID)
+	assert.Equal(t, 2, newer.ID)
+
+	t.Run("previous skips incomplete runs", func(t *testing.T) {
+		add(false)
+		previous, ok := store.Previous(4)
+		require.True(t, ok)
+		assert.Equal(t, 2, previous.ID)
+
+		_, ok = store.Previous(1)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,493 @@
+// Package testutil provides mock servers and fixtures shared by the package tests
+package testutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// CreateMockServer creates a mock HTTP server with a custom handler
+func CreateMockServer(handler http.Handler) *httptest.Server {
+	return httptest.NewServer(handler)
+}
+
+// CreateMockServerWithRoutes creates a mock server with specific routes
+func CreateMockServerWithRoutes(routes map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+
+	for path, content := range routes {
+		path := path       // capture loop variable
+		content := content // capture loop variable
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(content))
+		})
+	}
+
+	return httptest.NewServer(mux)
+}
+
+// CountingServer is a mock server for routes that records when each path
+// was requested. Paths can be made to fail or to hang until released.
+// Paths without a route get a 404.
+type CountingServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	routes   map[string]string
+	hits     map[string][]time.Time
+	failures map[string]*mockFailure
+	blocked  map[string]chan struct{}
+}
+
+// mockFailure is an error response served for the next remaining requests
+type mockFailure struct {
+	status     int
+	remaining  int // negative fails every request
+	retryAfter string
+}
+
+// CreateCountingServer creates a counting mock server with specific routes
+func CreateCountingServer(routes map[string]string) *CountingServer {
+	cs := &CountingServer{
+		routes:   make(map[string]string),
+		hits:     make(map[string][]time.Time),
+		failures: make(map[string]*mockFailure),
+		blocked:  make(map[string]chan struct{}),
+	}
+	for path, content := range routes {
+		cs.routes[path] = content
+	}
+
+	cs.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cs.mu.Lock()
+		cs.hits[r.URL.Path] = append(cs.hits[r.URL.Path], time.Now())
+		release := cs.blocked[r.URL.Path]
+		cs.mu.Unlock()
+
+		if release != nil {
+			<-release
+		}
+
+		cs.mu.Lock()
+		var failure mockFailure
+		if f := cs.failures[r.URL.Path]; f != nil && f.remaining != 0 {
+			f.remaining--
+			failure = *f
+		}
+		content, ok := cs.routes[r.URL.Path]
+		cs.mu.Unlock()
+
+		if failure.status != 0 {
+			if failure.retryAfter != "" {
+				w.Header().Set("Retry-After", failure.retryAfter)
+			}
+			w.WriteHeader(failure.status)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(content))
+	}))
+	return cs
+}
+
+// Route sets the content served for path
+func (cs *CountingServer) Route(path, content string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.routes[path] = content
+}
+
+// Fail makes the next times requests for path fail with status, sending
+// retryAfter as the Retry-After header unless empty. A negative times fails
+// every request.
+func (cs *CountingServer) Fail(path string, status, times int, retryAfter string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.failures[path] = &mockFailure{status: status, remaining: times, retryAfter: retryAfter}
+}
+
+// Block makes requests for path wait until the returned function is called
+func (cs *CountingServer) Block(path string) func() {
+	release := make(chan struct{})
+	cs.mu.Lock()
+	cs.blocked[path] = release
+	cs.mu.Unlock()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(release) }) }
+}
+
+// Hits returns when path was requested, oldest first
+func (cs *CountingServer) Hits(path string) []time.Time {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return append([]time.Time(nil), cs.hits[path]...)
+}
+
+// Count returns how many times path was requested
+func (cs *CountingServer) Count(path string) int {
+	return len(cs.Hits(path))
+}
+
+// Reset forgets the requests recorded so far
+func (cs *CountingServer) Reset() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.hits = make(map[string][]time.Time)
+}
+
+// fixtureDir is the repository's top-level testdata directory, shared by
+// the tests of every package
+var fixtureDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "testdata")
+}()
+
+// GetFixture reads a test fixture from the testdata directory
+func GetFixture(filename string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(fixtureDir, filename))
+	if err != nil {
+		return "", fmt.Errorf("failed to read fixture %s: %w", filename, err)
+	}
+	return string(data), nil
+}
+
+// MustGetFixture reads a fixture or panics (for test setup)
+func MustGetFixture(t *testing.T, filename string) string {
+	t.Helper()
+	content, err := GetFixture(filename)
+	if err != nil {
+		t.Fatalf("Failed to load fixture %s: %v", filename, err)
+	}
+	return content
+}
+
+// ListingWithAbsoluteLinks returns the listing.html fixture with its product links
+// made absolute against serverURL, as the detail collector needs them
+func ListingWithAbsoluteLinks(t *testing.T, serverURL string) string {
+	t.Helper()
+	return strings.ReplaceAll(MustGetFixture(t, "listing.html"), `href="/product/`, `href="`+serverURL+`/product/`)
+}
+
+// CreateShopServer creates a counting mock server for a one page shop: the
+// listing.html fixture at / and product.html for each of its three products
+func CreateShopServer(t *testing.T) *CountingServer {
+	t.Helper()
+	product := MustGetFixture(t, "product.html")
+	server := CreateCountingServer(map[string]string{
+		"/product/test-product-1": product,
+		"/product/test-product-2": product,
+		"/product/test-product-3": product,
+		"/page/2":                 "<html><body></body></html>",
+	})
+	server.Route("/", ListingWithAbsoluteLinks(t, server.URL))
+	return server
+}
+
+// CompareCSV compares two CSV files for equality
+func CompareCSV(t *testing.T, expectedFile, actualFile string) {
+	t.Helper()
+
+	expected, err := ReadCSVFile(expectedFile)
+	assert.NoError(t, err, "Failed to read expected CSV")
+
+	actual, err := ReadCSVFile(actualFile)
+	assert.NoError(t, err, "Failed to read actual CSV")
+
+	assert.Equal(t, len(expected), len(actual), "CSV row count mismatch")
+
+	for i := range expected {
+		assert.Equal(t, expected[i], actual[i], "CSV row %d mismatch", i)
+	}
+}
+
+// ReadCSVFile reads a CSV file and returns all rows
+func ReadCSVFile(filename string) ([][]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	return reader.ReadAll()
+}
+
+// CreateTempCSV creates a temporary CSV file with given data
+func CreateTempCSV(t *testing.T, data [][]string) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test.csv")
+
+	file, err := os.Create(filename)
+	assert.NoError(t, err, "Failed to create temp CSV file")
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	for _, row := range data {
+		err := writer.Write(row)
+		assert.NoError(t, err, "Failed to write CSV row")
+	}
+
+	return filename
+}
+
+// FileExists checks if a file exists
+func FileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
+
+// ProxyServer is a forward HTTP proxy that relays requests to their target
+// and counts them. A banned proxy answers every request with a block page
+// instead.
+type ProxyServer struct {
+	*httptest.Server
+	mu        sync.Mutex
+	requests  int
+	banStatus int
+	banBody   string
+	auth      string // expected Proxy-Authorization, empty accepts any request
+}
+
+// CreateProxyServer starts a forward HTTP proxy
+func CreateProxyServer() *ProxyServer {
+	ps := &ProxyServer{}
+	ps.Server = httptest.NewServer(http.HandlerFunc(ps.serve))
+	return ps
+}
+
+// serve relays a proxied request, or answers it with the block page
+func (ps *ProxyServer) serve(w http.ResponseWriter, r *http.Request) {
+	ps.mu.Lock()
+	ps.requests++
+	status, body, auth := ps.banStatus, ps.banBody, ps.auth
+	ps.mu.Unlock()
+
+	if auth != "" && r.Header.Get("Proxy-Authorization") != auth {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="proxy"`)
+		w.WriteHeader(http.StatusProxyAuthRequired)
+		return
+	}
+	if status != 0 {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Authorization")
+	resp, err := http.DefaultTransport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// Ban makes the proxy answer every request with status and body
+func (ps *ProxyServer) Ban(status int, body string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.banStatus, ps.banBody = status, body
+}
+
+// RequireAuth makes the proxy answer 407 to requests without the basic
+// credentials user and password
+func (ps *ProxyServer) RequireAuth(user, password string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// Requests returns how many requests reached the proxy
+func (ps *ProxyServer) Requests() int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return ps.requests
+}
+
+// SOCKS5Server is a SOCKS5 proxy relaying CONNECT requests, with username
+// and password authentication when it has a user
+type SOCKS5Server struct {
+	URL         string // socks5://host:port, without the credentials
+	listener    net.Listener
+	user        string
+	password    string
+	mu          sync.Mutex
+	connections int
+}
+
+// CreateSOCKS5Server starts a SOCKS5 proxy. An empty user accepts clients
+// without authentication.
+func CreateSOCKS5Server(t *testing.T, user, password string) *SOCKS5Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &SOCKS5Server{URL: "socks5://" + listener.Addr().String(), listener: listener, user: user, password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// Close stops accepting connections
+func (s *SOCKS5Server) Close() {
+	s.listener.Close()
+}
+
+// Connections returns how many CONNECT requests the proxy relayed
+func (s *SOCKS5Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+// serve runs the SOCKS5 handshake of RFC 1928 and 1929 on conn and relays it
+func (s *SOCKS5Server) serve(conn net.Conn) {
+	defer conn.Close()
+
+	// Greeting: version, methods
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil || header[0] != 5 {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	method := byte(0x00)
+	if s.user != "" {
+		method = 0x02
+	}
+	if !bytes.Contains(methods, []byte{method}) {
+		conn.Write([]byte{5, 0xff})
+		return
+	}
+	conn.Write([]byte{5, method})
+
+	if s.user != "" {
+		// Username and password: version, ulen, user, plen, password
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		user := make([]byte, header[1])
+		io.ReadFull(conn, user)
+		plen := make([]byte, 1)
+		io.ReadFull(conn, plen)
+		password := make([]byte, plen[0])
+		io.ReadFull(conn, password)
+		if string(user) != s.user || string(password) != s.password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	// Request: version, command, reserved, address type, address, port
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil || request[1] != 1 {
+		return
+	}
+	var host string
+	switch request[3] {
+	case 1:
+		ip := make([]byte, 4)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	case 3:
+		length := make([]byte, 1)
+		io.ReadFull(conn, length)
+		name := make([]byte, length[0])
+		io.ReadFull(conn, name)
+		host = string(name)
+	case 4:
+		ip := make([]byte, 16)
+		io.ReadFull(conn, ip)
+		host = net.IP(ip).String()
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+
+	target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(port[0])<<8|int(port[1]))))
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	s.mu.Lock()
+	s.connections++
+	s.mu.Unlock()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+// CreateMockServerWithStatus creates a server that returns a specific status code
+func CreateMockServerWithStatus(statusCode int, content string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(statusCode)
+		w.Write([]byte(content))
+	}))
+}
+
+// ExtractDomain extracts the domain (including port) from a server URL for use with collectors
+func ExtractDomain(serverURL string) string {
+	// Remove http:// or https://
+	domain := serverURL
+	if len(domain) > 7 && domain[:7] == "http://" {
+		domain = domain[7:]
+	} else if len(domain) > 8 && domain[:8] == "https://" {
+		domain = domain[8:]
+	}
+	// Remove any path components, keeping just host:port
+	if idx := strings.Index(domain, "/"); idx != -1 {
+		domain = domain[:idx]
+	}
+	return domain
+}
@@ -0,0 +1,130 @@
+// Package validate checks scraped products against declarative field rules,
+// sets rejected products aside with the reasons, and reports how well each
+// field is filled.
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is the checks one field of a product must pass. Checks other than
+// Required pass for empty values.
+type Rule struct {
+	Field       string   `yaml:"field" json:"field"`
+	Required    bool     `yaml:"required,omitempty" json:"required,omitempty"`
+	Pattern     string   `yaml:"pattern,omitempty" json:"pattern,omitempty"`           // regular expression, unanchored
+	AbsoluteURL bool     `yaml:"absolute_url,omitempty" json:"absolute_url,omitempty"` // http or https URL with a host
+	Min         *float64 `yaml:"min,omitempty" json:"min,omitempty"`                   // numeric lower bound, inclusive
+	Max         *float64 `yaml:"max,omitempty" json:"max,omitempty"`                   // numeric upper bound, inclusive
+
+	pattern *regexp.Regexp
+}
+
+// Config holds the validation rules
+type Config struct {
+	Rules []Rule `yaml:"rules" json:"rules"`
+}
+
+// LoadConfig reads validation rules from a YAML or JSON file.
+// Files ending in .json are decoded as JSON, everything else as YAML.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read validation rules: %w", err)
+	}
+
+	config := &Config{}
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse validation rules %s: %w", filename, err)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid validation rules %s: %w", filename, err)
+	}
+	return config, nil
+}
+
+// Validate checks the rules and compiles their patterns
+func (c *Config) Validate() error {
+	if len(c.Rules) == 0 {
+		return errors.New("no rules")
+	}
+	for i := range c.Rules {
+		if err := c.Rules[i].validate(); err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i+1, c.Rules[i].Field, err)
+		}
+	}
+	return nil
+}
+
+// validate checks the rule's field and settings
+func (r *Rule) validate() error {
+	if !knownField(r.Field) {
+		return fmt.Errorf("unknown field %q, expected one of %s", r.Field, strings.Join(Fields, ", "))
+	}
+	if !r.Required && r.Pattern == "" && !r.AbsoluteURL && r.Min == nil && r.Max == nil {
+		return errors.New("no checks")
+	}
+	if r.Pattern != "" {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		r.pattern = pattern
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return fmt.Errorf("min %g is above max %g", *r.Min, *r.Max)
+	}
+	return nil
+}
+
+// check returns why value breaks the rule, or "" when it passes
+func (r *Rule) check(value string) string {
+	if value == "" {
+		if r.Required {
+			return "required"
+		}
+		return ""
+	}
+	if r.pattern != nil && !r.pattern.MatchString(value) {
+		return fmt.Sprintf("%q does not match %s", value, r.Pattern)
+	}
+	if r.AbsoluteURL {
+		if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Sprintf("%q is not an absolute URL", value)
+		}
+	}
+	if r.Min != nil || r.Max != nil {
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Sprintf("%q is not a number", value)
+		}
+		if r.Min != nil && number < *r.Min {
+			return fmt.Sprintf("%s is below the minimum of %g", value, *r.Min)
+		}
+		if r.Max != nil && number > *r.Max {
+			return fmt.Sprintf("%s is above the maximum of %g", value, *r.Max)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package validate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes content to a file called name in a temp dir
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("loads YAML", func(t *testing.T) {
+		path := writeConfig(t, "rules.yaml", `
+rules:
+  - field: sku
+    required: true
+    pattern: ^[A-Z0-9-]+$
+  - field: image_url
+    absolute_url: true
+  - field: amount
+    min: 0.01
+    max: 10000
+`)
+
+		config, err := LoadConfig(path)
+		require.NoError(t, err)
+		require.Len(t, config.Rules, 3)
+		assert.NotNil(t, config.Rules[0].pattern)
+		assert.True(t, config.Rules[1].AbsoluteURL)
+		assert.Equal(t, 0.01, *config.Rules[2].Min)
+	})
+
+	t.Run("loads JSON", func(t *testing.T) {
+		path := writeConfig(t, "rules.json", `{"rules": [{"field": "price", "required": true}]}`)
+
+		config, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.True(t, config.Rules[0].Required)
+	})
+
+	invalid := []struct {
+		name, content, err string
+	}{
+		{"unknown key", "rules: [{field: sku, requird: true}]\n", "field requird not found"},
+		{"no rules", "rules: []\n", "no rules"},
+		{"unknown field", "rules: [{field: colour, required: true}]\n", `unknown field "colour"`},
+		{"no checks", "rules: [{field: sku}]\n", "no checks"},
+		{"bad pattern", "rules: [{field: sku, pattern: '[A-'}]\n", "invalid pattern"},
+		{"inverted range", "rules: [{field: rating, min: 5, max: 1}]\n", "min 5 is above max 1"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, "rules.yaml", tt.content))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorContains(t, err, "failed to read validation rules")
+	})
+}
+
+func TestRuleCheck(t *testing.T) {
+	min, max := 0.01, 100.0
+	tests := []struct {
+		name  string
+		rule  Rule
+		value string
+		want  string
+	}{
+		{"required and filled", Rule{Required: true}, "x", ""},
+		{"required and empty", Rule{Required: true}, "", "required"},
+		{"optional and empty", Rule{Pattern: "^A", AbsoluteURL: true, Min: &min}, "", ""},
+		{"pattern matches", Rule{Pattern: "^[A-Z]+-[0-9]+$"}, "SKU-1", ""},
+		{"pattern fails", Rule{Pattern: "^[A-Z]+-[0-9]+$"}, "sku 1", `"sku 1" does not match ^[A-Z]+-[0-9]+$`},
+		{"absolute URL", Rule{AbsoluteURL: true}, "https://shop.example/a.jpg", ""},
+		{"relative URL", Rule{AbsoluteURL: true}, "/images/a.jpg", `"/images/a.jpg" is not an absolute URL`},
+		{"other scheme", Rule{AbsoluteURL: true}, "ftp://shop.example/a.jpg", `"ftp://shop.example/a.jpg" is not an absolute URL`},
+		{"in range", Rule{Min: &min, Max: &max}, "19.99", ""},
+		{"below range", Rule{Min: &min, Max: &max}, "0.00", "0.00 is below the minimum of 0.01"},
+		{"above range", Rule{Min: &min, Max: &max}, "250", "250 is above the maximum of 100"},
+		{"not a number", Rule{Max: &max}, "cheap", `"cheap" is not a number`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			rule.Field = "sku"
+			require.NoError(t, rule.validate())
+			assert.Equal(t, tt.want, rule.check(tt.value))
+		})
+	}
+}
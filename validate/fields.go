@@ -0,0 +1,86 @@
+package validate
+
+import (
+	"strconv"
+
+	"web-scraper/extract"
+)
+
+// Fields are the product fields rules can check, named like the JSON export
+// of extract.ProductDetail. amount is the parsed price in major units, e.g.
+// "19.99". Listing products only have url, name, price, amount, currency and
+// image_url.
+var Fields = []string{
+	"url", "name", "price", "amount", "currency", "description", "sku", "category",
+	"image_url", "brand", "gtin", "rating", "review_count",
+}
+
+// knownField reports whether name is one of Fields
+func knownField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// field is the value of a product field, empty when not filled
+type field struct {
+	name  string
+	value string
+}
+
+// fieldsOf returns the fields of a product record in the order of Fields, or
+// false for records that are not products
+func fieldsOf(record any) ([]field, bool) {
+	switch p := record.(type) {
+	case extract.Product:
+		return []field{
+			{"url", p.URL},
+			{"name", p.Name},
+			{"price", p.Price},
+			{"amount", amount(p.Pricing)},
+			{"currency", p.Pricing.Currency},
+			{"image_url", p.Image},
+		}, true
+	case *extract.Product:
+		return fieldsOf(*p)
+	case extract.ProductDetail:
+		return []field{
+			{"url", p.URL},
+			{"name", p.Name},
+			{"price", p.Price},
+			{"amount", amount(p.Pricing)},
+			{"currency", p.Pricing.Currency},
+			{"description", p.Description},
+			{"sku", p.SKU},
+			{"category", p.Category},
+			{"image_url", p.ImageURL},
+			{"brand", p.Brand},
+			{"gtin", p.GTIN},
+			{"rating", nonZero(strconv.FormatFloat(p.Rating, 'f', -1, 64))},
+			{"review_count", nonZero(strconv.Itoa(p.ReviewCount))},
+		}, true
+	case *extract.ProductDetail:
+		return fieldsOf(*p)
+	default:
+		return nil, false
+	}
+}
+
+// amount renders a parsed price in major units, empty when it wasn't parsed
+func amount(p extract.Price) string {
+	if p.IsZero() {
+		return ""
+	}
+	return extract.FormatAmount(p.Amount, p.Currency)
+}
+
+// nonZero returns number, or "" for an unset "0"
+func nonZero(number string) string {
+	if number == "0" {
+		return ""
+	}
+	return number
+}
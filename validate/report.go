@@ -0,0 +1,54 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+)
+
+// Report is the data-quality summary of a run
+type Report struct {
+	Products int          `json:"products"` // products checked
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Fields   []FieldStats `json:"fields"` // in the order of Fields
+}
+
+// FieldStats is how well one field was filled
+type FieldStats struct {
+	Field      string  `json:"field"`
+	Products   int     `json:"products"`   // products that have the field
+	Filled     int     `json:"filled"`     // of those, the ones with a value
+	FillRate   float64 `json:"fill_rate"`  // Filled / Products
+	Violations int     `json:"violations"` // products rejected by a rule on the field
+}
+
+// Print writes the report as a table
+func (r Report) Print(w io.Writer) error {
+	fmt.Fprintf(w, "\n=== Data Quality ===\n")
+	fmt.Fprintf(w, "Products checked: %d (%d accepted, %d rejected)\n", r.Products, r.Accepted, r.Rejected)
+	if len(r.Fields) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Field\tFilled\tFill rate\tViolations")
+	for _, stats := range r.Fields {
+		fmt.Fprintf(tw, "%s\t%d/%d\t%.1f%%\t%d\n", stats.Field, stats.Filled, stats.Products, stats.FillRate*100, stats.Violations)
+	}
+	return tw.Flush()
+}
+
+// WriteJSON writes the report to an indented JSON file
+func (r Report) WriteJSON(filename string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode report: %w", err)
+	}
+	if err := os.WriteFile(filename, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package validate
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"web-scraper/export"
+)
+
+// Reject is a product that failed validation, as handed to the rejects
+// exporter
+type Reject struct {
+	Record  any      `json:"record"`
+	Reasons []string `json:"reasons"` // e.g. "sku: required"
+}
+
+// Validator checks products against rules and keeps the data-quality report
+// of a run. It is safe for concurrent use.
+type Validator struct {
+	rules   []Rule
+	rejects export.Exporter // nil drops rejected products
+	mu      sync.Mutex
+	report  Report
+	columns map[string]int // index of each field in report.Fields
+}
+
+// New returns a validator for the rules of config, which must have passed
+// Validate as LoadConfig does. A nil config accepts every product and only
+// reports fill rates. Rejected products are handed to rejects unless it is
+// nil; the validator never closes it.
+func New(config *Config, rejects export.Exporter) *Validator {
+	v := &Validator{rejects: rejects, columns: make(map[string]int)}
+	if config != nil {
+		v.rules = config.Rules
+	}
+	return v
+}
+
+// Check validates record and reports whether it is accepted. A rejected
+// record is handed to the rejects exporter with the reasons, and the error
+// is that export's. Records that are not products are accepted unchecked.
+func (v *Validator) Check(record any) (bool, error) {
+	fields, ok := fieldsOf(record)
+	if !ok {
+		return true, nil
+	}
+
+	var reasons []string
+	violated := make(map[string]bool)
+	for i := range v.rules {
+		rule := &v.rules[i]
+		value, ok := lookup(fields, rule.Field)
+		if !ok {
+			continue // listing products lack most detail fields
+		}
+		if reason := rule.check(value); reason != "" {
+			reasons = append(reasons, rule.Field+": "+reason)
+			violated[rule.Field] = true
+		}
+	}
+
+	v.mu.Lock()
+	v.add(fields, violated)
+	v.mu.Unlock()
+
+	if len(reasons) == 0 {
+		return true, nil
+	}
+	productURL, _ := lookup(fields, "url")
+	log.Printf("[REJECT] %s: %s", productURL, strings.Join(reasons, "; "))
+	if v.rejects == nil {
+		return false, nil
+	}
+	return false, v.rejects.Export(Reject{Record: record, Reasons: reasons})
+}
+
+// add counts a checked product in the report. The caller must hold v.mu.
+func (v *Validator) add(fields []field, violated map[string]bool) {
+	v.report.Products++
+	if len(violated) == 0 {
+		v.report.Accepted++
+	} else {
+		v.report.Rejected++
+	}
+
+	for _, f := range fields {
+		i, ok := v.columns[f.name]
+		if !ok {
+			i = len(v.report.Fields)
+			v.columns[f.name] = i
+			v.report.Fields = append(v.report.Fields, FieldStats{Field: f.name})
+		}
+		stats := &v.report.Fields[i]
+		stats.Products++
+		if f.value != "" {
+			stats.Filled++
+		}
+		if violated[f.name] {
+			stats.Violations++
+		}
+		stats.FillRate = float64(stats.Filled) / float64(stats.Products)
+	}
+}
+
+// Report returns the data-quality report of the products checked so far
+func (v *Validator) Report() Report {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	report := v.report
+	report.Fields = append([]FieldStats{}, v.report.Fields...)
+	return report
+}
+
+// lookup returns the value of the named field, false when the product
+// doesn't have it
+func lookup(fields []field, name string) (string, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f.value, true
+		}
+	}
+	return "", false
+}
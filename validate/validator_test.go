@@ -0,0 +1,113 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"web-scraper/export"
+	"web-scraper/extract"
+)
+
+// testRules requires a SKU and an absolute image URL
+func testRules(t *testing.T) *Config {
+	t.Helper()
+	config := &Config{Rules: []Rule{
+		{Field: "sku", Required: true},
+		{Field: "image_url", AbsoluteURL: true},
+	}}
+	require.NoError(t, config.Validate())
+	return config
+}
+
+func TestValidator(t *testing.T) {
+	t.Run("rejects products with the reasons", func(t *testing.T) {
+		var buf bytes.Buffer
+		v := New(testRules(t), export.NewJSONLinesExporter(&buf))
+
+		accepted, err := v.Check(extract.ProductDetail{URL: "https://shop.example/a", Name: "A", SKU: "A-1", ImageURL: "https://shop.example/a.jpg"})
+		require.NoError(t, err)
+		assert.True(t, accepted)
+
+		accepted, err = v.Check(&extract.ProductDetail{URL: "https://shop.example/b", Name: "B", ImageURL: "/b.jpg"})
+		require.NoError(t, err)
+		assert.False(t, accepted)
+
+		var reject struct {
+			Record  extract.ProductDetail `json:"record"`
+			Reasons []string              `json:"reasons"`
+		}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &reject))
+		assert.Equal(t, "https://shop.example/b", reject.Record.URL)
+		assert.Equal(t, []string{"sku: required", `image_url: "/b.jpg" is not an absolute URL`}, reject.Reasons)
+	})
+
+	t.Run("skips rules on fields listing products lack", func(t *testing.T) {
+		v := New(testRules(t), nil)
+
+		accepted, err := v.Check(extract.Product{Name: "A", Image: "https://shop.example/a.jpg"})
+		require.NoError(t, err)
+		assert.True(t, accepted)
+
+		accepted, err = v.Check(extract.Product{Name: "B", Image: "b.jpg"})
+		require.NoError(t, err)
+		assert.False(t, accepted)
+	})
+
+	t.Run("accepts records that are not products", func(t *testing.T) {
+		accepted, err := New(testRules(t), nil).Check(extract.Page{URL: "https://shop.example/"})
+		require.NoError(t, err)
+		assert.True(t, accepted)
+	})
+
+	t.Run("returns the rejects export error", func(t *testing.T) {
+		rejects, err := export.CreateJSONLines(filepath.Join(t.TempDir(), "rejects.jsonl"), false)
+		require.NoError(t, err)
+		require.NoError(t, rejects.Close())
+
+		_, err = New(testRules(t), rejects).Check(extract.ProductDetail{Name: "B"})
+		assert.ErrorContains(t, err, "failed to write record")
+	})
+}
+
+func TestValidatorReport(t *testing.T) {
+	v := New(testRules(t), nil)
+	v.Check(extract.ProductDetail{Name: "A", SKU: "A-1", Price: "$10.00", Pricing: extract.Price{Currency: "USD", Amount: 1000}})
+	v.Check(extract.ProductDetail{Name: "B", Rating: 4.5})
+	v.Check(extract.ProductDetail{Name: "C", SKU: "C-1", ImageURL: "c.jpg"})
+
+	report := v.Report()
+	assert.Equal(t, 3, report.Products)
+	assert.Equal(t, 1, report.Accepted)
+	assert.Equal(t, 2, report.Rejected)
+	require.Len(t, report.Fields, len(Fields))
+
+	stats := make(map[string]FieldStats)
+	for _, field := range report.Fields {
+		stats[field.Field] = field
+	}
+	assert.Equal(t, FieldStats{Field: "name", Products: 3, Filled: 3, FillRate: 1}, stats["name"])
+	assert.Equal(t, FieldStats{Field: "sku", Products: 3, Filled: 2, FillRate: 2.0 / 3, Violations: 1}, stats["sku"])
+	assert.Equal(t, FieldStats{Field: "amount", Products: 3, Filled: 1, FillRate: 1.0 / 3}, stats["amount"])
+	assert.Equal(t, 1, stats["image_url"].Violations)
+	assert.Equal(t, 1, stats["rating"].Filled)
+	assert.Equal(t, 0, stats["review_count"].Filled)
+
+	var out strings.Builder
+	require.NoError(t, report.Print(&out))
+	assert.Contains(t, out.String(), "Products checked: 3 (1 accepted, 2 rejected)")
+	assert.Regexp(t, `sku\s+2/3\s+66\.7%\s+1`, out.String())
+
+	t.Run("without rules only reports fill rates", func(t *testing.T) {
+		v := New(nil, nil)
+		accepted, err := v.Check(extract.Product{Name: "A"})
+		require.NoError(t, err)
+		assert.True(t, accepted)
+		assert.Equal(t, 1, v.Report().Accepted)
+		assert.Len(t, v.Report().Fields, 6)
+	})
+}